@@ -2,15 +2,26 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
+	"maps"
+	"mime"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ledongthuc/pdf"
 	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
@@ -20,7 +31,12 @@ import (
 
 // Користувацька сесія для відстеження стану
 type UserSession struct {
-	AwaitingDocument bool
+	AwaitingDocument bool                           `json:"awaiting_document"`
+	History          []openai.ChatCompletionMessage `json:"history"`                   // Останні репліки розмови
+	Collection       string                         `json:"collection,omitempty"`      // Колекція з /collection (порожньо - всі)
+	TopK             int                            `json:"top_k,omitempty"`           // Кількість збігів з /set topk (0 - PINECONE_TOP_K)
+	MinScore         *float32                       `json:"min_score,omitempty"`       // Поріг оцінки з /set threshold (nil - PINECONE_MIN_SCORE)
+	AwaitingUpdate   bool                           `json:"awaiting_update,omitempty"` // Очікуваний документ замінює попередню версію (/update)
 }
 
 var (
@@ -36,12 +52,213 @@ var (
 	PineconeAPIKey = os.Getenv("PINECONE_API_KEY") // Pinecone API Key
 
 	// Pinecone спеціфічні налаштування:
-	PineconeIndex = "telegram"  // Назва індексу
-	PineconeEnv   = "us-east-1" // Середовище Pinecone (регіон)
+	PineconeIndex = envOrDefault("PINECONE_INDEX", "telegram") // Назва індексу типової колекції
+	PineconeEnv   = "us-east-1"                                // Середовище Pinecone (регіон)
 
-	// Винесення OpenAI моделі до змінних середовища
-	//OpenAIModel = os.Getenv("OPENAI_MODEL") // Модель OpenAI
-	OpenAIModel = "gpt-4o"
+	// Колекції документів в окремих індексах Pinecone, заповнюються в loadConfig:
+	// PINECONE_COLLECTIONS="resumes=resumes-index,projects=projects-index" (колекція default - завжди індекс PINECONE_INDEX)
+	PineconeCollections map[string]string
+	// Колекція за типом документа під час завантаження: PINECONE_TYPE_COLLECTIONS="pdf=resumes,md=projects"
+	PineconeTypeCollections map[string]string
+
+	// Спосіб отримання оновлень: polling (типово) або webhook
+	BotMode = envOrDefault("BOT_MODE", "polling")
+
+	// Адреса HTTP сервера з /healthz та /readyz для проб оркестратора
+	HealthListen = envOrDefault("HEALTH_LISTEN", ":8081")
+
+	// Спільний namespace для всіх користувачів (публічне портфоліо) замість окремого для кожного
+	PineconeSharedNamespace = os.Getenv("PINECONE_SHARED_NAMESPACE") == "true"
+
+	// Додаткові namespace (наприклад, спільний публічний), у яких шукаємо разом із namespace користувача.
+	// Спільний namespace без назви позначається як __default__.
+	PineconeSearchNamespaces = splitList(os.Getenv("PINECONE_SEARCH_NAMESPACES"))
+
+	// Поля JSON, текст яких векторизується (у заданому порядку), і поля, що стають метаданими.
+	// Вкладені поля задаються через крапку, наприклад author.name. Без JSON_METADATA_FIELDS
+	// метаданими стають усі прості поля верхнього рівня, крім текстових; якщо жодного текстового
+	// поля в записі немає, векторизуються всі його рядкові значення.
+	JSONTextFields     = splitList(envOrDefault("JSON_TEXT_FIELDS", "text"))
+	JSONMetadataFields = splitList(os.Getenv("JSON_METADATA_FIELDS"))
+
+	// Колонки CSV, текст яких векторизується (порожньо - усі); усі колонки стають метаданими
+	CSVTextColumns      = splitList(os.Getenv("CSV_TEXT_COLUMNS"))
+	CSVDelimiter   rune = ',' // CSV_DELIMITER: роздільник колонок
+
+	// Обмеження кількості запитів користувача (0 - без обмежень)
+	RateLimitPerMinute = 10 // RATE_LIMIT_PER_MINUTE
+
+	// Квота бази знань користувача, крім адміністраторів (0 - без обмежень)
+	UserMaxDocuments = 0 // USER_MAX_DOCUMENTS: кількість документів у namespace користувача
+	UserMaxVectors   = 0 // USER_MAX_VECTORS: загальна кількість частин (векторів) у namespace користувача
+
+	// Скільки документів одного користувача обробляються одночасно; решта чекає в черзі
+	UserMaxConcurrentUploads = 1 // USER_MAX_CONCURRENT_UPLOADS
+
+	// Тарифи OpenAI у доларах за 1000 токенів для оцінки вартості
+	OpenAIEmbeddingCostPer1K  = 0.0001 // OPENAI_EMBEDDING_COST_PER_1K
+	OpenAIPromptCostPer1K     = 0.0025 // OPENAI_PROMPT_COST_PER_1K
+	OpenAICompletionCostPer1K = 0.01   // OPENAI_COMPLETION_COST_PER_1K
+
+	// Telegram ID адміністраторів з ADMIN_IDS (через кому), заповнюється в loadConfig
+	AdminIDs = map[int64]bool{}
+
+	// Файл локального реєстру завантажених документів
+	RegistryPath = envOrDefault("REGISTRY_PATH", "registry.json")
+
+	// Файл відгуків 👍/👎 на відповіді (один JSON на рядок)
+	FeedbackPath = envOrDefault("FEEDBACK_PATH", "feedback.jsonl")
+
+	// Файл, у якому сесії користувачів переживають перезапуск бота
+	SessionStorePath = envOrDefault("SESSION_STORE_PATH", "sessions.json")
+	SessionSaveDelay = 2 * time.Second // SESSION_SAVE_DELAY: затримка, за яку зміни сесій збираються в один запис
+
+	// Розбиття документів на частини перед векторизацією
+	ChunkMaxTokens        = 500 // Максимальний розмір частини у токенах
+	ChunkOverlapSentences = 2   // CHUNK_OVERLAP_SENTENCES: скільки останніх речень частини повторюється на початку наступної
+
+	// Пам'ять розмови для уточнюючих запитань
+	HistoryMaxTurns  = 6    // Скільки останніх пар запит-відповідь зберігати
+	HistoryMaxTokens = 2000 // Бюджет токенів для історії у запиті до GPT
+
+	// Винесення OpenAI моделей до змінних середовища
+	OpenAIModel          = envOrDefault("OPENAI_MODEL", "gpt-4o")                           // Модель для відповідей
+	OpenAIEmbeddingModel = envOrDefault("OPENAI_EMBEDDING_MODEL", "text-embedding-ada-002") // Модель для векторизації
+	VisionModel          = envOrDefault("OPENAI_VISION_MODEL", "gpt-4o")                    // Модель для запитань щодо фото
+
+	// Зменшена розмірність векторів text-embedding-3-*, щоб підійти до наявного індексу (0 - повна розмірність моделі)
+	OpenAIEmbeddingDimensions = 0 // OPENAI_EMBEDDING_DIMENSIONS
+
+	// Постачальник векторів: openai (типово) або ollama - локальний сервер Ollama
+	EmbeddingProvider    = envOrDefault("EMBEDDING_PROVIDER", "openai")
+	OllamaURL            = envOrDefault("OLLAMA_URL", "http://localhost:11434")
+	OllamaEmbeddingModel = envOrDefault("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text")
+	EmbeddingBatchSize   = 32 // EMBEDDING_BATCH_SIZE: скільки частин документа векторизується одним запитом
+
+	// Текст, довший за EMBEDDING_MAX_INPUT_TOKENS (ліміт моделі ембеддингів), не надсилається як є:
+	// truncate - обрізаємо до ліміту, average - векторизуємо шматками й усереднюємо, error - відмовляємо
+	EmbeddingMaxInputTokens = 8191 // EMBEDDING_MAX_INPUT_TOKENS
+	EmbeddingOversizeMode   = envOrDefault("EMBEDDING_OVERSIZE_MODE", "truncate")
+
+	// Що робити, якщо модель ембеддингів відрізняється від тієї, якою векторизовано індекс:
+	// refuse - не запускатися, reindex - векторизувати збережений текст заново у фоні
+	EmbeddingModelChange = envOrDefault("EMBEDDING_MODEL_CHANGE", "refuse")
+
+	// Моделі, між якими адміністратор може перемикатися через /model (OPENAI_MODEL додається завжди)
+	OpenAIModelAllowlist = splitList(envOrDefault("OPENAI_MODEL_ALLOWLIST", "gpt-4o,gpt-4o-mini"))
+
+	// Файл налаштувань, змінених під час роботи (наприклад, модель з /model)
+	SettingsPath = envOrDefault("SETTINGS_PATH", "settings.json")
+
+	// OpenAI-сумісний сервер (Ollama, LM Studio, vLLM) замість api.openai.com, якщо задано
+	OpenAIBaseURL = os.Getenv("OPENAI_BASE_URL")
+
+	// Числові налаштування, що зчитуються в loadConfig
+	PineconeTopK              = 5    // PINECONE_TOP_K: кількість релевантних записів для пошуку
+	OpenAITemperature float32 = 0.2  // OPENAI_TEMPERATURE: температура генерації відповіді
+	PineconeMinScore  float32 = 0.75 // PINECONE_MIN_SCORE: мінімальна оцінка релевантності збігу
+	MaxChunksPerFile          = 0    // MAX_CHUNKS_PER_FILE: скільки частин одного файлу може потрапити в контекст (0 - без обмеження)
+	SearchParallelism         = 4    // SEARCH_PARALLELISM: скільки пошуків у Pinecone (колекції, namespace) виконується одночасно для одного запиту
+	HybridSearchAlpha         = 1.0  // HYBRID_SEARCH_ALPHA: вага векторного пошуку, решта - пошук за ключовими словами (1 - лише векторний)
+
+	// Ліміти контексту моделі для відповіді
+	OpenAIContextTokens     = 128000 // OPENAI_CONTEXT_TOKENS: розмір вікна контексту моделі
+	OpenAICompletionReserve = 1024   // OPENAI_COMPLETION_RESERVE: токени, зарезервовані для відповіді
+	OpenAIMaxTokens         = 1024   // OPENAI_MAX_TOKENS: максимальна довжина відповіді в токенах
+
+	// OCR для відсканованих PDF (потрібні pdftoppm і tesseract)
+	OCREnabled      = os.Getenv("OCR_ENABLED") == "true"
+	OCRLanguages    = envOrDefault("OCR_LANGUAGES", "ukr+eng") // Мови tesseract
+	OCRMinTextChars = 100                                      // OCR_MIN_TEXT_CHARS: менше символів у текстовому шарі - пробуємо OCR
+
+	// Максимальний розмір документа, надісланого в Telegram (Bot API віддає ботам файли до 20 МБ)
+	MaxUploadBytes int64 = telegramBotAPIFileLimit // MAX_UPLOAD_BYTES
+
+	// Максимальний розмір веб-сторінки для /ingest у байтах
+	IngestMaxBytes int64 = 5 << 20 // INGEST_MAX_BYTES
+
+	// Розмір пакета векторів для одного запиту UpsertVectors
+	PineconeUpsertBatchSize = 100 // PINECONE_UPSERT_BATCH_SIZE
+
+	// Найбільший розмір метаданих одного вектора в байтах JSON (ліміт Pinecone - 40 КБ); довший текст частини обрізається
+	PineconeMaxMetadataBytes = pineconeMetadataLimit // PINECONE_MAX_METADATA_BYTES
+
+	// Скільки частин документів векторизується одночасно (спільно для всіх завантажень)
+	EmbeddingWorkers = 4 // EMBEDDING_WORKERS
+
+	// Стрімінг відповіді з поступовим редагуванням повідомлення
+	OpenAIStream       = os.Getenv("OPENAI_STREAM") != "false" // OPENAI_STREAM: вимкнути стрімінг значенням false
+	StreamEditInterval = time.Second                           // STREAM_EDIT_INTERVAL: мінімальний інтервал між редагуваннями
+
+	// Таймаути однієї спроби запиту до зовнішніх сервісів
+	OpenAIEmbeddingTimeout = 30 * time.Second  // OPENAI_EMBEDDING_TIMEOUT: векторизація
+	OpenAIChatTimeout      = 120 * time.Second // OPENAI_CHAT_TIMEOUT: відповідь GPT (разом зі стрімінгом) і розпізнавання голосу
+	PineconeTimeout        = 15 * time.Second  // PINECONE_TIMEOUT: пошук та інші запити до Pinecone
+	PineconeUpsertTimeout  = 30 * time.Second  // PINECONE_UPSERT_TIMEOUT: додавання пакета векторів у Pinecone
+	HTTPTimeout            = 60 * time.Second  // HTTP_TIMEOUT: завантаження файлів з Telegram, сторінок для /ingest та інші HTTP запити
+
+	// Вимикач Pinecone: після стількох невдалих запитів поспіль (0 - вимкнено) запити до Pinecone
+	// відхиляються одразу, доки не мине пауза, а потім один пробний запит перевіряє, чи сервіс відновився
+	PineconeBreakerThreshold = 5                // PINECONE_BREAKER_THRESHOLD
+	PineconeBreakerCooldown  = 30 * time.Second // PINECONE_BREAKER_COOLDOWN
+
+	// Переранжування збігів Pinecone моделлю OpenAI перед формуванням контексту
+	RerankEnabled = os.Getenv("RERANK_ENABLED") == "true"       // RERANK_ENABLED: увімкнути переранжування
+	RerankModel   = envOrDefault("RERANK_MODEL", "gpt-4o-mini") // Модель для оцінки релевантності
+	RerankTopN    = 3                                           // RERANK_TOP_N: скільки збігів залишити після переранжування
+
+	// Розширення запиту: модель формулює перефразування запиту, і пошук виконується за кожним із них
+	QueryExpansionEnabled = os.Getenv("QUERY_EXPANSION_ENABLED") == "true"       // QUERY_EXPANSION_ENABLED: увімкнути розширення
+	QueryExpansionModel   = envOrDefault("QUERY_EXPANSION_MODEL", "gpt-4o-mini") // Модель для перефразування
+	QueryExpansionCount   = 3                                                    // QUERY_EXPANSION_COUNT: скільки перефразувань генерувати (1-5)
+
+	// Пошук на вимогу моделі: GPT може викликати search_knowledge_base з уточненим запитом, якщо джерел замало.
+	// У цьому режимі відповідь не стрімиться, бо спершу треба дочекатися викликів інструмента
+	ToolSearchEnabled  = os.Getenv("TOOL_SEARCH_ENABLED") == "true" // TOOL_SEARCH_ENABLED: увімкнути виклики пошуку
+	ToolSearchMaxCalls = 3                                          // TOOL_SEARCH_MAX_CALLS: найбільше викликів на одне повідомлення (1-10)
+
+	// Кешування векторів запитів і (за ANSWER_CACHE_ENABLED=true) готових відповідей
+	QueryCacheSize     = 256                                         // QUERY_CACHE_SIZE: кількість записів у кожному кеші (0 - вимкнено)
+	QueryCacheTTL      = 10 * time.Minute                            // QUERY_CACHE_TTL: час життя записів
+	AnswerCacheEnabled = os.Getenv("ANSWER_CACHE_ENABLED") == "true" // Повторювати відповідь без генерації GPT
+
+	// Поведінка, коли в базі знань немає релевантних даних: refuse (типово) - повідомлення NO_CONTEXT_MESSAGE
+	// або каталогу, general - відповідь із загальних знань моделі із застереженням
+	NoContextMode    = envOrDefault("NO_CONTEXT_MODE", "refuse")
+	NoContextMessage = os.Getenv("NO_CONTEXT_MESSAGE") // Власний текст відмови замість типового
+
+	// Обмеження відповідей знайденими джерелами: off (типово) або strict - строга інструкція моделі
+	// та перевірка готової відповіді моделлю GUARDRAIL_MODEL; запитання поза джерелами отримують відмову
+	GuardrailMode  = envOrDefault("GUARDRAIL_MODE", "off")
+	GuardrailModel = envOrDefault("GUARDRAIL_MODEL", "gpt-4o-mini")
+
+	// Позначка впевненості під відповіддю (висока/середня/низька) за оцінками збігів: найкраща оцінка порівнюється
+	// з CONFIDENCE_HIGH_SCORE і CONFIDENCE_LOW_SCORE, а з ANSWER_CONFIDENCE_SELF_RATE модель ще й оцінює відповідь сама
+	AnswerConfidenceEnabled  = os.Getenv("ANSWER_CONFIDENCE_ENABLED") == "true"
+	AnswerConfidenceSelfRate = os.Getenv("ANSWER_CONFIDENCE_SELF_RATE") == "true"
+	AnswerConfidenceModel    = envOrDefault("ANSWER_CONFIDENCE_MODEL", "gpt-4o-mini")
+	ConfidenceHighScore      = 0.85 // CONFIDENCE_HIGH_SCORE
+	ConfidenceLowScore       = 0.78 // CONFIDENCE_LOW_SCORE
+
+	// Обрізання шаблонних вступів і завершень відповіді; шаблони - регулярні вирази без урахування регістру,
+	// які діють лише на самому початку (PREFIXES) чи в самому кінці (SUFFIXES) відповіді
+	AnswerStripEnabled  = os.Getenv("ANSWER_STRIP_ENABLED") == "true"
+	AnswerStripPrefixes = envOrDefault("ANSWER_STRIP_PREFIXES", defaultAnswerStripPrefixes)
+	AnswerStripSuffixes = envOrDefault("ANSWER_STRIP_SUFFIXES", defaultAnswerStripSuffixes)
+
+	// Коли бот відповідає на текст у групах: mention (типово) - лише на згадку @бота або відповідь на його повідомлення,
+	// all - на кожне повідомлення, off - ігнорує текст у групах. В особистих чатах бот відповідає завжди.
+	GroupTrigger = envOrDefault("GROUP_TRIGGER", "mention")
+
+	// Кнопки джерел під відповіддю: натискання показує текст фрагмента (SOURCE_BUTTONS=false - вимкнути)
+	SourceButtons = os.Getenv("SOURCE_BUTTONS") != "false"
+
+	// Мінімальна довжина запиту в символах після нормалізації пробілів
+	QueryMinLength = 3 // QUERY_MIN_LENGTH
+
+	// Повторні спроби для OpenAI та Pinecone
+	RetryCount     = 3                      // RETRY_COUNT: кількість повторів після першої спроби
+	RetryBaseDelay = 500 * time.Millisecond // RETRY_BASE_DELAY: початкова затримка між спробами
 )
 
 // Налаштування команди для Cobra
@@ -49,23 +266,87 @@ var aibotCmd = &cobra.Command{
 	Use:   "aibot",
 	Short: "Telegram бот з інтеграцією з Pinecone та GPT-4.",
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Printf("AI бот запущено! Версія: %s", appVersion)
+		if err := setupLogging(); err != nil {
+			fatal("Некоректні налаштування логування", "error", err)
+		}
+
+		slog.Info("AI бот запущено!", "version", appVersion)
 
 		// Перевіряємо змінні середовища
-		if TelegramToken == "" || OpenAIKey == "" || PineconeAPIKey == "" || PineconeEnv == "" || OpenAIModel == "" {
-			log.Fatalf("Відсутні необхідні змінні середовища.")
+		if TelegramToken == "" || OpenAIKey == "" || PineconeAPIKey == "" || PineconeEnv == "" || OpenAIModel == "" || OpenAIEmbeddingModel == "" {
+			fatal("Відсутні необхідні змінні середовища.")
+		}
+		if err := loadConfig(); err != nil {
+			fatal("Некоректні налаштування", "error", err)
+		}
+		registerMetrics()
+
+		// Одне підключення до Pinecone на весь час роботи бота
+		if err := initPinecone(); err != nil {
+			fatal("Не вдалося підключитися до Pinecone", "error", err)
+		}
+
+		// Модель ембеддингів має відповідати розмірності індексу
+		if err := validateEmbeddingDimension(pineconeConn.dimension); err != nil {
+			fatal("Некоректна модель ембеддингів", "error", err)
+		}
+
+		// Налаштування, змінені адміністратором під час попереднього запуску
+		if err := loadSettings(SettingsPath); err != nil {
+			fatal("Не вдалося завантажити налаштування", "error", err)
+		}
+
+		// Шаблони запиту до GPT (типові або задані оператором)
+		if err := loadPrompts(); err != nil {
+			fatal("Некоректний шаблон запиту", "error", err)
+		}
+
+		// Сесії користувачів з попереднього запуску
+		if err := initSessionStore(newFileSessionStore(SessionStorePath)); err != nil {
+			fatal("Не вдалося завантажити сесії користувачів", "error", err)
+		}
+
+		// Реєстр документів для /list та /delete
+		if err := loadRegistry(RegistryPath); err != nil {
+			fatal("Не вдалося завантажити реєстр документів", "error", err)
+		}
+
+		// Вектори, створені іншою моделлю ембеддингів, несумісні з поточною
+		if err := checkEmbeddingModel(); err != nil {
+			fatal("Модель ембеддингів змінилася", "error", err)
+		}
+
+		// Накопичені відгуки для /stats
+		if err := loadFeedbackStats(FeedbackPath); err != nil {
+			fatal("Не вдалося завантажити відгуки", "error", err)
+		}
+
+		// Long polling або webhook залежно від BOT_MODE
+		poller, err := newPoller()
+		if err != nil {
+			fatal("Некоректні налаштування отримання оновлень", "error", err)
 		}
 
 		// Ініціалізація Telegram-бота
 		aibot, err := telebot.NewBot(telebot.Settings{
 			Token:  TelegramToken,
-			Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+			Poller: poller,
 		})
 
 		if err != nil {
-			log.Fatalf("Не вдалося запустити бота: %v", err)
+			fatal("Не вдалося запустити бота", "error", err)
+		}
+
+		// Після роботи у режимі webhook Telegram не віддає оновлення через long polling
+		if BotMode == "polling" {
+			if err := aibot.RemoveWebhook(); err != nil {
+				slog.Warn("Не вдалося видалити webhook", "error", err)
+			}
 		}
 
+		// Кожне оновлення отримує ID запиту, який потрапляє в усі рядки логу його обробки
+		aibot.Use(requestIDMiddleware)
+
 		// Меню для бота
 		//menu := &telebot.ReplyMarkup{
 		//	ReplyKeyboard: [][]telebot.ReplyButton{
@@ -75,11 +356,12 @@ var aibotCmd = &cobra.Command{
 
 		// Обробка команди /start
 		// Форматуємо повідомлення перед відправкою у /start з використанням HTML
-		aibot.Handle("/start", func(m telebot.Context) error {
-			log.Printf("Користувач ID %d почав сесію.", m.Sender().ID)
+		handleCommand(aibot, "/start", "Про бота", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			slog.InfoContext(ctx, "Користувач почав сесію", "user_id", m.Sender().ID)
 
-			// Форматуємо повідомлення перед відправкою
-			msg := fmt.Sprintf("Цей чат-бот створений для надавання інформації про людину та її трудовий досвід. Версія чат-боту: %s", appVersion)
+			// Форматуємо повідомлення перед відправкою мовою користувача
+			msg := translate(userLanguage(m), "start", appVersion)
 
 			return m.Send(msg, &telebot.SendOptions{
 				ParseMode: telebot.ModeHTML, // Використовуємо HTML форматування
@@ -88,322 +370,1727 @@ var aibotCmd = &cobra.Command{
 
 		// Обробка текстових запитів
 		aibot.Handle(telebot.OnText, func(m telebot.Context) error {
-			userQuery := m.Text() // Текст запиту користувача
-			log.Printf("Запит користувача: %s", userQuery)
+			ctx := requestContext(m)
+
+			// У групах відповідаємо лише на звернення до бота (GROUP_TRIGGER), без самої згадки в запиті
+			userQuery, addressed := groupQuery(m) // Текст запиту користувача
+			if !addressed {
+				return nil
+			}
+			slog.InfoContext(ctx, "Запит користувача", "user_id", m.Sender().ID, "query_len", len([]rune(userQuery)))
+			slog.DebugContext(ctx, "Текст запиту користувача", "user_id", m.Sender().ID, "query", userQuery)
+
+			userQuery, problem := sanitizeQuery(userQuery)
+			if problem != "" {
+				slog.InfoContext(ctx, "Запит відхилено", "user_id", m.Sender().ID, "reason", problem)
+				if problem == "query_too_short" {
+					return m.Send(translate(userLanguage(m), problem, QueryMinLength))
+				}
+				return m.Send(translate(userLanguage(m), problem))
+			}
 
-			if userQuery == "" {
-				return m.Send("Будь ласка, введіть запит.")
+			// Після /upload чекаємо на файл, а не на запит
+			if isAwaitingDocument(m.Sender().ID) {
+				return m.Send(translate(userLanguage(m), "awaiting_document"))
 			}
 
-			// 1. Векторизуємо запит через OpenAI
-			queryEmbedding, err := getQueryEmbeddingFromOpenAI(userQuery)
-			if err != nil {
-				log.Printf("Помилка у OpenAI: %v", err)
-				return m.Send(fmt.Sprintf("Помилка у генерації вектору через OpenAI: %v", err))
+			if !allowRequest(m.Sender().ID) {
+				return m.Send(translate(userLanguage(m), "rate_limited"))
+			}
+
+			return answerQuery(m, userQuery)
+		})
+
+		// Натискання inline-кнопок під повідомленнями бота
+		aibot.Handle(telebot.OnCallback, func(c telebot.Context) error {
+			data := c.Callback().Data
+			if strings.HasPrefix(data, feedbackCallbackPrefix+"|") {
+				return handleFeedbackCallback(c, data)
+			}
+			if strings.HasPrefix(data, photoIndexCallbackPrefix+"|") {
+				return handlePhotoIndexCallback(c, data)
+			}
+			if strings.HasPrefix(data, sourceCallbackPrefix+"|") {
+				return handleSourceCallback(c, data)
+			}
+			return c.Respond()
+		})
+
+		// Inline-запити (@бот запит): швидкий пошук фрагментів без генерації відповіді
+		aibot.Handle(telebot.OnQuery, handleInlineQuery)
+
+		// Фото та альбоми: відповідаємо на підпис як на запитання щодо зображень
+		aibot.Handle(telebot.OnPhoto, handlePhoto)
+
+		// Голосові запити: розпізнаємо через Whisper і обробляємо як текстові
+		aibot.Handle(telebot.OnVoice, func(m telebot.Context) error {
+			// У групах розпізнаємо лише голосові, адресовані боту (GROUP_TRIGGER), щоб не платити за чужі розмови
+			if _, addressed := groupCaption(m); !addressed {
+				return nil
+			}
+
+			ctx := requestContext(m)
+			voice := m.Message().Voice
+			slog.InfoContext(ctx, "Голосовий запит користувача", "user_id", m.Sender().ID, "duration_s", voice.Duration)
+
+			if isAwaitingDocument(m.Sender().ID) {
+				return m.Send(translate(userLanguage(m), "awaiting_document"))
+			}
+
+			if !allowRequest(m.Sender().ID) {
+				return m.Send(translate(userLanguage(m), "rate_limited"))
 			}
 
-			// 2. Пошук у Pinecone
-			matches, err := searchPinecone(queryEmbedding)
-			if err != nil || len(matches.Matches) == 0 {
-				log.Printf("Pinecone не повернув релевантної інформації або виникла проблема із запитом: %v", err)
-				return m.Send("Не знайдено релевантних збігів у Pinecone.")
+			stopTyping := startTyping(m)
+			audio, err := downloadTelegramFile(aibot, voice.FileID)
+			if err != nil {
+				stopTyping()
+				slog.ErrorContext(ctx, "Помилка завантаження голосового повідомлення", "user_id", m.Sender().ID, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "voice_download_error", err)))
 			}
 
-			// 3. Генерація відповіді GPT-4 із обмеженим контекстом
-			answer, err := generateFinalAnswerFromOpenAI(userQuery, matches)
+			transcript, err := transcribeVoice(ctx, audio)
+			stopTyping()
 			if err != nil {
-				log.Printf("Помилка під час спроби згенерувати відповідь через GPT-4: %v", err)
-				return m.Send(fmt.Sprintf("GPT-4 не зміг згенерувати відповідь: %v", err))
+				slog.ErrorContext(ctx, "Помилка розпізнавання голосу", "user_id", m.Sender().ID, "error", err)
+				if isTimeoutError(err) {
+					return m.Send(translate(userLanguage(m), "timeout"))
+				}
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "voice_error", userFacingError(err))))
 			}
+			if transcript == "" {
+				return m.Send(translate(userLanguage(m), "voice_empty"))
+			}
+
+			slog.DebugContext(ctx, "Розпізнаний голосовий запит", "user_id", m.Sender().ID, "query", transcript)
 
-			log.Printf("Повернена відповідь від ChatGPT: %s", answer)
+			// Показуємо розпізнаний текст, щоб користувач міг перевірити, чи його правильно почули
+			if err := m.Send(translate(userLanguage(m), "voice_transcript", transcript)); err != nil {
+				return err
+			}
 
-			// Повернення результату користувачеві
-			return m.Send(fmt.Sprintf("%s", answer))
+			return answerQuery(m, transcript)
 		})
 
 		aibot.Handle(telebot.OnDocument, func(m telebot.Context) error {
+			// У групах додаємо лише документи, адресовані боту або очікувані після /upload, а не всі файли учасників
+			if !groupUploadAddressed(m) {
+				return nil
+			}
+			caption, _ := groupCaption(m)
+
+			ctx := requestContext(m)
 			file := m.Message().Document
+			uploadsHandled.Add(1)
+			updating := isAwaitingUpdate(m.Sender().ID)
+			setAwaitingDocument(m.Sender().ID, false)
+
+			// Підпис "preview" показує, як документ буде розбито, нічого не додаючи в базу
+			if isPreviewCaption(caption) {
+				ctx = contextWithPreview(ctx)
+				m.Set(requestContextKey, ctx)
+			} else if updating || isUpdateCaption(caption) {
+				// Після /update або з підписом "update" нова версія замінює вектори попередньої
+				ctx = contextWithUpdate(ctx)
+				m.Set(requestContextKey, ctx)
+			}
+
+			// Завеликі файли відхиляємо ще до завантаження; формат визначаємо за вмістом після нього
+			if file.FileSize > MaxUploadBytes {
+				slog.WarnContext(ctx, "Файл перевищує ліміт розміру", "user_id", m.Sender().ID, "file", file.FileName, "size", file.FileSize, "limit", MaxUploadBytes)
+				return m.Send(fileTooLargeMessage(userLanguage(m), file.FileSize))
+			}
+			if message, ok := checkUploadQuota(userLanguage(m), m.Sender().ID, file.FileName); !ok {
+				slog.WarnContext(ctx, "Квоту користувача вичерпано", "user_id", m.Sender().ID, "file", file.FileName)
+				return m.Send(message)
+			}
+
+			// Документи одного користувача обробляються по черзі (USER_MAX_CONCURRENT_UPLOADS)
+			release, err := acquireUploadSlot(ctx, m.Sender().ID, func(ahead int) {
+				slog.InfoContext(ctx, "Завантаження поставлено в чергу", "user_id", m.Sender().ID, "file", file.FileName, "ahead", ahead)
+				if err := m.Send(translate(userLanguage(m), "upload_queued", file.FileName, ahead)); err != nil {
+					slog.WarnContext(ctx, "Не вдалося повідомити про чергу завантажень", "error", err)
+				}
+			})
+			if errors.Is(err, context.Canceled) {
+				return m.Send(translate(userLanguage(m), "upload_cancelled_file", file.FileName))
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "Завантаження не прийнято", "user_id", m.Sender().ID, "file", file.FileName, "error", err)
+				return m.Send(translate(userLanguage(m), "upload_rejected", file.FileName, err))
+			}
+			defer release()
+
+			// Завантажуємо файл у тимчасовий файл на диску, а не в пам'ять
+			tmpFile, size, err := downloadTelegramFileToTemp(aibot, file.FileID, MaxUploadBytes)
+			if errors.Is(err, errFileTooLarge) {
+				return m.Send(fileTooLargeMessage(userLanguage(m), size))
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Помилка завантаження файлу", "user_id", m.Sender().ID, "file", file.FileName, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "file_download_error", err)))
+			}
+			defer removeTempFile(tmpFile)
 
-			// Завантажуємо файл
-			fileBytes, err := downloadTelegramFile(aibot, file.FileID)
+			// Тип визначаємо за вмістом: .pdf може виявитися JSON, а файл без розширення - текстом
+			docType, err := detectDocumentType(tmpFile, size, file.FileName)
 			if err != nil {
-				log.Printf("Помилка завантаження файлу: %v", err)
-				return m.Send(fmt.Sprintf("Помилка завантаження файлу: %v", err))
+				slog.ErrorContext(ctx, "Помилка читання файлу", "user_id", m.Sender().ID, "file", file.FileName, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "file_read_error", err)))
+			}
+			recordUploadMetric(docType)
+			if docType == "" {
+				slog.WarnContext(ctx, "Непідтримуваний формат файлу", "user_id", m.Sender().ID, "file", file.FileName)
+				return m.Send(translate(userLanguage(m), "unknown_format"))
+			}
+			m.Set(documentTypeKey, docType)
+			if docType != documentType(file.FileName) {
+				slog.InfoContext(ctx, "Тип файлу визначено за вмістом", "user_id", m.Sender().ID, "file", file.FileName, "type", docType)
+				if err := m.Send(translate(userLanguage(m), "type_detected", file.FileName, documentTypeLabels[docType])); err != nil {
+					return err
+				}
 			}
 
-			// Визначаємо тип файлу (PDF або JSON)
-			if isPDF(file.FileName) {
-				return processAndUploadPDF(fileBytes, file.FileName, m) // Обробка PDF
-			} else if isJSON(file.FileName) {
+			// PDF і DOCX читаються з диска частинами, решта форматів розбирається цілком у пам'яті
+			switch docType {
+			case "pdf":
+				return processAndUploadPDF(tmpFile, size, file.FileName, m) // Обробка PDF
+			case "docx":
+				return processAndUploadDocx(tmpFile, size, file.FileName, m) // Обробка DOCX
+			}
+
+			fileBytes, err := io.ReadAll(tmpFile)
+			if err != nil {
+				slog.ErrorContext(ctx, "Помилка читання файлу", "user_id", m.Sender().ID, "file", file.FileName, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "file_read_error", err)))
+			}
+			switch docType {
+			case "json":
 				return processAndUploadJSON(fileBytes, file.FileName, m) // Обробка JSON
+			case "csv":
+				return processAndUploadCSV(fileBytes, file.FileName, m) // Обробка CSV
+			}
+			return processAndUploadText(fileBytes, file.FileName, m) // Обробка TXT та Markdown
+		})
+
+		// Очікування документа: наступне повідомлення має бути файлом
+		handleCommand(aibot, "/upload", "Завантажити документ у базу знань", func(m telebot.Context) error {
+			setAwaitingDocument(m.Sender().ID, true)
+			return m.Send(translate(userLanguage(m), "upload_prompt"))
+		})
+
+		// Оновлення вже завантаженого документа: наступний файл замінить його попередню версію
+		handleCommand(aibot, "/update", "Оновити вже завантажений документ", handleUpdate)
+
+		// Скасування очікування документа та завантажень, що ще тривають
+		handleCommand(aibot, "/cancel", "Скасувати очікування документа або завантаження", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			awaiting := isAwaitingDocument(m.Sender().ID)
+			setAwaitingDocument(m.Sender().ID, false)
+			cancelled := cancelOperations(m.Sender().ID)
+			slog.InfoContext(ctx, "Користувач скасував операції", "user_id", m.Sender().ID, "awaiting_document", awaiting, "cancelled", cancelled)
+
+			if !awaiting && cancelled == 0 {
+				return m.Send(translate(userLanguage(m), "cancel_nothing"))
+			}
+			if cancelled > 0 {
+				return m.Send(translate(userLanguage(m), "cancel_uploads", cancelled))
+			}
+			return m.Send(translate(userLanguage(m), "cancel_awaiting"))
+		})
+
+		// Індексація веб-сторінки: /ingest <url>
+		handleCommand(aibot, "/ingest", "Додати веб-сторінку: /ingest <url>", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			rawURL := strings.TrimSpace(m.Message().Payload)
+			if rawURL == "" {
+				return m.Send(translate(userLanguage(m), "ingest_usage"))
+			}
+
+			if !allowRequest(m.Sender().ID) {
+				return m.Send(translate(userLanguage(m), "rate_limited"))
+			}
+			if message, ok := checkUploadQuota(userLanguage(m), m.Sender().ID, rawURL); !ok {
+				slog.WarnContext(ctx, "Квоту користувача вичерпано", "user_id", m.Sender().ID, "url", rawURL)
+				return m.Send(message)
+			}
+
+			uploadsHandled.Add(1)
+			recordUploadMetric("web")
+			slog.InfoContext(ctx, "Користувач індексує сторінку", "user_id", m.Sender().ID, "url", rawURL)
+
+			return processAndUploadURL(rawURL, m)
+		})
+
+		// Видалення документа з векторної бази: /delete <файл>
+		handleCommand(aibot, "/delete", "Видалити документ: /delete <файл>", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			fileName := strings.TrimSpace(m.Message().Payload)
+			if fileName == "" {
+				return m.Send(translate(userLanguage(m), "delete_usage"))
+			}
+
+			slog.InfoContext(ctx, "Користувач видаляє файл", "user_id", m.Sender().ID, "file", fileName)
+
+			deleted, err := deleteVectorsByFile(userNamespace(m.Sender().ID), fileName)
+			if deleted > 0 {
+				invalidateAnswerCache(userNamespace(m.Sender().ID))
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Помилка видалення файлу", "user_id", m.Sender().ID, "file", fileName, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "delete_error", err)))
+			}
+			if deleted == 0 {
+				return m.Send(translate(userLanguage(m), "delete_not_found", fileName))
+			}
+
+			return m.Send(translate(userLanguage(m), "delete_done", fileName, deleted))
+		})
+
+		// Стислий підсумок завантаженого документа: /summarize <файл>
+		handleCommand(aibot, "/summarize", "Стислий підсумок документа: /summarize <файл>", handleSummarize)
+
+		// Статистика використання, лише для адміністраторів
+		handleAdminCommand(aibot, "/stats", "Статистика використання", func(m telebot.Context) error {
+			return sendLongMessage(m, buildStatsReport())
+		})
+
+		// Перегляд і зміна активної моделі GPT, лише для адміністраторів
+		handleAdminCommand(aibot, "/model", "Перегляд і зміна моделі GPT", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			model := strings.TrimSpace(m.Message().Payload)
+			if model == "" {
+				return m.Send(translate(userLanguage(m), "model_current", currentModel(), strings.Join(OpenAIModelAllowlist, ", ")))
+			}
+
+			if err := setModel(model); err != nil {
+				slog.WarnContext(ctx, "Не вдалося змінити модель", "user_id", m.Sender().ID, "model", model, "error", err)
+				return m.Send(translate(userLanguage(m), "model_invalid", err, strings.Join(OpenAIModelAllowlist, ", ")))
+			}
+
+			slog.InfoContext(ctx, "Адміністратор змінив модель", "user_id", m.Sender().ID, "model", model)
+			return m.Send(translate(userLanguage(m), "model_changed", model))
+		})
+
+		// Сирі збіги Pinecone для запиту без генерації відповіді, лише для адміністраторів
+		handleAdminCommand(aibot, "/debug", "Збіги Pinecone для запиту без відповіді", handleDebug)
+
+		// Пошук лише за ключовими словами: потрібні розріджені вектори, які є лише з гібридним пошуком
+		if hybridSearchEnabled() {
+			handleCommand(aibot, "/keyword", "Пошук за ключовими словами: /keyword <слова>", handleKeywordSearch)
+		}
+
+		// Перелік завантажених документів
+		handleCommand(aibot, "/list", "Перелік завантажених документів", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			namespace := userNamespace(m.Sender().ID)
+			vectorCount, err := namespaceVectorCount(namespace)
+			if err != nil {
+				slog.ErrorContext(ctx, "Помилка отримання статистики індексу", "user_id", m.Sender().ID, "error", err)
+				return m.Send(withRequestID(ctx, translate(userLanguage(m), "index_stats_error", err)))
+			}
+
+			lang := userLanguage(m)
+			entries := listRegistry(namespace)
+			if len(entries) == 0 {
+				return m.Send(translate(lang, "list_empty", vectorCount))
+			}
+
+			var sb strings.Builder
+			sb.WriteString(translate(lang, "list_header", len(entries), vectorCount))
+			for _, entry := range entries {
+				sb.WriteString(translate(lang, "list_entry", entry.File, entry.Chunks))
+			}
+
+			return sendLongMessage(m, sb.String())
+		})
+
+		// Вибір колекції для пошуку та завантажень: /collection <назва> або /collection all
+		handleCommand(aibot, "/collection", "Вибір колекції для пошуку", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			lang := userLanguage(m)
+			name := strings.TrimSpace(m.Message().Payload)
+			if name == "" {
+				selected := sessionCollection(m.Sender().ID)
+				current := translate(lang, "collection_all_label")
+				if isKnownCollection(selected) {
+					current = selected
+				}
+				return m.Send(translate(lang, "collection_status", current, describeCollections(lang, selected)))
+			}
+
+			if name == allCollections {
+				setSessionCollection(m.Sender().ID, "")
+				slog.InfoContext(ctx, "Користувач обрав пошук у всіх колекціях", "user_id", m.Sender().ID)
+				return m.Send(translate(lang, "collection_all"))
 			}
+			if !isKnownCollection(name) {
+				return m.Send(translate(lang, "collection_unknown", name, strings.Join(collectionNames(), ", ")))
+			}
+
+			setSessionCollection(m.Sender().ID, name)
+			slog.InfoContext(ctx, "Користувач обрав колекцію", "user_id", m.Sender().ID, "collection", name)
+			return m.Send(translate(lang, "collection_selected", name))
+		})
+
+		// Налаштування пошуку користувача: /set topk 8, /set threshold 0.7
+		handleCommand(aibot, "/set", "Налаштування пошуку: /set topk|threshold <значення>", handleSet)
 
-			return m.Send("Невідомий формат файлу. Завантажте, будь ласка, тільки PDF або JSON.")
+		// Стан сесії користувача: ID, права, історія та куди спрямовані запити
+		handleCommand(aibot, "/whoami", "Стан вашої сесії", func(m telebot.Context) error {
+			return m.Send(whoamiReport(userLanguage(m), m.Sender().ID))
 		})
 
+		// Очищення історії розмови, щоб почати спілкування з чистого аркуша
+		handleCommand(aibot, "/reset", "Очистити історію розмови", func(m telebot.Context) error {
+			ctx := requestContext(m)
+			resetSession(m.Sender().ID)
+			slog.InfoContext(ctx, "Користувач очистив історію розмови", "user_id", m.Sender().ID)
+
+			return m.Send(translate(userLanguage(m), "reset_done"))
+		})
+
+		// Перелік команд з описами, зібраний з усіх зареєстрованих вище
+		handleCommand(aibot, "/help", "Перелік команд", handleHelp)
+		registerBotCommands(aibot)
+
+		// Проби готовності для оркестратора
+		healthServer := startHealthServer(HealthListen)
+
+		// Коректна зупинка за сигналом: aibot.Stop() також зупиняє HTTP сервер webhook
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			<-signals
+
+			slog.Info("Отримано сигнал завершення, зупиняємо бота")
+			aibot.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := healthServer.Shutdown(ctx); err != nil {
+				slog.Warn("Помилка зупинки HTTP сервера перевірки стану", "error", err)
+			}
+		}()
+
 		// Старт бота
+		slog.Info("Бот успішно стартував!", "mode", BotMode)
+
 		aibot.Start()
 
-		log.Printf("Бот успішно стартував!")
-	},
-}
+		// Даємо завершитися завантаженням, що вже обробляються, а черга більше не приймає нових
+		drainUploads(uploadDrainTimeout)
 
-//Функції для завантаження та векторизації
+		// Зберігаємо зміни сесій, що ще чекають відкладеного запису
+		flushSessions()
 
-// Перевірка, чи є файл PDF
-func isPDF(fileName string) bool {
-	return len(fileName) > 4 && fileName[len(fileName)-4:] == ".pdf"
+		slog.Info("Бот зупинено")
+	},
 }
 
-// Перевірка, чи є файл JSON
-func isJSON(fileName string) bool {
-	return len(fileName) > 5 && fileName[len(fileName)-5:] == ".json"
-}
+// Повний цикл відповіді на запит: векторизація, пошук у Pinecone та генерація відповіді
+func answerQuery(m telebot.Context, userQuery string) error {
+	ctx := requestContext(m)
+	queriesHandled.Add(1)
+	metricQueries.Inc()
+	started := time.Now()
+	defer func() { metricQueryDuration.Observe(time.Since(started).Seconds()) }()
+
+	// Показуємо "друкує…" до завершення обробки запиту
+	stopTyping := startTyping(m)
+	defer stopTyping()
+
+	// Відповідь залежить від namespace, моделі та запиту разом із фільтрами. Кеш використовуємо лише
+	// без історії розмови, бо уточнююче запитання з тим самим текстом має інший зміст.
+	namespace := userNamespace(m.Sender().ID)
+	history := getSessionHistory(m.Sender().ID)
+	topK, minScore := userSearchSettings(m.Sender().ID)
+	ctx = contextWithTopK(ctx, topK)
+	cacheKey := answerCacheKey(namespace, sessionCollection(m.Sender().ID), currentModel(), fmt.Sprintf("%d/%.2f", topK, minScore), userQuery)
+	if len(history) == 0 {
+		if answer, ok := answerCache.Get(cacheKey); ok {
+			answerCacheHits.Add(1)
+			slog.InfoContext(ctx, "Відповідь з кешу", "user_id", m.Sender().ID, "answer_len", len([]rune(answer)), "latency_ms", time.Since(started).Milliseconds())
+			appendSessionHistory(m.Sender().ID, userQuery, answer)
+			return newStreamingMessage(m).FinishMarkdown(answer, feedbackMarkup(m.Sender().ID, userQuery, answer, nil, currentModel()))
+		}
+	}
+
+	// Повідомлення показуємо мовою запиту
+	lang := replyLanguage(m, userQuery)
 
-// Завантажуємо файл з Telegram
-func downloadTelegramFile(bot *telebot.Bot, fileID string) ([]byte, error) {
-	file, err := bot.FileByID(fileID)
+	// Префікси на кшталт file:resume.pdf обмежують пошук окремими документами
+	conditions, userQuery := parseQueryFilter(userQuery)
+	if userQuery == "" {
+		return m.Send(translate(lang, "filter_needs_query"))
+	}
+	filter, err := buildMetadataFilter(conditions)
 	if err != nil {
-		return nil, err
+		slog.ErrorContext(ctx, "Помилка фільтра запиту", "user_id", m.Sender().ID, "error", err)
+		return m.Send(translate(lang, "filter_invalid", err))
 	}
 
-	resp, err := http.Get(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", TelegramToken, file.FilePath))
+	// 1. Векторизуємо запит
+	queryEmbedding, err := embedQuery(ctx, userQuery)
 	if err != nil {
-		return nil, err
+		slog.ErrorContext(ctx, "Помилка векторизації запиту", "user_id", m.Sender().ID, "stage", "embedding", "error", err)
+		metricErrors.WithLabelValues("embedding").Inc()
+		if isTimeoutError(err) {
+			return m.Send(withRequestID(ctx, translate(lang, "timeout")))
+		}
+		if message, ok := openAIErrorMessage(lang, err); ok {
+			return m.Send(withRequestID(ctx, message))
+		}
+		return m.Send(withRequestID(ctx, translate(lang, "embedding_error", err)))
 	}
-	defer resp.Body.Close()
 
-	return io.ReadAll(resp.Body)
-}
+	// 2. Пошук у Pinecone (з QUERY_EXPANSION_ENABLED - ще й за перефразуваннями запиту)
+	namespaces := searchNamespaces(m.Sender().ID)
+	matches, err := searchWithExpansion(ctx, searchCollectionNames(m.Sender().ID), namespaces, userQuery, queryEmbedding, minScore, filter)
+	if err != nil || len(matches.Matches) == 0 {
+		slog.WarnContext(ctx, "Pinecone не повернув релевантної інформації або виникла проблема із запитом", "user_id", m.Sender().ID, "stage", "search", "error", err)
+		if err != nil {
+			metricErrors.WithLabelValues("search").Inc()
+		}
 
-// Обробка та індексація PDF файлів
-func processAndUploadPDF(fileBytes []byte, fileName string, m telebot.Context) error {
-	// 1. Витягуємо текст з PDF файлу
-	text, err := extractTextFromPDF(fileBytes)
-	if err != nil {
-		log.Printf("Помилка обробки PDF: %v", err)
-		return m.Send("Помилка обробки PDF файла.")
+		if isTimeoutError(err) {
+			return m.Send(withRequestID(ctx, translate(lang, "timeout")))
+		}
+		if errors.Is(err, errCircuitOpen) {
+			return m.Send(withRequestID(ctx, translate(lang, "kb_unavailable")))
+		}
+
+		// Порожня база знань - окрема ситуація, а не просто невдалий запит
+		if err == nil {
+			if count, statsErr := namespacesVectorCount(namespaces); statsErr == nil && count == 0 {
+				return m.Send(translate(lang, "kb_empty"))
+			}
+		}
+		if err != nil || NoContextMode != "general" {
+			return m.Send(noContextMessage(lang))
+		}
+
+		// NO_CONTEXT_MODE=general: відповідаємо із загальних знань моделі, попередивши про це
+		slog.InfoContext(ctx, "Відповідь без знайдених джерел", "user_id", m.Sender().ID)
+		matches = &pinecone.QueryVectorsResponse{}
 	}
 
-	// 2. Векторизуємо текст через OpenAI API
-	queryEmbedding, err := getQueryEmbeddingFromOpenAI(text)
-	if err != nil {
-		log.Printf("Помилка векторизації PDF: %v", err)
-		return m.Send("Помилка векторизації тексту з PDF.")
+	// Уточнюємо порядок збігів і залишаємо найрелевантніші (RERANK_ENABLED)
+	matches = rerankMatches(ctx, userQuery, matches)
+
+	// Додатковий пошук, який може запросити модель (TOOL_SEARCH_ENABLED), у тих самих колекціях і з тими самими фільтрами
+	search := func(ctx context.Context, query string) (*pinecone.QueryVectorsResponse, error) {
+		embedding, err := embedQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return searchCollections(contextWithKeywords(ctx, query), searchCollectionNames(m.Sender().ID), namespaces, embedding, minScore, filter)
 	}
 
-	// 3. Додаємо вектор у Pinecone
-	err = upsertVectorToPinecone(queryEmbedding, map[string]interface{}{
-		"file": fileName, "text": text,
-	})
+	// Відповідь без джерел завжди починається із застереження, зокрема й під час стрімінгу
+	disclaimer := ""
+	if len(matches.Matches) == 0 {
+		disclaimer = translate(lang, "general_knowledge") + "\n\n"
+	}
+
+	// 3. Генерація відповіді GPT-4 із обмеженим контекстом та історією розмови
+	// Відповідь показується поступово, редагуючи одне повідомлення. У строгому режимі
+	// не показуємо відповідь до перевірки, тож стрімінг вимкнено.
+	stream := newStreamingMessage(m)
+	var onProgress func(string)
+	if GuardrailMode != "strict" {
+		onProgress = func(text string) { stream.Update(disclaimer + stripAnswerBoilerplate(text)) }
+	}
+	answer, truncated, err := generateFinalAnswerFromOpenAI(ctx, userQuery, matches, history, onProgress, search)
 	if err != nil {
-		log.Printf("Помилка додавання в Pinecone: %v", err)
-		return m.Send("Помилка завантаження даних у Pinecone.")
+		slog.ErrorContext(ctx, "Помилка під час спроби згенерувати відповідь через GPT-4", "user_id", m.Sender().ID, "stage", "generation", "error", err)
+		metricErrors.WithLabelValues("generation").Inc()
+		if isTimeoutError(err) {
+			return m.Send(withRequestID(ctx, translate(lang, "timeout")))
+		}
+		if message, ok := openAIErrorMessage(lang, err); ok {
+			return m.Send(withRequestID(ctx, message))
+		}
+		return m.Send(withRequestID(ctx, translate(lang, "generation_error", err)))
 	}
 
-	return m.Send("PDF успішно завантажено та додано до векторної бази.")
-}
+	slog.InfoContext(ctx, "Повернена відповідь від ChatGPT", "user_id", m.Sender().ID, "match_count", len(matches.Matches),
+		"answer_len", len([]rune(answer)), "latency_ms", time.Since(started).Milliseconds())
+	slog.DebugContext(ctx, "Текст відповіді", "user_id", m.Sender().ID, "answer", answer)
 
-// Обробка та індексація JSON файлів
-func processAndUploadJSON(fileBytes []byte, fileName string, m telebot.Context) error {
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(fileBytes, &jsonData); err != nil {
-		log.Printf("Помилка обробки JSON: %v", err)
-		return m.Send("Помилка обробки JSON файла.")
+	// GUARDRAIL_MODE=strict: відповідь, не підкріплену джерелами, замінюємо відмовою
+	if refusal, grounded := guardrailAnswer(ctx, lang, userQuery, answer, matches); !grounded {
+		slog.InfoContext(ctx, "Відповідь відхилено: вона не базується на джерелах", "user_id", m.Sender().ID)
+		return stream.Finish(refusal)
 	}
 
-	// Якщо є текст або інша інформація, яку потрібно векторизувати, векторизуємо її
-	if text, ok := jsonData["text"].(string); ok {
-		queryEmbedding, err := getQueryEmbeddingFromOpenAI(text)
-		if err != nil {
-			log.Printf("Помилка векторизації JSON: %v", err)
-			return m.Send("Помилка векторизації тексту з JSON.")
-		}
+	answer = stripAnswerBoilerplate(answer)
 
-		// Додаємо вектори з метаданими JSON у Pinecone
-		err = upsertVectorToPinecone(queryEmbedding, jsonData)
-		if err != nil {
-			log.Printf("Помилка збереження в Pinecone з JSON: %v", err)
-			return m.Send("Помилка завантаження даних з JSON у Pinecone.")
-		}
+	// ANSWER_CONFIDENCE_ENABLED: позначка впевненості, а за низької - ще й застереження перед відповіддю
+	if AnswerConfidenceEnabled && len(matches.Matches) > 0 {
+		answer = withConfidence(lang, answerConfidence(ctx, userQuery, answer, matches), answer)
+	}
+	answer = disclaimer + answer
+
+	// Запам'ятовуємо репліку для наступних уточнюючих запитань
+	appendSessionHistory(m.Sender().ID, userQuery, answer)
+
+	// Обрізану відповідь не кешуємо, щоб не повторювати її неповною
+	if len(history) == 0 && !truncated {
+		answerCache.Add(cacheKey, answer)
+	}
 
-		return m.Send("JSON успішно завантажено та додано до векторної бази.")
+	// Примітку про обрізану відповідь показуємо користувачу, але не зберігаємо в історії
+	if truncated {
+		slog.WarnContext(ctx, "Відповідь обрізано через ліміт токенів", "user_id", m.Sender().ID, "max_tokens", OpenAIMaxTokens)
+		answer += "\n\n" + translate(lang, "answer_truncated")
 	}
 
-	return m.Send("JSON не містить текстових даних для векторизації.")
+	// Повернення результату користувачеві, розбитого на повідомлення до 4096 символів
+	// Кнопки відгуку зберігають запит, відповідь і знайдені вектори для аналізу якості, а кнопки джерел показують їхній текст
+	vectorIDs := make([]string, 0, len(matches.Matches))
+	for _, match := range matches.Matches {
+		vectorIDs = append(vectorIDs, match.Vector.Id)
+	}
+	markup := withSourceButtons(lang, feedbackMarkup(m.Sender().ID, userQuery, answer, vectorIDs, currentModel()), m.Sender().ID, matches)
+	return stream.FinishMarkdown(answer, markup)
 }
 
-// Витягуємо текст з PDF
-func extractTextFromPDF(fileBytes []byte) (string, error) {
-	// Реалізуйте ваше витягування тексту з PDF тут
-	// Можна використовувати сторонні бібліотеки для роботи з PDF, як pdfcpu або unidoc
-	return "Text from PDF", nil
+//Функції для завантаження та векторизації
+
+// Перевірка, чи є файл документом Word
+func isDocx(fileName string) bool {
+	return strings.HasSuffix(strings.ToLower(fileName), ".docx")
 }
 
-// Додавання вектора до Pinecone з метаданими
-func upsertVectorToPinecone(embedding []float32, metadata map[string]interface{}) error {
-	clientParams := pinecone.NewClientParams{
-		ApiKey: PineconeAPIKey,
-	}
-	client, err := pinecone.NewClient(clientParams)
-	if err != nil {
-		return fmt.Errorf("Помилка створення Pinecone клієнта: %v", err)
-	}
+// Перевірка, чи є файл таблицею CSV
+func isCSV(fileName string) bool {
+	return strings.HasSuffix(strings.ToLower(fileName), ".csv")
+}
 
-	// Деталі індексу
-	indexDesc, err := client.DescribeIndex(context.Background(), PineconeIndex)
-	if err != nil {
-		return fmt.Errorf("Помилка опису індексу Pinecone: %v", err)
-	}
+// Перевірка, чи є файл Markdown
+func isMarkdown(fileName string) bool {
+	lower := strings.ToLower(fileName)
+	return strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown")
+}
+
+// Обробка та індексація PDF файлів
+func processAndUploadPDF(file *os.File, size int64, fileName string, m telebot.Context) error {
+	// Хід індексації показуємо одним повідомленням, яке наприкінці замінюється підсумком
+	status := newUploadStatus(m)
+
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
 
-	// Підключаємося до індексу
-	indexConnection, err := client.Index(pinecone.NewIndexConnParams{Host: indexDesc.Host})
+	// 1. Витягуємо текст з PDF файлу (для сканів - через OCR, якщо його ввімкнено)
+	status.Stage("stage_pdf")
+	text, usedOCR, err := extractPDFTextWithOCR(file, size, status)
 	if err != nil {
-		return fmt.Errorf("Помилка підключення до індексу: %v", err)
+		slog.ErrorContext(ctx, "Помилка обробки PDF", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "pdf_error", err)))
 	}
 
-	// Метадані векторів у форматі JSON
-	metadataStruct, err := structpb.NewStruct(metadata)
-	if err != nil {
-		return fmt.Errorf("Помилка перетворення метаданих: %v", err)
+	// Назва, автор, дата створення та кількість сторінок з метаданих PDF, якщо вони є
+	metadata := uploadMetadata(m, documentContentType(m, fileName))
+	for key, value := range readPDFInfo(file, size) {
+		metadata[key] = value
 	}
 
-	// Додаємо вектори і метадані в Pinecone
-	_, err = indexConnection.UpsertVectors(context.Background(), []*pinecone.Vector{
-		{
-			Id:       fmt.Sprintf("doc-%d", time.Now().Unix()), // Унікальний ID для документа
-			Values:   embedding,                                // Вектор з OpenAI
-			Metadata: metadataStruct,                           // Метадані
-		},
-	})
+	// 2. Розбиваємо текст на частини, векторизуємо та додаємо у Pinecone
+	result, err := chunkAndUpsert(ctx, uploadCollection(m.Sender().ID, uploadDocumentType(m, fileName)), userNamespace(m.Sender().ID), fileName, text, metadata, status)
 	if err != nil {
-		return fmt.Errorf("Запит UpsertVectors не вдався: %v", err)
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації PDF", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "pdf_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
 	}
 
-	return nil
+	if usedOCR {
+		return status.Finish(result.message(status.lang, "PDF") + translate(status.lang, "pdf_ocr_note"))
+	}
+	return status.Finish(result.message(status.lang, "PDF"))
 }
 
-// Отримуємо ембеддинг через OpenAI з використанням 'text-embedding-ada-002'
-func getQueryEmbeddingFromOpenAI(query string) ([]float32, error) {
-	client := openai.NewClient(OpenAIKey)
+// Обробка та індексація DOCX файлів
+func processAndUploadDocx(file *os.File, size int64, fileName string, m telebot.Context) error {
+	status := newUploadStatus(m)
 
-	embeddingReq := openai.EmbeddingRequest{
-		Model: "text-embedding-ada-002", // Чітко вказуємо модель для векторизації
-		Input: []string{query},
-	}
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
 
-	resp, err := client.CreateEmbeddings(context.Background(), embeddingReq)
+	status.Stage("stage_docx")
+	text, err := extractTextFromDocx(file, size)
 	if err != nil {
-		return nil, fmt.Errorf("Помилка створення ембеддингів через OpenAI: %v", err)
+		slog.ErrorContext(ctx, "Помилка обробки DOCX", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "docx_error", err)))
 	}
 
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("OpenAI не повернув векторів.")
+	result, err := chunkAndUpsert(ctx, uploadCollection(m.Sender().ID, uploadDocumentType(m, fileName)), userNamespace(m.Sender().ID), fileName, text, uploadMetadata(m, documentContentType(m, fileName)), status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації DOCX", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "docx_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
 	}
 
-	log.Printf("API OpenAI успішно згенерував вектор для запиту: %s", query)
-
-	return resp.Data[0].Embedding, nil
+	return status.Finish(result.message(status.lang, "DOCX"))
 }
 
-// Виконуємо пошук у Pinecone за релевантними даними для запиту
-func searchPinecone(embedding []float32) (*pinecone.QueryVectorsResponse, error) {
-	clientParams := pinecone.NewClientParams{
-		ApiKey: PineconeAPIKey,
+// Обробка та індексація JSON файлів
+func processAndUploadJSON(fileBytes []byte, fileName string, m telebot.Context) error {
+	var jsonData interface{}
+	if err := json.Unmarshal(fileBytes, &jsonData); err != nil {
+		slog.ErrorContext(requestContext(m), "Помилка обробки JSON", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return m.Send(translate(userLanguage(m), "json_error"))
 	}
-	client, err := pinecone.NewClient(clientParams)
-	if err != nil {
-		return nil, fmt.Errorf("Помилка створення клієнта Pinecone: %v", err)
+
+	// Кожен запис (елемент масиву або об'єкт) векторизується окремо
+	records := extractJSONRecords(jsonData)
+	if len(records) == 0 {
+		return m.Send(translate(userLanguage(m), "json_empty"))
 	}
 
-	// Отримуємо інформацію про індекс
-	indexDesc, err := client.DescribeIndex(context.Background(), PineconeIndex)
-	if err != nil {
-		return nil, fmt.Errorf("Помилка під час опису індексу: %v", err)
+	status := newUploadStatus(m)
+
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
+	status.Stage("stage_records")
+
+	// Решта полів запису зберігається як метадані кожної його частини
+	var chunks []documentChunk
+	for i, record := range records {
+		for _, chunk := range chunkText(record.Text, ChunkMaxTokens, ChunkOverlapSentences) {
+			metadata := make(map[string]interface{}, len(record.Metadata)+1)
+			for key, value := range record.Metadata {
+				metadata[key] = value
+			}
+			metadata["record"] = i
+			chunks = append(chunks, documentChunk{Text: chunk, Metadata: metadata})
+		}
 	}
 
-	// Приєднуємось до індексу через хост
-	indexConnection, err := client.Index(pinecone.NewIndexConnParams{Host: indexDesc.Host})
+	result, err := upsertChunks(ctx, uploadCollection(m.Sender().ID, uploadDocumentType(m, fileName)), userNamespace(m.Sender().ID), fileName, chunks, uploadMetadata(m, documentContentType(m, fileName)), status)
 	if err != nil {
-		return nil, fmt.Errorf("Помилка підключення до індексу: %v", err)
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації JSON", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "json_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
 	}
 
-	// Створюємо запит на основі векторного представлення
-	queryRequest := &pinecone.QueryByVectorValuesRequest{
-		Vector:          embedding,
-		TopK:            5,    // Повернути 5 найбільш релевантних записів.
-		IncludeValues:   true, // Додаємо значення векторів.
-		IncludeMetadata: true, // Важливо отримати метадані.
+	return status.Finish(result.message(status.lang, translate(status.lang, "kind_json", len(records))))
+}
+
+// Обробка та індексація CSV файлів: окремий вектор на кожен рядок даних
+func processAndUploadCSV(fileBytes []byte, fileName string, m telebot.Context) error {
+	if !utf8.Valid(fileBytes) {
+		return m.Send(translate(userLanguage(m), "csv_not_utf8"))
 	}
 
-	// Запит до Pinecone
-	response, err := indexConnection.QueryByVectorValues(context.Background(), queryRequest)
+	records, err := extractCSVRecords(fileBytes)
 	if err != nil {
-		return nil, fmt.Errorf("Помилка запиту до Pinecone: %v", err)
+		slog.ErrorContext(requestContext(m), "Помилка обробки CSV", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return m.Send(translate(userLanguage(m), "csv_error", err))
+	}
+	if len(records) == 0 {
+		return m.Send(translate(userLanguage(m), "csv_empty"))
 	}
 
-	log.Printf("Запит до Pinecone був успішним. Знайдено збігів: %d", len(response.Matches))
-
-	return response, nil
-}
-
-// **Формування відповіді через OpenAI GPT-4**
-// Генерація відповіді з використанням всіх знайдених релевантних даних через GPT-4
-// Генерація відповіді з використанням GPT-4
-func generateFinalAnswerFromOpenAI(query string, matches *pinecone.QueryVectorsResponse) (string, error) {
+	status := newUploadStatus(m)
 
-	// Створення OpenAI клієнта
-	client := openai.NewClient(OpenAIKey)
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
 
-	// Підготовка результатів для GPT-4
-	var resultsDescription string
-	for _, match := range matches.Matches {
-		vectorID := match.Vector.Id
-		values, _ := json.Marshal(match.Vector.Values)
+	status.Stage("stage_rows")
 
-		// Метадані
-		metadata := "Метадані відсутні"
-		if match.Vector.Metadata != nil {
-			metadataMap := match.Vector.Metadata.AsMap()
-			metadataBytes, _ := json.Marshal(metadataMap)
-			metadata = string(metadataBytes)
+	// Усі колонки рядка зберігаються як метадані кожної його частини
+	var chunks []documentChunk
+	for i, record := range records {
+		for _, chunk := range chunkText(record.Text, ChunkMaxTokens, ChunkOverlapSentences) {
+			metadata := make(map[string]interface{}, len(record.Metadata)+1)
+			for key, value := range record.Metadata {
+				metadata[key] = value
+			}
+			metadata["row"] = i
+			chunks = append(chunks, documentChunk{Text: chunk, Metadata: metadata})
 		}
+	}
 
-		// Опис результату для GPT-4
-		resultsDescription += fmt.Sprintf("ID: %s, Векторні значення: %s, Метадані: %s. Оцінка релевантності: %f\n", vectorID, values, metadata, match.Score)
-
-		// Обмеження обсягу для GPT
-		if len(resultsDescription) > 100000000 {
-			resultsDescription += "\n(Деякі записи були виключені через обмеження обсягу)."
-			break
+	result, err := upsertChunks(ctx, uploadCollection(m.Sender().ID, uploadDocumentType(m, fileName)), userNamespace(m.Sender().ID), fileName, chunks, uploadMetadata(m, documentContentType(m, fileName)), status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
 		}
+		slog.ErrorContext(ctx, "Помилка індексації CSV", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "csv_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
 	}
 
-	log.Printf("Формування результатів з Pinecone для GPT-4")
+	return status.Finish(result.message(status.lang, translate(status.lang, "kind_csv", len(records))))
+}
 
-	// Запит до GPT-4 із контекстом запиту користувача
-	chatRequest := openai.ChatCompletionRequest{
-		Model: OpenAIModel, // Модель OpenAI з змінної середовища
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "Ти чат-асистент, який відповідає на основі даних з векторної бази Pinecone. Всі відповіді мають базуватися на знайденій інформації. Якщо знайдено кілька варіантів, надай зведення з кожного.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: fmt.Sprintf("Ось ваш запит: %s. Ось знайдені дані через Pinecone: %s", query, resultsDescription),
-			},
+// Обробка та індексація TXT і Markdown файлів
+func processAndUploadText(fileBytes []byte, fileName string, m telebot.Context) error {
+	if !utf8.Valid(fileBytes) {
+		return m.Send(translate(userLanguage(m), "text_not_utf8"))
+	}
+	text := string(fileBytes)
+
+	status := newUploadStatus(m)
+
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
+	status.Stage("stage_chunking")
+
+	// Markdown ділимо на розділи, щоб заголовок потрапив у метадані кожної частини
+	var chunks []documentChunk
+	if docType := uploadDocumentType(m, fileName); docType == "md" || docType == "markdown" {
+		for _, section := range splitMarkdownSections(text) {
+			for _, chunk := range chunkText(section.Text, ChunkMaxTokens, ChunkOverlapSentences) {
+				metadata := map[string]interface{}{}
+				if section.Heading != "" {
+					metadata["section"] = section.Heading
+				}
+				chunks = append(chunks, documentChunk{Text: chunk, Metadata: metadata})
+			}
+		}
+	} else {
+		chunks = textChunks(text)
+	}
+
+	result, err := upsertChunks(ctx, uploadCollection(m.Sender().ID, uploadDocumentType(m, fileName)), userNamespace(m.Sender().ID), fileName, chunks, uploadMetadata(m, documentContentType(m, fileName)), status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації текстового файлу", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "file_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
+	}
+
+	return status.Finish(result.message(status.lang, translate(status.lang, "kind_file", fileName, documentTypeLabels[uploadDocumentType(m, fileName)])))
+}
+
+// Завантаження веб-сторінки та індексація її тексту; URL стає назвою "файлу" для /list і /delete
+func processAndUploadURL(rawURL string, m telebot.Context) error {
+	status := newUploadStatus(m)
+
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
+
+	status.Stage("stage_page")
+	text, title, mediaType, err := fetchURLText(rawURL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка завантаження сторінки", "user_id", m.Sender().ID, "url", rawURL, "error", err)
+		return status.Finish(translate(status.lang, "page_error", err))
+	}
+	if strings.TrimSpace(text) == "" {
+		return status.Finish(translate(status.lang, "page_empty"))
+	}
+
+	metadata := uploadMetadata(m, mediaType)
+	metadata["source"] = rawURL
+	metadata["type"] = "web"
+	if title != "" {
+		metadata["title"] = title
+	}
+
+	result, err := chunkAndUpsert(ctx, uploadCollection(m.Sender().ID, "web"), userNamespace(m.Sender().ID), rawURL, text, metadata, status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(status.lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації сторінки", "user_id", m.Sender().ID, "url", rawURL, "error", err)
+		return status.Finish(withRequestID(ctx, translate(status.lang, "page_upload_error", userFacingError(err))+result.resumeHint(status.lang)))
+	}
+
+	return status.Finish(result.message(status.lang, translate(status.lang, "kind_page", rawURL)))
+}
+
+// Метадані завантаження для кожного вектора: час (RFC3339), ID автора в Telegram і MIME-тип.
+// Час зберігаємо рядком, бо structpb не перетворює time.Time; ID стає числом (ID Telegram менші за 2^53, тож точність не втрачається).
+func uploadMetadata(m telebot.Context, contentType string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"uploaded_at":  time.Now().UTC().Format(time.RFC3339),
+		"uploader_id":  m.Sender().ID,
+		"content_type": contentType,
+	}
+	// Тип, визначений за вмістом, важливіший за розширення файлу
+	if docType, ok := m.Get(documentTypeKey).(string); ok && docType != "" {
+		metadata["type"] = docType
+	}
+	return metadata
+}
+
+// MIME-тип документа з Telegram, а якщо його немає - за розширенням файлу
+func documentContentType(m telebot.Context, fileName string) string {
+	if document := m.Message().Document; document != nil && document.MIME != "" {
+		return document.MIME
+	}
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// Частина документа з власними метаданими (наприклад, розділ Markdown)
+type documentChunk struct {
+	Text     string
+	Metadata map[string]interface{}
+}
+
+// Розбиваємо текст на частини без додаткових метаданих
+func textChunks(text string) []documentChunk {
+	var chunks []documentChunk
+	for _, chunk := range chunkText(text, ChunkMaxTokens, ChunkOverlapSentences) {
+		chunks = append(chunks, documentChunk{Text: chunk})
+	}
+	return chunks
+}
+
+// Розбиваємо текст на частини та додаємо кожну як окремий вектор
+func chunkAndUpsert(ctx context.Context, collection, namespace, fileName, text string, baseMetadata map[string]interface{}, status *uploadStatus) (uploadResult, error) {
+	status.Stage("stage_chunking")
+	return upsertChunks(ctx, collection, namespace, fileName, textChunks(text), baseMetadata, status)
+}
+
+// Результат завантаження документа: скільки частин додано, а скільки вже було в базі
+type uploadResult struct {
+	Added      int
+	Duplicates int
+	Pending    int          // Скільки нових частин мало бути додано
+	Preview    string       // Звіт попереднього перегляду, якщо нічого не додавалося
+	Update     *updateDelta // Зміни відносно попередньої версії файлу, якщо документ оновлювався
+}
+
+// Повідомлення користувачу мовою lang про результат завантаження документа kind
+func (r uploadResult) message(lang, kind string) string {
+	if r.Preview != "" {
+		return r.Preview
+	}
+	if r.Update != nil {
+		return r.Update.message(lang, kind)
+	}
+	if r.Added == 0 {
+		return translate(lang, "upload_present", kind)
+	}
+	if r.Duplicates > 0 {
+		return translate(lang, "upload_done_duplicates", kind, r.Added, r.Duplicates)
+	}
+	return translate(lang, "upload_done", kind, r.Added)
+}
+
+// Підказка мовою lang після помилки завантаження: скільки частин збережено і як продовжити
+func (r uploadResult) resumeHint(lang string) string {
+	if r.Added == 0 {
+		return ""
+	}
+	return translate(lang, "upload_resume_hint", r.Added, r.Pending)
+}
+
+// Повідомлення мовою lang про скасоване через /cancel завантаження
+func (r uploadResult) cancelledMessage(lang string) string {
+	return translate(lang, "upload_cancelled", r.Added)
+}
+
+// Метадані вектора частини: базові поля, поля частини, назва файлу, текст, індекс і хеш вмісту.
+// Тип за розширенням файлу можна перевизначити в базових метаданих (наприклад, для веб-сторінок).
+func chunkMetadata(fileName string, chunk documentChunk, index, total int, hash string, baseMetadata map[string]interface{}) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(baseMetadata)+len(chunk.Metadata)+7)
+	metadata["type"] = documentType(fileName)
+	for key, value := range baseMetadata {
+		metadata[key] = value
+	}
+	for key, value := range chunk.Metadata {
+		metadata[key] = value
+	}
+	metadata["file"] = fileName // Назва файлу завжди однакова для всіх частин, щоб їх можна було видалити
+	metadata["text"] = chunk.Text
+	metadata["chunk"] = index
+	metadata["chunks"] = total
+	metadata["content_hash"] = hash
+	metadata["embedding_model"] = embeddingModelTag()
+	return metadata
+}
+
+// SHA-256 тексту частини для пошуку дублікатів
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Хеш вмісту всього документа для детермінованих ID його векторів (<хеш>-<індекс частини>)
+func documentHash(chunks []documentChunk) string {
+	hash := sha256.New()
+	for _, chunk := range chunks {
+		hash.Write([]byte(chunk.Text))
+		hash.Write([]byte{0}) // Роздільник, щоб межі частин впливали на хеш
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// Векторизуємо та додаємо частини документа в індекс колекції collection пакетами по PineconeUpsertBatchSize векторів.
+// Метадані кожної частини містять базові поля, поля частини, назву файлу, текст частини, її індекс і хеш вмісту.
+// Частини, текст яких уже є в namespace колекції, не додаються вдруге: файл лише посилається в реєстрі на наявний вектор,
+// тож /delete іншого файлу з таким самим текстом його не видаляє. Хід векторизації та додавання показується в status (може бути nil).
+// Скасування ctx зупиняє завантаження між частинами; вже додані пакети залишаються в базі.
+// Кожен доданий пакет одразу записується в реєстр, а при помилці векторизації спершу додаються вже векторизовані
+// частини пакета, тож повторне надсилання того самого файлу пропускає їх за хешем вмісту і продовжує з місця зупинки.
+// Під час оновлення (isUpdateUpload) частини старої версії файлу додаються заново з новими ID і метаданими,
+// а вектори старої версії видаляються лише після того, як нова версія повністю додана.
+func upsertChunks(ctx context.Context, collection, namespace, fileName string, chunks []documentChunk, baseMetadata map[string]interface{}, status *uploadStatus) (uploadResult, error) {
+	var result uploadResult
+	if len(chunks) == 0 {
+		return result, fmt.Errorf("Текст для векторизації порожній")
+	}
+
+	// Спільний префікс ID для всіх частин документа: однаковий вміст дає однакові ID,
+	// тож повторне завантаження перезаписує наявні вектори, а не дублює їх
+	docID := documentHash(chunks)
+
+	// Відбираємо частини, яких ще немає в namespace колекції, зберігаючи їхні початкові індекси
+	type pendingChunk struct {
+		index int
+		hash  string
+	}
+	// Вектори старої версії файлу, які під час оновлення не вважаються дублікатами
+	update := isUpdateUpload(ctx)
+	previous := make(map[string]bool)
+	var previousHashes map[string]bool
+	if update {
+		for _, id := range registryVectorIDs(collection, namespace, fileName) {
+			previous[id] = true
+		}
+		previousHashes = registryFileHashes(collection, namespace, fileName)
+	}
+
+	var pending []pendingChunk
+	var shared []registeredVector
+	seen := make(map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		hash := contentHash(chunk.Text)
+		existing, exists := registryVectorByHash(collection, namespace, hash)
+		if exists && previous[existing] && existing != fmt.Sprintf("%s-%d", docID, i) {
+			exists = false
+		}
+		if seen[hash] {
+			result.Duplicates++
+			continue
+		}
+		seen[hash] = true
+		if exists {
+			shared = append(shared, registeredVector{ID: existing, Hash: hash})
+			result.Duplicates++
+			continue
+		}
+		pending = append(pending, pendingChunk{index: i, hash: hash})
+	}
+	if result.Duplicates > 0 {
+		slog.InfoContext(ctx, "Пропущено дублікати частин", "file", fileName, "duplicates", result.Duplicates)
+	}
+
+	// У режимі попереднього перегляду нічого не векторизуємо та не додаємо
+	if isPreviewUpload(ctx) {
+		result.Preview = uploadPreview(status.language(), collection, namespace, fileName, chunks, result.Duplicates, baseMetadata)
+		return result, nil
+	}
+
+	result.Pending = len(pending)
+
+	// Наявні вектори з таким самим текстом записуємо й за цим файлом
+	if err := registerVectors(collection, namespace, fileName, shared...); err != nil {
+		slog.ErrorContext(ctx, "Помилка оновлення реєстру документів", "file", fileName, "error", err)
+	}
+
+	// Нові документи можуть змінити відповіді, тож кешовані відповіді namespace вже неактуальні
+	defer func() {
+		if result.Added > 0 {
+			invalidateAnswerCache(namespace)
+		}
+	}()
+
+	// Векторизовані частини додаємо у Pinecone одним запитом і одразу фіксуємо в реєстрі як контрольну точку
+	batchNumber := 0
+	upsertGroup := func(group []pendingChunk, embeddings [][]float32) error {
+		batchNumber++
+		batch := make([]*pinecone.Vector, 0, len(group))
+		batchVectors := make([]registeredVector, 0, len(group))
+		for i, p := range group {
+			chunk := chunks[p.index]
+			if err := checkVectorDimension(embeddings[i]); err != nil {
+				return err
+			}
+
+			// Метадані векторів у форматі JSON
+			metadataStruct, err := metadataStruct(ctx, chunkMetadata(fileName, chunk, p.index, len(chunks), p.hash, baseMetadata))
+			if err != nil {
+				return fmt.Errorf("Помилка перетворення метаданих частини %d: %v", p.index, err)
+			}
+
+			id := fmt.Sprintf("%s-%d", docID, p.index)
+			batch = append(batch, &pinecone.Vector{
+				Id:           id,                                           // Унікальний ID частини документа
+				Values:       embeddings[i],                                // Вектор частини
+				SparseValues: documentSparseVector(collection, chunk.Text), // Ключові слова частини для гібридного пошуку
+				Metadata:     metadataStruct,                               // Метадані
+			})
+			batchVectors = append(batchVectors, registeredVector{ID: id, Hash: p.hash})
+		}
+
+		status.Stage("stage_upsert", batchNumber, len(batch))
+		if err := upsertVectorsToPinecone(ctx, collection, namespace, batch); err != nil {
+			return fmt.Errorf("Помилка додавання пакета %d (частин: %d) у Pinecone: %v", batchNumber, len(batch), err)
+		}
+		if err := registerVectors(collection, namespace, fileName, batchVectors...); err != nil {
+			slog.ErrorContext(ctx, "Помилка оновлення реєстру документів", "file", fileName, "error", err)
+		}
+		result.Added += len(batch)
+		return nil
+	}
+
+	// Кожен пакет векторизуємо паралельно в пулі, а потім додаємо у Pinecone
+	for start := 0; start < len(pending); start += PineconeUpsertBatchSize {
+		group := pending[start:min(start+PineconeUpsertBatchSize, len(pending))]
+
+		texts := make([]string, len(group))
+		for i, p := range group {
+			texts[i] = chunks[p.index].Text
+		}
+		embeddings, failed, err := embedChunks(ctx, texts, func(done int) {
+			status.Progress("progress_embedded", start+done, len(pending))
+		})
+		if errors.Is(err, context.Canceled) {
+			return result, err
+		}
+		if err != nil {
+			// Зберігаємо частини пакета, які встигли векторизувати, щоб не повторювати їх при наступній спробі
+			var done []pendingChunk
+			var doneEmbeddings [][]float32
+			for i, embedding := range embeddings {
+				if embedding != nil {
+					done = append(done, group[i])
+					doneEmbeddings = append(doneEmbeddings, embedding)
+				}
+			}
+			if len(done) > 0 {
+				if saveErr := upsertGroup(done, doneEmbeddings); saveErr != nil {
+					slog.WarnContext(ctx, "Не вдалося зберегти векторизовані частини пакета", "file", fileName, "error", saveErr)
+				}
+			}
+			slog.WarnContext(ctx, "Завантаження перервано, додані частини збережено", "file", fileName, "added", result.Added, "pending", result.Pending)
+			return result, fmt.Errorf("Помилка векторизації частини %d: %w", group[failed].index, err)
+		}
+
+		if err := upsertGroup(group, embeddings); err != nil {
+			slog.WarnContext(ctx, "Завантаження перервано, додані частини збережено", "file", fileName, "added", result.Added, "pending", result.Pending)
+			return result, err
+		}
+	}
+
+	// Нова версія вже в базі, тож тепер прибираємо вектори старої
+	if update {
+		result.Update = newUpdateDelta(previousHashes, chunks)
+		deleted, err := removeStaleVectors(ctx, namespace, fileName, docID)
+		result.Update.Deleted = deleted
+		if deleted > 0 {
+			invalidateAnswerCache(namespace)
+		}
+		if err != nil {
+			return result, fmt.Errorf("Нову версію додано, але не вдалося видалити частини старої: %v. Надішліть файл для оновлення ще раз", err)
+		}
+		slog.InfoContext(ctx, "Документ оновлено", "file", fileName, "added", result.Update.Added, "removed", result.Update.Removed,
+			"unchanged", result.Update.Unchanged, "deleted_vectors", deleted)
+	}
+
+	return result, nil
+}
+
+// PDF не містить текстового шару (наприклад, відскановані сторінки)
+var errPDFNoText = errors.New("PDF не містить тексту, який можна витягнути")
+
+// Витягуємо текст з PDF, розділяючи сторінки переносом рядка
+func extractTextFromPDF(r io.ReaderAt, size int64) (text string, err error) {
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		if errors.Is(err, pdf.ErrInvalidPassword) {
+			return "", fmt.Errorf("PDF зашифровано паролем, витягнути текст неможливо")
+		}
+		return "", fmt.Errorf("Помилка відкриття PDF: %v", err)
+	}
+
+	// Бібліотека панікує на пошкоджених потоках, тому перетворюємо паніку на помилку
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("Помилка розбору PDF: %v", r)
+		}
+	}()
+
+	var sb strings.Builder
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// Кешуємо шрифти між сторінками
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return "", fmt.Errorf("Помилка читання сторінки %d: %v", i, err)
+		}
+
+		// Зберігаємо межі сторінок для подальшого розбиття на частини
+		sb.WriteString(strings.TrimSpace(pageText))
+		sb.WriteString("\n")
+	}
+
+	text = strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", errPDFNoText
+	}
+
+	return text, nil
+}
+
+// Спільне підключення до Pinecone, що створюється один раз під час старту
+var pineconeConn struct {
+	sync.Mutex
+	client    *pinecone.Client
+	indexes   map[string]*pineconeIndexState // Індекси за назвою колекції
+	dimension int                            // Розмірність векторів, однакова для всіх індексів
+}
+
+// Індекс Pinecone колекції та його підключення за namespace
+type pineconeIndexState struct {
+	name        string
+	host        string                               // Хост індексу з DescribeIndex
+	metric      pinecone.IndexMetric                 // Метрика подібності індексу
+	connections map[string]*pinecone.IndexConnection // Підключення до індексу за namespace
+}
+
+// Створюємо клієнт Pinecone та підключення до індексів усіх колекцій
+func initPinecone() error {
+	client, err := pinecone.NewClient(pinecone.NewClientParams{
+		ApiKey: PineconeAPIKey,
+	})
+	if err != nil {
+		return fmt.Errorf("Помилка створення клієнта Pinecone: %v", err)
+	}
+
+	pineconeConn.client = client
+	pineconeConn.indexes = make(map[string]*pineconeIndexState)
+
+	// Деталі кожного індексу описуємо лише один раз і кешуємо хост
+	for _, collection := range collectionNames() {
+		indexName := PineconeCollections[collection]
+		ctx, cancel := context.WithTimeout(context.Background(), PineconeTimeout)
+		indexDesc, err := client.DescribeIndex(ctx, indexName)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("Помилка опису індексу Pinecone %s (якщо індексу ще немає, створіть його командою aibot init-index): %v", indexName, err)
+		}
+
+		// Запит векторизується один раз для всіх колекцій, тож розмірності мають збігатися
+		if pineconeConn.dimension == 0 {
+			pineconeConn.dimension = int(indexDesc.Dimension)
+		} else if int(indexDesc.Dimension) != pineconeConn.dimension {
+			return fmt.Errorf("Розмірність індексу %s (%d) відрізняється від розмірності індексу %s (%d)", indexName, indexDesc.Dimension, PineconeIndex, pineconeConn.dimension)
+		}
+
+		// Гібридний пошук надсилає розріджені вектори, які Pinecone приймає лише для метрики dotproduct
+		if hybridSearchEnabled() && indexDesc.Metric != pinecone.Dotproduct {
+			return fmt.Errorf("Гібридний пошук (HYBRID_SEARCH_ALPHA < 1) потребує індексу з метрикою dotproduct, а індекс %s має метрику %s. "+
+				"Перенесіть документи в новий індекс командою aibot reindex --create", indexName, indexDesc.Metric)
+		}
+
+		pineconeConn.indexes[collection] = &pineconeIndexState{
+			name:        indexName,
+			host:        indexDesc.Host,
+			metric:      indexDesc.Metric,
+			connections: make(map[string]*pinecone.IndexConnection),
+		}
+
+		// Перевіряємо підключення до спільного namespace одразу під час старту
+		if _, err := pineconeIndex(collection, ""); err != nil {
+			return err
+		}
+
+		slog.Info("Підключено до індексу Pinecone", "collection", collection, "index", indexName, "host", indexDesc.Host, "dimension", indexDesc.Dimension)
+	}
+
+	return nil
+}
+
+// Підключення до індексу колекції в межах namespace; створюється один раз і перевикористовується
+func pineconeIndex(collection, namespace string) (*pinecone.IndexConnection, error) {
+	pineconeConn.Lock()
+	defer pineconeConn.Unlock()
+
+	index, ok := pineconeConn.indexes[collection]
+	if !ok {
+		return nil, fmt.Errorf("Невідома колекція %q", collection)
+	}
+	if conn, ok := index.connections[namespace]; ok {
+		return conn, nil
+	}
+
+	conn, err := pineconeConn.client.Index(pinecone.NewIndexConnParams{
+		Host:      index.host,
+		Namespace: namespace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка підключення до індексу %s: %v", index.name, err)
+	}
+	index.connections[namespace] = conn
+
+	return conn, nil
+}
+
+// Перевіряємо, що розмірність вектора збігається з розмірністю індексу, до запиту в Pinecone
+func checkVectorDimension(embedding []float32) error {
+	if len(embedding) != pineconeConn.dimension {
+		return fmt.Errorf("Розмірність вектора (%d) не збігається з розмірністю індексу %s (%d). Перевірте OPENAI_EMBEDDING_MODEL і OPENAI_EMBEDDING_DIMENSIONS або створіть індекс потрібної розмірності",
+			len(embedding), PineconeIndex, pineconeConn.dimension)
+	}
+	return nil
+}
+
+// Namespace користувача: власний для кожного Telegram ID або спільний, якщо так налаштовано
+func userNamespace(userID int64) string {
+	if PineconeSharedNamespace {
+		return ""
+	}
+	return fmt.Sprintf("user-%d", userID)
+}
+
+// Додаємо пакет векторів в індекс колекції одним запитом UpsertVectors.
+// Розпочатий запит не переривається скасуванням ctx, щоб пакет не залишився в невідомому стані.
+func upsertVectorsToPinecone(ctx context.Context, collection, namespace string, vectors []*pinecone.Vector) error {
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return err
+	}
+
+	_, err = withBreaker(ctx, pineconeBreaker, func() (uint32, error) {
+		return withContextTimeoutRetry(context.WithoutCancel(ctx), "Pinecone UpsertVectors", PineconeUpsertTimeout, func(ctx context.Context) (uint32, error) {
+			return index.UpsertVectors(ctx, vectors)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("Запит UpsertVectors не вдався: %w", err)
+	}
+
+	return nil
+}
+
+// Видаляємо всі вектори, у метаданих яких file збігається з fileName, з індексів усіх колекцій,
+// крім спільних з іншими файлами.
+// Serverless-індекси не підтримують видалення за фільтром, тому спершу шукаємо ID запитом з фільтром.
+func deleteVectorsByFile(namespace, fileName string) (int, error) {
+	filter, probe, err := fileVectorsQuery(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, collection := range collectionNames() {
+		// Вектори, на які посилаються інші файли з таким самим текстом, залишаємо, навіть якщо їх знайдено за фільтром
+		others := registryOtherFilesVectorIDs(collection, namespace, fileName)
+		owned := slices.DeleteFunc(registryVectorIDs(collection, namespace, fileName), func(id string) bool { return others[id] })
+
+		// Рахуємо лише вектори, які справді є в індексі, а не всі ID з реєстру
+		registered, err := existingVectorIDs(collection, namespace, owned)
+		if err != nil {
+			return deleted, err
+		}
+		found, err := deleteFileFromCollection(collection, namespace, registered, slices.Collect(maps.Keys(others)), filter, probe)
+		deleted += found + len(registered)
+		if err != nil {
+			return deleted, err
+		}
+		if err := unregisterFile(collection, namespace, fileName); err != nil {
+			slog.Error("Помилка оновлення реєстру документів", "collection", collection, "file", fileName, "error", err)
+		}
+	}
+	return deleted, nil
+}
+
+// ID з ids, які є в індексі колекції. Реєстр може посилатися на вектори, яких в індексі вже немає
+// або ніколи не було (записи, віднесені до типової колекції), а кількість видалених має бути точною.
+func existingVectorIDs(collection, namespace string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []string
+	for start := 0; start < len(ids); start += exportPageSize {
+		page := ids[start:min(start+exportPageSize, len(ids))]
+		fetched, err := withTimeoutRetry("Pinecone FetchVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+			return index.FetchVectors(ctx, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Помилка отримання векторів: %v", err)
+		}
+		for _, id := range page {
+			if _, ok := fetched.Vectors[id]; ok {
+				existing = append(existing, id)
+			}
+		}
+	}
+	return existing, nil
+}
+
+// Фільтр векторів файлу fileName і пробний вектор для запиту з цим фільтром
+func fileVectorsQuery(fileName string) (*structpb.Struct, []float32, error) {
+	filter, err := structpb.NewStruct(map[string]interface{}{
+		"file": map[string]interface{}{"$eq": fileName},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Помилка створення фільтра: %v", err)
+	}
+
+	// Для запиту з фільтром потрібен будь-який ненульовий вектор потрібної розмірності
+	probe := make([]float32, pineconeConn.dimension)
+	for i := range probe {
+		probe[i] = 1
+	}
+	return filter, probe, nil
+}
+
+// Видаляємо з індексу колекції вектори з реєстру та знайдені за фільтром, крім векторів keep.
+// Повертаємо кількість векторів, знайдених за фільтром поза реєстром.
+func deleteFileFromCollection(collection, namespace string, registered, keep []string, filter *structpb.Struct, probe []float32) (int, error) {
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool, len(registered)+len(keep))
+	for _, id := range keep {
+		seen[id] = true
+	}
+
+	// Спершу видаляємо відомі з реєстру вектори
+	if len(registered) > 0 {
+		_, err := withTimeoutRetry("Pinecone DeleteVectorsById", PineconeTimeout, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, index.DeleteVectorsById(ctx, registered)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("Помилка видалення векторів: %v", err)
+		}
+		for _, id := range registered {
+			seen[id] = true
+		}
+	}
+
+	// Потім шукаємо вектори, яких немає в реєстрі (наприклад, завантажені до його появи)
+	found := 0
+	for {
+		response, err := withTimeoutRetry("Pinecone QueryByVectorValues", PineconeTimeout, func(ctx context.Context) (*pinecone.QueryVectorsResponse, error) {
+			return index.QueryByVectorValues(ctx, &pinecone.QueryByVectorValuesRequest{
+				Vector:         probe,
+				TopK:           1000,
+				MetadataFilter: filter,
+			})
+		})
+		if err != nil {
+			return found, fmt.Errorf("Помилка пошуку векторів файлу: %v", err)
+		}
+
+		// Індекс оновлюється не миттєво, тож пропускаємо вже видалені ID
+		var ids []string
+		for _, match := range response.Matches {
+			if !seen[match.Vector.Id] {
+				seen[match.Vector.Id] = true
+				ids = append(ids, match.Vector.Id)
+			}
+		}
+		if len(ids) == 0 {
+			return found, nil
+		}
+
+		_, err = withTimeoutRetry("Pinecone DeleteVectorsById", PineconeTimeout, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, index.DeleteVectorsById(ctx, ids)
+		})
+		if err != nil {
+			return found, fmt.Errorf("Помилка видалення векторів: %v", err)
+		}
+		found += len(ids)
+	}
+}
+
+// Виконуємо пошук в індексі колекції за релевантними даними для запиту.
+// Збіги з оцінкою нижче minScore відкидаються.
+func searchPinecone(ctx context.Context, collection, namespace string, embedding []float32, minScore float32, filter *structpb.Struct) (*pinecone.QueryVectorsResponse, error) {
+	started := time.Now()
+	if err := checkVectorDimension(embedding); err != nil {
+		return nil, err
+	}
+
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Створюємо запит на основі векторного представлення
+	queryRequest := &pinecone.QueryByVectorValuesRequest{
+		Vector:          embedding,
+		TopK:            uint32(candidateTopK(ctx)), // Кількість найбільш релевантних записів.
+		MetadataFilter:  filter,                     // nil означає пошук без фільтра.
+		IncludeValues:   false,                      // Значення векторів для відповіді не потрібні.
+		IncludeMetadata: true,                       // Важливо отримати метадані.
+	}
+
+	// Гібридний пошук: оцінка зважено поєднує векторну подібність і збіг ключових слів запиту
+	if alpha := denseWeight(ctx); alpha < 1 && sparseSupported(collection) {
+		if query, ok := keywordQuery(ctx); ok {
+			if sparse := sparseVector(query, true); sparse != nil {
+				queryRequest.Vector, queryRequest.SparseValues = hybridQuery(embedding, sparse, alpha)
+			}
+		}
+	}
+
+	// Запит до Pinecone
+	// Якщо Pinecone недоступний, вимикач одразу повертає errCircuitOpen замість очікування таймаутів
+	response, err := withBreaker(ctx, pineconeBreaker, func() (*pinecone.QueryVectorsResponse, error) {
+		return withContextTimeoutRetry(ctx, "Pinecone QueryByVectorValues", PineconeTimeout, func(ctx context.Context) (*pinecone.QueryVectorsResponse, error) {
+			return index.QueryByVectorValues(ctx, queryRequest)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка запиту до Pinecone: %w", err)
+	}
+
+	// Розподіл оцінок до відсікання допомагає підібрати поріг PINECONE_MIN_SCORE
+	recordScoreDistribution(ctx, collection, response.Matches, minScore)
+
+	// Відкидаємо нерелевантні збіги, щоб GPT не отримував шумовий контекст
+	relevant := response.Matches[:0]
+	for _, match := range response.Matches {
+		if match.Score >= minScore {
+			relevant = append(relevant, match)
+		}
+	}
+
+	slog.DebugContext(ctx, "Запит до Pinecone був успішним", "collection", collection, "namespace", namespace, "match_count", len(response.Matches),
+		"relevant_count", len(relevant), "latency_ms", time.Since(started).Milliseconds())
+
+	response.Matches = relevant
+
+	return response, nil
+}
+
+// Інструкція для відповіді, коли в базі знань нічого не знайдено
+const noContextInstruction = "У базі знань немає даних для цього запитання, тож відповідай коротко із загальних знань і не вигадуй фактів про конкретних людей."
+
+// **Формування відповіді через OpenAI GPT-4**
+// Генерація відповіді з використанням всіх знайдених релевантних даних через GPT-4
+// Генерація відповіді з використанням GPT-4
+// Якщо передано onProgress, відповідь стрімиться і onProgress отримує накопичений текст.
+// truncated означає, що відповідь обрізано через ліміт OpenAIMaxTokens.
+// З TOOL_SEARCH_ENABLED модель може шукати через search (може бути nil), а знайдені джерела додаються до matches.
+func generateFinalAnswerFromOpenAI(ctx context.Context, query string, matches *pinecone.QueryVectorsResponse, history []openai.ChatCompletionMessage,
+	onProgress func(string), search knowledgeSearch) (answer string, truncated bool, err error) {
+
+	// Створення OpenAI клієнта
+	client := newOpenAIClient()
+
+	// Модель читаємо один раз, щоб /model посеред запиту не змішав моделі
+	model := currentModel()
+
+	// Відповідаємо мовою запиту, а не мовою шаблонів чи знайдених документів, і посилаємося на джерела за номерами
+	instruction := languageInstruction(query) + " " + sourceCitationInstruction
+	if GuardrailMode == "strict" {
+		instruction += " " + guardrailInstruction
+	}
+
+	// Шаблони без знайдених даних, щоб порахувати їхню вартість у токенах
+	systemPrompt, userPrompt, err := renderPrompts(query, "")
+	if err != nil {
+		return "", false, err
+	}
+	systemPrompt += "\n\n" + instruction
+
+	// Бюджет токенів для знайдених даних: вікно контексту мінус системна інструкція,
+	// історія, запит користувача та резерв для відповіді
+	budget := OpenAIContextTokens - OpenAICompletionReserve -
+		countTokens(model, systemPrompt) - countTokens(model, userPrompt) -
+		tokensPerMessage*(len(history)+2)
+	for _, message := range history {
+		budget -= countTokens(model, message.Content)
+	}
+
+	// Збіги вже впорядковані за релевантністю (Pinecone або переранжування),
+	// тож при перевищенні бюджету відкидаються найменш релевантні
+	sorted := matches.Matches
+
+	// Підготовка результатів для GPT-4: пронумеровані блоки джерел (без сирих векторів, які лише витрачають токени)
+	var resultsDescription string
+	dropped := 0
+	for i, match := range sorted {
+		description := sourceBlock(i+1, match)
+
+		// Обмеження обсягу для GPT
+		tokens := countTokens(model, description)
+		if tokens > budget {
+			dropped = len(sorted) - i
+			break
+		}
+		budget -= tokens
+		resultsDescription += description
+	}
+	if dropped > 0 {
+		resultsDescription += "\n(Деякі записи були виключені через обмеження обсягу)."
+		slog.WarnContext(ctx, "Через ліміт контексту відкинуто збіги", "dropped", dropped, "match_count", len(sorted))
+	}
+
+	slog.DebugContext(ctx, "Формування результатів з Pinecone для GPT-4", "match_count", len(sorted)-dropped)
+
+	// Без збігів (NO_CONTEXT_MODE=general) дозволяємо моделі відповісти із загальних знань
+	if len(sorted) == 0 {
+		resultsDescription = "(релевантних даних не знайдено)"
+		instruction = noContextInstruction + " " + languageInstruction(query)
+	}
+	toolSearch := ToolSearchEnabled && search != nil
+	if toolSearch {
+		instruction += " " + toolSearchInstruction
+	}
+
+	systemPrompt, userPrompt, err = renderPrompts(query, resultsDescription)
+	if err != nil {
+		return "", false, err
+	}
+	systemPrompt += "\n\n" + instruction
+
+	// Системна інструкція, попередні репліки розмови та поточний запит із контекстом
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemPrompt,
 		},
 	}
+	messages = append(messages, history...)
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: userPrompt,
+	})
+
+	// Запит до GPT-4 із контекстом запиту користувача
+	chatRequest := openai.ChatCompletionRequest{
+		Model:       model, // Активна модель OpenAI (OPENAI_MODEL або обрана через /model)
+		Messages:    messages,
+		Temperature: OpenAITemperature,
+		MaxTokens:   OpenAIMaxTokens, // Обмежуємо довжину і вартість відповіді
+	}
+
+	// Модель сама вирішує, чи шукати ще, тож відповідь отримуємо після всіх викликів пошуку
+	if toolSearch {
+		resp, err := completeWithToolSearch(ctx, client, chatRequest, matches, search, budget)
+		if err != nil {
+			return "", false, fmt.Errorf("GPT-4 не зміг згенерувати відповідь: %w", err)
+		}
+		return resp.Choices[0].Message.Content, resp.Choices[0].FinishReason == openai.FinishReasonLength, nil
+	}
+
+	// Стрімимо відповідь; при помилці посеред відповіді повертаємося до звичайного запиту
+	if onProgress != nil && OpenAIStream {
+		answer, truncated, err := streamChatCompletion(ctx, client, chatRequest, onProgress)
+		if err == nil {
+			return answer, truncated, nil
+		}
+		// Після таймауту повторний запит без стрімінгу лише подвоїв би очікування
+		if isTimeoutError(err) {
+			return "", false, fmt.Errorf("GPT-4 не відповів вчасно: %w", err)
+		}
+		slog.WarnContext(ctx, "Стрімінг відповіді не вдався, повторюємо без стрімінгу", "error", err)
+	}
 
 	// Надсилаємо запит до GPT-4
-	resp, err := client.CreateChatCompletion(context.Background(), chatRequest)
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, chatRequest)
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("GPT-4 не зміг згенерувати відповідь: %w", err)
+	}
+
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return "", false, fmt.Errorf("GPT-4 не повернув відповіді")
+	}
+	return resp.Choices[0].Message.Content, resp.Choices[0].FinishReason == openai.FinishReasonLength, nil
+}
+
+// Клієнт OpenAI; з OPENAI_BASE_URL запити йдуть на вказаний OpenAI-сумісний сервер
+func newOpenAIClient() *openai.Client {
+	config := openai.DefaultConfig(OpenAIKey)
+	if OpenAIBaseURL != "" {
+		config.BaseURL = OpenAIBaseURL
+	}
+	return openai.NewClientWithConfig(config)
+}
+
+// Отримуємо відповідь GPT частинами, передаючи накопичений текст у onProgress
+// truncated означає, що відповідь обрізано через ліміт MaxTokens.
+func streamChatCompletion(ctx context.Context, client *openai.Client, chatRequest openai.ChatCompletionRequest, onProgress func(string)) (answerText string, truncated bool, err error) {
+	chatRequest.Stream = true
+	chatRequest.StreamOptions = &openai.StreamOptions{IncludeUsage: true} // Використання токенів приходить в останньому фрагменті
+	// Таймаут охоплює весь стрімінг, а не лише початок відповіді
+	ctx, cancel := context.WithTimeout(ctx, OpenAIChatTimeout)
+	defer cancel()
+	defer observeExternalCall("OpenAI CreateChatCompletionStream", time.Now())
+
+	stream, err := client.CreateChatCompletionStream(ctx, chatRequest)
 	if err != nil {
-		return "", fmt.Errorf("GPT-4 не зміг згенерувати відповідь: %v", err)
+		return "", false, err
+	}
+	defer stream.Close()
+
+	var answer strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if resp.Usage != nil {
+			recordChatUsage(*resp.Usage)
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+
+		if resp.Choices[0].FinishReason == openai.FinishReasonLength {
+			truncated = true
+		}
+		answer.WriteString(resp.Choices[0].Delta.Content)
+		onProgress(answer.String())
+	}
+
+	if answer.Len() == 0 {
+		return "", false, fmt.Errorf("GPT повернув порожню відповідь")
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return answer.String(), truncated, nil
 }
 
 func init() {
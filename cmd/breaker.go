@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Стани автоматичного вимикача
+const (
+	breakerClosed   = "closed"    // Запити проходять
+	breakerOpen     = "open"      // Запити одразу відхиляються до кінця паузи
+	breakerHalfOpen = "half-open" // Після паузи пропускаємо один пробний запит
+)
+
+// Помилка, яку повертає розімкнений вимикач замість очікування таймаутів
+var errCircuitOpen = errors.New("База знань тимчасово недоступна")
+
+// Автоматичний вимикач: після threshold поспіль невдалих операцій сервісу запити відхиляються
+// на cooldown, а потім один пробний запит вирішує, замкнути вимикач чи знову розімкнути
+type circuitBreaker struct {
+	name string
+
+	mu        sync.Mutex
+	state     string
+	failures  int       // Невдалі операції поспіль
+	openedAt  time.Time // Коли вимикач розімкнувся востаннє
+	probing   bool      // Пробний запит у напіввідкритому стані вже виконується
+	rejected  int64     // Відхилені без звернення до сервісу запити
+	lastError string
+}
+
+// Вимикач для запитів до Pinecone (PINECONE_BREAKER_THRESHOLD, PINECONE_BREAKER_COOLDOWN)
+var pineconeBreaker = &circuitBreaker{name: "Pinecone", state: breakerClosed}
+
+// Виконуємо fn, якщо вимикач це дозволяє, і враховуємо результат
+func withBreaker[T any](ctx context.Context, b *circuitBreaker, fn func() (T, error)) (T, error) {
+	if !b.allow(ctx) {
+		var zero T
+		return zero, errCircuitOpen
+	}
+	result, err := fn()
+	b.record(ctx, err)
+	return result, err
+}
+
+// Чи можна звернутися до сервісу; після паузи розімкнений вимикач пропускає один пробний запит
+func (b *circuitBreaker) allow(ctx context.Context) bool {
+	if PineconeBreakerThreshold == 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < PineconeBreakerCooldown {
+			b.rejected++
+			return false
+		}
+		b.state, b.probing = breakerHalfOpen, true
+		slog.InfoContext(ctx, "Вимикач напіввідкрито: пробний запит", "service", b.name)
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Враховуємо результат операції: збої сервісу (таймаути та тимчасові помилки) розмикають вимикач,
+// а будь-яка відповідь сервісу, навіть з помилкою запиту, його замикає
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if PineconeBreakerThreshold == 0 || errors.Is(err, context.Canceled) {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	if err == nil || !(isTimeoutError(err) || isRetryableError(err)) {
+		if b.state != breakerClosed {
+			slog.InfoContext(ctx, "Вимикач замкнено: сервіс знову відповідає", "service", b.name)
+		}
+		b.state, b.failures = breakerClosed, 0
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= PineconeBreakerThreshold) {
+		b.state, b.openedAt = breakerOpen, time.Now()
+		slog.WarnContext(ctx, "Вимикач розімкнено: запити до сервісу призупинено", "service", b.name,
+			"failures", b.failures, "cooldown", PineconeBreakerCooldown, "error", err)
+	}
+}
+
+// Рядок стану вимикача для /stats
+func (b *circuitBreaker) report() string {
+	if PineconeBreakerThreshold == 0 {
+		return fmt.Sprintf("Вимикач %s: вимкнено\n", b.name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	line := fmt.Sprintf("Вимикач %s: %s, збоїв поспіль %d з %d, відхилено запитів %d", b.name, b.state, b.failures, PineconeBreakerThreshold, b.rejected)
+	if b.state == breakerOpen {
+		remaining := PineconeBreakerCooldown - time.Since(b.openedAt)
+		line += fmt.Sprintf(", пробний запит через %s", max(remaining, 0).Round(time.Second))
+	}
+	if b.lastError != "" && b.state != breakerClosed {
+		line += fmt.Sprintf(" (остання помилка: %s)", b.lastError)
+	}
+	return line + "\n"
+}
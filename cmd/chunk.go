@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Приблизна оцінка кількості токенів у тексті (~4 символи на токен)
+func estimateTokens(text string) int {
+	return runesToTokens(utf8.RuneCountInString(text))
+}
+
+// Оцінка токенів за кількістю символів
+func runesToTokens(runes int) int {
+	return (runes + 3) / 4
+}
+
+// Речення (або шматок завеликого речення) як одиниця розбиття тексту
+type chunkUnit struct {
+	text           string
+	runes          int  // Довжина в символах: бюджет рахуємо для склеєної частини разом із роздільниками
+	paragraphStart bool // Перше речення абзацу: в частині відокремлюється переносом рядка
+}
+
+// Розбиваємо текст на частини до maxTokens токенів. Межі частин проходять між реченнями,
+// а кожна наступна частина починається з останніх overlapSentences речень попередньої,
+// якщо вони вміщуються в бюджет разом із новим реченням. Завеликі речення ділимо по словах.
+func chunkText(text string, maxTokens, overlapSentences int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxTokens <= 0 {
+		return []string{text}
+	}
+	if overlapSentences < 0 {
+		overlapSentences = 0
+	}
+
+	// Збираємо речення, кожне з яких вміщується у maxTokens
+	var units []chunkUnit
+	for _, paragraph := range splitParagraphs(text) {
+		paragraphStart := true
+		for _, sentence := range splitSentences(paragraph) {
+			parts := []string{sentence}
+			if estimateTokens(sentence) > maxTokens {
+				parts = splitWords(sentence, maxTokens)
+			}
+			for _, part := range parts {
+				units = append(units, chunkUnit{text: part, runes: utf8.RuneCountInString(part), paragraphStart: paragraphStart})
+				paragraphStart = false
+			}
+		}
+	}
+
+	var chunks []string
+	var current []chunkUnit
+	currentRunes := 0 // Довжина склеєної частини разом із роздільниками
+	for _, unit := range units {
+		if len(current) > 0 && runesToTokens(currentRunes+1+unit.runes) > maxTokens {
+			chunks = append(chunks, joinUnits(current))
+
+			// Переносимо останні речення попередньої частини як перекриття
+			current, currentRunes = overlapTail(current, overlapSentences, maxTokens, unit.runes)
+		}
+		if len(current) > 0 {
+			currentRunes++ // Пробіл або перенос рядка перед реченням
+		}
+		current = append(current, unit)
+		currentRunes += unit.runes
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, joinUnits(current))
+	}
+
+	return chunks
+}
+
+// Повертаємо до sentences останніх речень частини та довжину їх склеєного тексту. Беремо лише речення,
+// що разом із наступним реченням довжиною nextRunes вміщуються в maxTokens, тож наступна частина не перевищить бюджет.
+func overlapTail(units []chunkUnit, sentences, maxTokens, nextRunes int) ([]chunkUnit, int) {
+	runes := 0
+	start := len(units)
+	for start > 0 && len(units)-start < sentences {
+		extended := units[start-1].runes
+		if start < len(units) {
+			extended += 1 + runes
+		}
+		if runesToTokens(extended+1+nextRunes) > maxTokens {
+			break
+		}
+		runes = extended
+		start--
+	}
+
+	tail := make([]chunkUnit, len(units)-start)
+	copy(tail, units[start:])
+	return tail, runes
+}
+
+// Склеюємо речення частини: в межах абзацу через пробіл, абзаци - з нового рядка
+func joinUnits(units []chunkUnit) string {
+	var sb strings.Builder
+	for i, unit := range units {
+		if i > 0 {
+			if unit.paragraphStart {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(unit.text)
+	}
+	return sb.String()
+}
+
+// Ділимо текст на непорожні абзаци
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// Ділимо абзац на речення за розділовими знаками кінця речення
+func splitSentences(paragraph string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(paragraph)
+	for i, r := range runes {
+		if r != '.' && r != '!' && r != '?' && r != '…' {
+			continue
+		}
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = i + 1
+	}
+	if sentence := strings.TrimSpace(string(runes[start:])); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// Ділимо завелике речення на шматки по словах, кожен до maxTokens токенів
+func splitWords(sentence string, maxTokens int) []string {
+	var parts []string
+	var current strings.Builder
+	for _, word := range strings.Fields(sentence) {
+		// Окреме слово довше за бюджет ріжемо по символах
+		if estimateTokens(word) > maxTokens && current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		for estimateTokens(word) > maxTokens {
+			runes := []rune(word)
+			parts = append(parts, string(runes[:maxTokens*4]))
+			word = string(runes[maxTokens*4:])
+		}
+
+		if current.Len() > 0 && estimateTokens(current.String()+" "+word) > maxTokens {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(" ")
+		}
+		current.WriteString(word)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
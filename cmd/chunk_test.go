@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Текст з count однакових за довжиною пронумерованих речень
+func numberedSentences(count int) string {
+	sentences := make([]string, count)
+	for i := range sentences {
+		sentences[i] = fmt.Sprintf("Речення номер %03d.", i)
+	}
+	return strings.Join(sentences, " ")
+}
+
+func TestChunkTextOverlap(t *testing.T) {
+	for _, overlap := range []int{0, 1, 2, 3} {
+		t.Run(fmt.Sprintf("overlap=%d", overlap), func(t *testing.T) {
+			chunks := chunkText(numberedSentences(40), 30, overlap)
+			if len(chunks) < 2 {
+				t.Fatalf("очікувалося кілька частин, отримано %d", len(chunks))
+			}
+			for i := 1; i < len(chunks); i++ {
+				previous := splitSentences(chunks[i-1])
+				current := splitSentences(chunks[i])
+				shared := 0
+				for shared < len(current) && slices.Contains(previous, current[shared]) {
+					shared++
+				}
+				if shared != overlap {
+					t.Errorf("частини %d і %d мають %d спільних речень, очікувалося %d", i-1, i, shared, overlap)
+				}
+				if !slices.Equal(previous[len(previous)-shared:], current[:shared]) {
+					t.Errorf("частина %d не починається з останніх речень частини %d", i, i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkTextTokenBudget(t *testing.T) {
+	text := numberedSentences(30) + "\n" + strings.Repeat("Коротке. ", 20) + "\n" + numberedSentences(10)
+	for _, maxTokens := range []int{8, 20, 50} {
+		for _, chunk := range chunkText(text, maxTokens, 2) {
+			if tokens := estimateTokens(chunk); tokens > maxTokens {
+				t.Errorf("maxTokens=%d: частина має %d токенів: %q", maxTokens, tokens, chunk)
+			}
+		}
+	}
+}
+
+func TestChunkTextLongSentence(t *testing.T) {
+	long := strings.TrimSuffix(strings.Repeat("слово ", 100), " ") + "."
+	chunks := chunkText(long, 10, 2)
+	if len(chunks) < 2 {
+		t.Fatalf("завелике речення має розбитися на кілька частин, отримано %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if tokens := estimateTokens(chunk); tokens > 10 {
+			t.Errorf("частина має %d токенів: %q", tokens, chunk)
+		}
+	}
+
+	// Жодне слово не втрачено, навіть якщо саме слово довше за бюджет
+	word := strings.Repeat("я", 100)
+	chunks = chunkText(word, 10, 2)
+	if got := strings.Join(chunks, ""); got != word {
+		t.Errorf("слово довше за бюджет втрачено: %q", got)
+	}
+}
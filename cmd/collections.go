@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	defaultCollection = "default" // Колекція в індексі PINECONE_INDEX (типово telegram), доступна завжди
+	allCollections    = "all"     // Пошук у всіх колекціях одночасно
+
+	defaultNamespaceName = "__default__" // Назва спільного namespace без назви в PINECONE_SEARCH_NAMESPACES
+)
+
+// Перевіряємо колекції з PINECONE_COLLECTIONS та PINECONE_TYPE_COLLECTIONS і додаємо типову колекцію
+func setCollections(collections, typeCollections map[string]string) error {
+	if collections == nil {
+		collections = make(map[string]string)
+	}
+	for name, index := range collections {
+		if name == allCollections {
+			return fmt.Errorf("PINECONE_COLLECTIONS: назва колекції %q зарезервована", allCollections)
+		}
+		if name == defaultCollection && index != PineconeIndex {
+			return fmt.Errorf("PINECONE_COLLECTIONS: колекція %q завжди використовує індекс %s з PINECONE_INDEX, змініть індекс там", defaultCollection, PineconeIndex)
+		}
+	}
+	collections[defaultCollection] = PineconeIndex
+
+	for docType, collection := range typeCollections {
+		if _, ok := collections[collection]; !ok {
+			return fmt.Errorf("PINECONE_TYPE_COLLECTIONS: тип %s посилається на невідому колекцію %q", docType, collection)
+		}
+	}
+
+	PineconeCollections = collections
+	PineconeTypeCollections = typeCollections
+	return nil
+}
+
+// Назви колекцій: типова першою, решта за абеткою
+func collectionNames() []string {
+	names := make([]string, 0, len(PineconeCollections))
+	for name := range PineconeCollections {
+		if name != defaultCollection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{defaultCollection}, names...)
+}
+
+// Чи налаштована колекція з такою назвою
+func isKnownCollection(name string) bool {
+	_, ok := PineconeCollections[name]
+	return ok
+}
+
+// Колекція для нового документа: обрана через /collection, інакше за типом документа, інакше типова
+func uploadCollection(userID int64, docType string) string {
+	if collection := sessionCollection(userID); isKnownCollection(collection) {
+		return collection
+	}
+	if collection, ok := PineconeTypeCollections[docType]; ok {
+		return collection
+	}
+	return defaultCollection
+}
+
+// Колекції для пошуку: обрана через /collection або всі, якщо вибору немає
+func searchCollectionNames(userID int64) []string {
+	if collection := sessionCollection(userID); isKnownCollection(collection) {
+		return []string{collection}
+	}
+	return collectionNames()
+}
+
+// Namespace для пошуку: namespace користувача першим, далі PINECONE_SEARCH_NAMESPACES без повторів
+func searchNamespaces(userID int64) []string {
+	namespaces := []string{userNamespace(userID)}
+	for _, namespace := range PineconeSearchNamespaces {
+		if namespace == defaultNamespaceName {
+			namespace = ""
+		}
+		if !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// Шукаємо в кількох колекціях і namespace паралельно та зливаємо збіги за оцінкою, залишаючи searchTopK найкращих.
+// Усі індекси використовують одну модель (і однакову вагу HYBRID_SEARCH_ALPHA), тож оцінки з різних пошуків порівнювані.
+// Якщо namespace кілька, у метадані збігу додається namespace, з якого його знайдено, щоб посилання на джерела були точними.
+// Помилка одного пошуку не зриває решту, якщо інші відповіли.
+func searchCollections(ctx context.Context, collections, namespaces []string, embedding []float32, minScore float32, filter *structpb.Struct) (*pinecone.QueryVectorsResponse, error) {
+	type target struct{ collection, namespace string }
+	var targets []target
+	for _, collection := range collections {
+		for _, namespace := range namespaces {
+			targets = append(targets, target{collection, namespace})
+		}
+	}
+	if len(targets) == 1 {
+		response, err := searchPinecone(ctx, collections[0], namespaces[0], embedding, minScore, filter)
+		if err != nil {
+			return nil, err
+		}
+		response.Matches = capMatchesPerFile(response.Matches, MaxChunksPerFile, searchTopK(ctx))
+		return response, nil
+	}
+
+	// Не більше SearchParallelism одночасних запитів, щоб багато колекцій і namespace не впиралися в ліміти Pinecone
+	responses := make([]*pinecone.QueryVectorsResponse, len(targets))
+	errs := make([]error, len(targets))
+	slots := make(chan struct{}, SearchParallelism)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			responses[i], errs[i] = searchPinecone(ctx, t.collection, t.namespace, embedding, minScore, filter)
+		}()
+	}
+	wg.Wait()
+
+	var firstErr error
+	for i, response := range responses {
+		if errs[i] != nil {
+			slog.WarnContext(ctx, "Помилка пошуку в колекції", "collection", targets[i].collection, "namespace", targets[i].namespace, "error", errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		if len(namespaces) > 1 {
+			tagMatchNamespace(response.Matches, targets[i].namespace)
+		}
+	}
+
+	merged := &pinecone.QueryVectorsResponse{Namespace: namespaces[0]}
+	merged.Matches = mergeMatches(responses, MaxChunksPerFile, searchTopK(ctx))
+	if len(merged.Matches) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return merged, nil
+}
+
+// Зливаємо збіги кількох пошуків (nil-відповіді невдалих пошуків пропускаємо): однаковий збіг (namespace та ID вектора)
+// залишаємо один раз із найкращою оцінкою, впорядковуємо за спаданням оцінки, а за однакової оцінки - за ключем,
+// щоб порядок не залежав від порядку завершення пошуків, і залишаємо topK збігів з обмеженням perFile частин на файл
+func mergeMatches(responses []*pinecone.QueryVectorsResponse, perFile, topK int) []*pinecone.ScoredVector {
+	best := map[string]*pinecone.ScoredVector{}
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+		for _, match := range response.Matches {
+			if match == nil || match.Vector == nil {
+				continue
+			}
+			key := matchKey(match)
+			if current, ok := best[key]; !ok || match.Score > current.Score {
+				best[key] = match
+			}
+		}
+	}
+
+	matches := make([]*pinecone.ScoredVector, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matchKey(matches[i]) < matchKey(matches[j])
+	})
+	return capMatchesPerFile(matches, perFile, topK)
+}
+
+// Скільки збігів запитуємо в Pinecone: з обмеженням частин на файл - із запасом,
+// щоб місця відкинутих частин заповнили менш релевантні частини інших файлів
+func candidateTopK(ctx context.Context) int {
+	topK := searchTopK(ctx)
+	if MaxChunksPerFile == 0 {
+		return topK
+	}
+	return min(topK*3, 10000)
+}
+
+// Залишаємо не більше perFile частин кожного файлу (0 - без обмеження) і не більше topK збігів загалом.
+// Збіги мають бути впорядковані за спаданням оцінки; порядок зберігається.
+func capMatchesPerFile(matches []*pinecone.ScoredVector, perFile, topK int) []*pinecone.ScoredVector {
+	capped := make([]*pinecone.ScoredVector, 0, min(len(matches), topK))
+	counts := make(map[string]int)
+	for _, match := range matches {
+		if len(capped) == topK {
+			break
+		}
+		if perFile > 0 {
+			key := matchFileKey(match)
+			if key != "" && counts[key] >= perFile {
+				continue
+			}
+			counts[key]++
+		}
+		capped = append(capped, match)
+	}
+	return capped
+}
+
+// Файл збігу разом із namespace (однакова назва файлу в різних namespace - різні документи)
+func matchFileKey(match *pinecone.ScoredVector) string {
+	if match.Vector == nil || match.Vector.Metadata == nil {
+		return ""
+	}
+	file := match.Vector.Metadata.Fields["file"].GetStringValue()
+	if file == "" {
+		return ""
+	}
+	return match.Vector.Metadata.Fields["namespace"].GetStringValue() + "/" + file
+}
+
+// Додаємо до метаданих збігів namespace, з якого їх знайдено
+func tagMatchNamespace(matches []*pinecone.ScoredVector, namespace string) {
+	for _, match := range matches {
+		if match.Vector == nil {
+			continue
+		}
+		if match.Vector.Metadata == nil {
+			match.Vector.Metadata = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		}
+		match.Vector.Metadata.Fields["namespace"] = structpb.NewStringValue(namespaceLabel(namespace))
+	}
+}
+
+// Назва namespace для людини: спільний namespace без назви показуємо як __default__
+func namespaceLabel(namespace string) string {
+	if namespace == "" {
+		return defaultNamespaceName
+	}
+	return namespace
+}
+
+// Ключ збігу для злиття результатів: однаковий вміст у різних namespace має однаковий ID вектора
+func matchKey(match *pinecone.ScoredVector) string {
+	namespace := ""
+	if match.Vector.Metadata != nil {
+		if value, ok := match.Vector.Metadata.Fields["namespace"]; ok {
+			namespace = value.GetStringValue()
+		}
+	}
+	return namespace + "/" + match.Vector.Id
+}
+
+// Сумарна кількість векторів у кількох namespace
+func namespacesVectorCount(namespaces []string) (uint32, error) {
+	var total uint32
+	for _, namespace := range namespaces {
+		count, err := namespaceVectorCount(namespace)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// Кількість векторів namespace у всіх колекціях
+func namespaceVectorCount(namespace string) (uint32, error) {
+	var total uint32
+	for _, collection := range collectionNames() {
+		count, err := collectionVectorCount(collection, namespace)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// Кількість векторів у namespace колекції за статистикою індексу
+func collectionVectorCount(collection, namespace string) (uint32, error) {
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	stats, err := withTimeoutRetry("Pinecone DescribeIndexStats", PineconeTimeout, func(ctx context.Context) (*pinecone.DescribeIndexStatsResponse, error) {
+		return index.DescribeIndexStats(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if summary, ok := stats.Namespaces[namespace]; ok {
+		return summary.VectorCount, nil
+	}
+	return 0, nil
+}
+
+// Опис колекцій для /collection мовою lang: назва, індекс і позначка обраної
+func describeCollections(lang, selected string) string {
+	var sb strings.Builder
+	for _, name := range collectionNames() {
+		marker := "•"
+		if name == selected {
+			marker = "✅"
+		}
+		sb.WriteString(translate(lang, "collection_entry", marker, name, PineconeCollections[name]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Збіг з ID, оцінкою, файлом і namespace у метаданих (порожні значення не додаються)
+func testMatch(id string, score float32, file, namespace string) *pinecone.ScoredVector {
+	fields := map[string]*structpb.Value{}
+	if file != "" {
+		fields["file"] = structpb.NewStringValue(file)
+	}
+	if namespace != "" {
+		fields["namespace"] = structpb.NewStringValue(namespace)
+	}
+	return &pinecone.ScoredVector{
+		Vector: &pinecone.Vector{Id: id, Metadata: &structpb.Struct{Fields: fields}},
+		Score:  score,
+	}
+}
+
+func testResponse(matches ...*pinecone.ScoredVector) *pinecone.QueryVectorsResponse {
+	return &pinecone.QueryVectorsResponse{Matches: matches}
+}
+
+func TestMergeMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*pinecone.QueryVectorsResponse
+		perFile   int
+		topK      int
+		want      []string // matchKey збігів у порядку результату
+		scores    []float32
+	}{
+		{
+			name: "empty input",
+			topK: 5,
+			want: []string{},
+		},
+		{
+			name:      "nil responses and matches",
+			responses: []*pinecone.QueryVectorsResponse{nil, testResponse(nil, &pinecone.ScoredVector{Score: 1}), nil},
+			topK:      5,
+			want:      []string{},
+		},
+		{
+			name: "same ID keeps the highest score",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("a", 0.7, "f.txt", "ns"), testMatch("b", 0.8, "g.txt", "ns")),
+				testResponse(testMatch("a", 0.9, "f.txt", "ns")),
+				testResponse(testMatch("a", 0.6, "f.txt", "ns")),
+			},
+			topK:   5,
+			want:   []string{"ns/a", "ns/b"},
+			scores: []float32{0.9, 0.8},
+		},
+		{
+			name: "same ID in different namespaces is kept twice",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("a", 0.9, "f.txt", "ns1")),
+				testResponse(testMatch("a", 0.8, "f.txt", "ns2")),
+			},
+			topK: 5,
+			want: []string{"ns1/a", "ns2/a"},
+		},
+		{
+			name: "equal scores are ordered by matchKey",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("c", 0.5, "", "ns"), testMatch("a", 0.5, "", "ns")),
+				testResponse(testMatch("b", 0.5, "", "ns"), testMatch("a", 0.5, "", "aa")),
+			},
+			topK: 5,
+			want: []string{"aa/a", "ns/a", "ns/b", "ns/c"},
+		},
+		{
+			name: "topK cap",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("a", 0.9, "", "ns"), testMatch("b", 0.8, "", "ns")),
+				testResponse(testMatch("c", 0.7, "", "ns"), testMatch("d", 0.6, "", "ns")),
+			},
+			topK: 2,
+			want: []string{"ns/a", "ns/b"},
+		},
+		{
+			name: "perFile cap fills the gap with other files",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("a1", 0.9, "a.txt", "ns"), testMatch("a2", 0.85, "a.txt", "ns")),
+				testResponse(testMatch("a3", 0.8, "a.txt", "ns"), testMatch("b1", 0.7, "b.txt", "ns")),
+				testResponse(testMatch("c1", 0.6, "", "ns"), testMatch("c2", 0.5, "", "ns")),
+			},
+			perFile: 2,
+			topK:    4,
+			want:    []string{"ns/a1", "ns/a2", "ns/b1", "ns/c1"},
+		},
+		{
+			name: "perFile counts files per namespace",
+			responses: []*pinecone.QueryVectorsResponse{
+				testResponse(testMatch("a1", 0.9, "a.txt", "ns1"), testMatch("a2", 0.8, "a.txt", "ns1")),
+				testResponse(testMatch("a1", 0.7, "a.txt", "ns2")),
+			},
+			perFile: 1,
+			topK:    5,
+			want:    []string{"ns1/a1", "ns2/a1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeMatches(tt.responses, tt.perFile, tt.topK)
+			keys := make([]string, len(merged))
+			for i, match := range merged {
+				keys[i] = matchKey(match)
+			}
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("mergeMatches() = %v, want %v", keys, tt.want)
+			}
+			for i, score := range tt.scores {
+				if i < len(merged) && merged[i].Score != score {
+					t.Errorf("збіг %s має оцінку %v, want %v", keys[i], merged[i].Score, score)
+				}
+			}
+		})
+	}
+}
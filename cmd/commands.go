@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Команда бота з описом для /help і меню команд Telegram
+type botCommand struct {
+	Text        string // Команда без "/"
+	Description string
+	Admin       bool // Доступна лише адміністраторам
+}
+
+// Усі зареєстровані команди в порядку реєстрації
+var botCommands []botCommand
+
+// Опис команди мовою lang: переклад з каталогу (command_<команда>) або опис, з яким її зареєстровано
+func (c botCommand) description(lang string) string {
+	if text, ok := messageCatalog[lang]["command_"+c.Text]; ok {
+		return text
+	}
+	return c.Description
+}
+
+// Реєструємо команду для всіх користувачів: обробник у telebot та опис для /help і меню
+func handleCommand(bot *telebot.Bot, command, description string, handler telebot.HandlerFunc) {
+	botCommands = append(botCommands, botCommand{Text: strings.TrimPrefix(command, "/"), Description: description})
+	bot.Handle(command, handler)
+}
+
+// Реєструємо команду адміністратора: іншим користувачам вона не показується й відповідає відмовою
+func handleAdminCommand(bot *telebot.Bot, command, description string, handler telebot.HandlerFunc) {
+	botCommands = append(botCommands, botCommand{Text: strings.TrimPrefix(command, "/"), Description: description, Admin: true})
+	bot.Handle(command, func(m telebot.Context) error {
+		if !isAdmin(m.Sender().ID) {
+			return m.Send(translate(userLanguage(m), "admin_only"))
+		}
+		return handler(m)
+	})
+}
+
+// Команди для меню Telegram мовою lang: звичайні або разом з командами адміністраторів
+func telegramCommands(lang string, admin bool) []telebot.Command {
+	var commands []telebot.Command
+	for _, command := range botCommands {
+		if !command.Admin || admin {
+			commands = append(commands, telebot.Command{Text: command.Text, Description: command.description(lang)})
+		}
+	}
+	return commands
+}
+
+// Передаємо перелік команд у Telegram, щоб клієнт показував меню: усім - звичайні команди,
+// адміністраторам у їхніх особистих чатах - ще й адміністративні. Меню мовою за замовчуванням
+// бачать користувачі, для мови яких немає каталогу, решта - меню своєю мовою.
+func registerBotCommands(bot *telebot.Bot) {
+	for lang := range messageCatalog {
+		code := lang
+		if lang == defaultLanguage {
+			code = ""
+		}
+		if err := bot.SetCommands(telegramCommands(lang, false), code); err != nil {
+			slog.Warn("Не вдалося зареєструвати меню команд у Telegram", "language", lang, "error", err)
+			return
+		}
+		for adminID := range AdminIDs {
+			scope := telebot.CommandScope{Type: telebot.CommandScopeChat, ChatID: adminID}
+			if err := bot.SetCommands(telegramCommands(lang, true), scope, code); err != nil {
+				// Telegram не знає чату адміністратора, доки той не написав боту
+				slog.Warn("Не вдалося зареєструвати меню команд адміністратора", "admin_id", adminID, "language", lang, "error", err)
+			}
+		}
+	}
+	slog.Info("Меню команд зареєстровано в Telegram", "commands", len(botCommands), "languages", len(messageCatalog))
+}
+
+// Обробка /help: перелік доступних користувачу команд з описами
+func handleHelp(m telebot.Context) error {
+	admin := isAdmin(m.Sender().ID)
+	lang := userLanguage(m)
+
+	var sb strings.Builder
+	sb.WriteString(translate(lang, "help_commands"))
+	for _, command := range botCommands {
+		if !command.Admin {
+			fmt.Fprintf(&sb, "/%s — %s\n", command.Text, command.description(lang))
+		}
+	}
+	if admin {
+		sb.WriteString(translate(lang, "help_admin_commands"))
+		for _, command := range botCommands {
+			if command.Admin {
+				fmt.Fprintf(&sb, "/%s — %s\n", command.Text, command.description(lang))
+			}
+		}
+	}
+	sb.WriteString(translate(lang, "help_footer"))
+	return sendLongMessage(m, sb.String())
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Рівні впевненості відповіді від найнижчого
+const (
+	confidenceLow = iota
+	confidenceMedium
+	confidenceHigh
+)
+
+// Назви рівнів для логів і самооцінки моделі
+var confidenceLevels = []string{"low", "medium", "high"}
+
+// Розрив між двома найкращими оцінками, за якого найкращий збіг явно виділяється серед інших
+const confidenceClearGap = 0.05
+
+// Інструкція для самооцінки відповіді моделлю
+const confidenceSelfRatePrompt = `Оціни, наскільки надані джерела підтверджують відповідь чат-бота на запитання.
+Відповідай лише JSON-об'єктом {"confidence": "high", "medium" або "low"}: high - відповідь повністю випливає з джерел,
+medium - частково, low - джерела майже не стосуються запитання.`
+
+// Впевненість відповіді за оцінками збігів, а з ANSWER_CONFIDENCE_SELF_RATE - нижча з неї та самооцінки моделі
+func answerConfidence(ctx context.Context, query, answer string, matches *pinecone.QueryVectorsResponse) int {
+	top, gap, mean := matchScoreStats(matches.Matches)
+
+	// Найкраща оцінка визначає рівень; середню впевненість підвищуємо, якщо найкращий збіг явно кращий за інші
+	level := confidenceMedium
+	switch {
+	case float64(top) >= ConfidenceHighScore:
+		level = confidenceHigh
+	case float64(top) < ConfidenceLowScore:
+		level = confidenceLow
+	case gap >= confidenceClearGap && len(matches.Matches) > 1:
+		level = confidenceHigh
+	}
+	retrieval := level
+
+	selfRated := ""
+	if AnswerConfidenceSelfRate {
+		rated, err := selfRateConfidence(ctx, query, answer, matches)
+		if err != nil {
+			slog.WarnContext(ctx, "Самооцінка впевненості не вдалася", "error", err)
+		} else {
+			selfRated = confidenceLevels[rated]
+			level = min(level, rated)
+		}
+	}
+
+	slog.DebugContext(ctx, "Впевненість відповіді", "top_score", top, "score_gap", gap, "mean_score", mean, "match_count", len(matches.Matches),
+		"retrieval", confidenceLevels[retrieval], "self_rated", selfRated, "confidence", confidenceLevels[level])
+	return level
+}
+
+// Найкраща оцінка, її відрив від другої та середня оцінка збігів
+func matchScoreStats(matches []*pinecone.ScoredVector) (top, gap, mean float32) {
+	if len(matches) == 0 {
+		return 0, 0, 0
+	}
+	second := float32(0)
+	sum := float32(0)
+	for _, match := range matches {
+		sum += match.Score
+		if match.Score > top {
+			top, second = match.Score, top
+		} else if match.Score > second {
+			second = match.Score
+		}
+	}
+	if len(matches) > 1 {
+		gap = top - second
+	}
+	return top, gap, sum / float32(len(matches))
+}
+
+// Позначка впевненості під відповіддю; за низької впевненості - ще й застереження перед нею
+func withConfidence(lang string, level int, answer string) string {
+	switch level {
+	case confidenceHigh:
+		return answer + "\n\n" + translate(lang, "confidence_high")
+	case confidenceMedium:
+		return answer + "\n\n" + translate(lang, "confidence_medium")
+	}
+	return translate(lang, "low_confidence") + "\n\n" + answer + "\n\n" + translate(lang, "confidence_low")
+}
+
+// Питаємо модель ANSWER_CONFIDENCE_MODEL, наскільки джерела підтверджують відповідь
+func selfRateConfidence(ctx context.Context, query, answer string, matches *pinecone.QueryVectorsResponse) (int, error) {
+	var sources strings.Builder
+	for i, match := range matches.Matches {
+		text := ""
+		if match.Vector != nil && match.Vector.Metadata != nil {
+			text, _ = match.Vector.Metadata.AsMap()["text"].(string)
+		}
+		fmt.Fprintf(&sources, "\n[%d] %s\n", i+1, truncateRunes(text, rerankPassageLength))
+	}
+
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model: AnswerConfidenceModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: confidenceSelfRatePrompt},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Запитання: %s\n\nДжерела:%s\nВідповідь:\n%s", query, sources.String(), answer)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (confidence)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Помилка запиту самооцінки: %w", err)
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return 0, fmt.Errorf("OpenAI не повернув самооцінки")
+	}
+	var result struct {
+		Confidence string `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return 0, fmt.Errorf("Некоректна самооцінка: %v", err)
+	}
+	for level, name := range confidenceLevels {
+		if strings.EqualFold(result.Confidence, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("Невідомий рівень самооцінки %q", result.Confidence)
+}
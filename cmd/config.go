@@ -0,0 +1,560 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Розмірність векторів відомих моделей ембеддингів OpenAI
+var embeddingModelDimensions = map[string]int{
+	"text-embedding-ada-002": 1536,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+}
+
+// Моделі, що підтримують зменшення розмірності параметром dimensions
+var embeddingModelsWithDimensions = map[string]bool{
+	"text-embedding-3-small": true,
+	"text-embedding-3-large": true,
+}
+
+// Назва індексу Pinecone: малі латинські літери, цифри та дефіси, до 45 символів
+var pineconeIndexName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,43}[a-z0-9])?$`)
+
+// Значення змінної середовища або типове значення, якщо її не задано
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// Список значень через кому без порожніх елементів
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Пари "ключ=значення" через кому зі змінної середовища, наприклад "resumes=resumes-index,projects=projects-index"
+func envKeyValues(key string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(os.Getenv(key)) {
+		name, value, ok := strings.Cut(item, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%s має містити пари ключ=значення через кому, отримано %q", key, item)
+		}
+		pairs[name] = value
+	}
+	return pairs, nil
+}
+
+// Ціле значення змінної середовища або типове значення
+func envInt(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s має бути цілим числом: %v", key, err)
+	}
+	return n, nil
+}
+
+// Дробове значення змінної середовища або типове значення
+func envFloat(key string, def float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s має бути числом: %v", key, err)
+	}
+	return f, nil
+}
+
+// Тривалість зі змінної середовища (наприклад, "500ms" або "2s") або типове значення
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s має бути тривалістю, наприклад 500ms: %v", key, err)
+	}
+	return d, nil
+}
+
+// Зчитуємо числові налаштування зі змінних середовища та перевіряємо їх
+func loadConfig() error {
+	topK, err := envInt("PINECONE_TOP_K", PineconeTopK)
+	if err != nil {
+		return err
+	}
+	if topK < 1 || topK > 10000 {
+		return fmt.Errorf("PINECONE_TOP_K має бути в межах 1-10000, отримано %d", topK)
+	}
+	PineconeTopK = topK
+
+	maxChunksPerFile, err := envInt("MAX_CHUNKS_PER_FILE", MaxChunksPerFile)
+	if err != nil {
+		return err
+	}
+	if maxChunksPerFile < 0 {
+		return fmt.Errorf("MAX_CHUNKS_PER_FILE не може бути від'ємним, отримано %d", maxChunksPerFile)
+	}
+	MaxChunksPerFile = maxChunksPerFile
+
+	searchParallelism, err := envInt("SEARCH_PARALLELISM", SearchParallelism)
+	if err != nil {
+		return err
+	}
+	if searchParallelism < 1 {
+		return fmt.Errorf("SEARCH_PARALLELISM має бути не менше 1, отримано %d", searchParallelism)
+	}
+	SearchParallelism = searchParallelism
+
+	batchSize, err := envInt("PINECONE_UPSERT_BATCH_SIZE", PineconeUpsertBatchSize)
+	if err != nil {
+		return err
+	}
+	if batchSize < 1 || batchSize > 1000 {
+		return fmt.Errorf("PINECONE_UPSERT_BATCH_SIZE має бути в межах 1-1000, отримано %d", batchSize)
+	}
+	PineconeUpsertBatchSize = batchSize
+
+	metadataBytes, err := envInt("PINECONE_MAX_METADATA_BYTES", PineconeMaxMetadataBytes)
+	if err != nil {
+		return err
+	}
+	if metadataBytes < 1024 || metadataBytes > pineconeMetadataLimit {
+		return fmt.Errorf("PINECONE_MAX_METADATA_BYTES має бути в межах 1024-%d, отримано %d", pineconeMetadataLimit, metadataBytes)
+	}
+	PineconeMaxMetadataBytes = metadataBytes
+
+	workers, err := envInt("EMBEDDING_WORKERS", EmbeddingWorkers)
+	if err != nil {
+		return err
+	}
+	if workers < 1 {
+		return fmt.Errorf("EMBEDDING_WORKERS має бути додатним, отримано %d", workers)
+	}
+	EmbeddingWorkers = workers
+	initEmbeddingPool(EmbeddingWorkers)
+
+	overlapSentences, err := envInt("CHUNK_OVERLAP_SENTENCES", ChunkOverlapSentences)
+	if err != nil {
+		return err
+	}
+	if overlapSentences < 0 {
+		return fmt.Errorf("CHUNK_OVERLAP_SENTENCES не може бути від'ємним, отримано %d", overlapSentences)
+	}
+	ChunkOverlapSentences = overlapSentences
+
+	embeddingBatchSize, err := envInt("EMBEDDING_BATCH_SIZE", EmbeddingBatchSize)
+	if err != nil {
+		return err
+	}
+	if embeddingBatchSize < 1 || embeddingBatchSize > 2048 {
+		return fmt.Errorf("EMBEDDING_BATCH_SIZE має бути в межах 1-2048, отримано %d", embeddingBatchSize)
+	}
+	EmbeddingBatchSize = embeddingBatchSize
+
+	embeddingDimensions, err := envInt("OPENAI_EMBEDDING_DIMENSIONS", OpenAIEmbeddingDimensions)
+	if err != nil {
+		return err
+	}
+	if embeddingDimensions != 0 {
+		if EmbeddingProvider != "openai" || !embeddingModelsWithDimensions[OpenAIEmbeddingModel] {
+			return fmt.Errorf("OPENAI_EMBEDDING_DIMENSIONS підтримують лише моделі text-embedding-3 від OpenAI, а обрано %s", embeddingModel())
+		}
+		if full := embeddingModelDimensions[OpenAIEmbeddingModel]; embeddingDimensions < 1 || embeddingDimensions > full {
+			return fmt.Errorf("OPENAI_EMBEDDING_DIMENSIONS для %s має бути в межах 1-%d, отримано %d", OpenAIEmbeddingModel, full, embeddingDimensions)
+		}
+	}
+	OpenAIEmbeddingDimensions = embeddingDimensions
+
+	maxInputTokens, err := envInt("EMBEDDING_MAX_INPUT_TOKENS", EmbeddingMaxInputTokens)
+	if err != nil {
+		return err
+	}
+	if maxInputTokens < 1 {
+		return fmt.Errorf("EMBEDDING_MAX_INPUT_TOKENS має бути додатним, отримано %d", maxInputTokens)
+	}
+	EmbeddingMaxInputTokens = maxInputTokens
+	if EmbeddingOversizeMode != "truncate" && EmbeddingOversizeMode != "average" && EmbeddingOversizeMode != "error" {
+		return fmt.Errorf("EMBEDDING_OVERSIZE_MODE має бути truncate, average або error, отримано %s", EmbeddingOversizeMode)
+	}
+	if EmbeddingModelChange != "refuse" && EmbeddingModelChange != "reindex" {
+		return fmt.Errorf("EMBEDDING_MODEL_CHANGE має бути refuse або reindex, отримано %s", EmbeddingModelChange)
+	}
+	if err := initEmbedder(); err != nil {
+		return err
+	}
+
+	if !pineconeIndexName.MatchString(PineconeIndex) {
+		return fmt.Errorf("PINECONE_INDEX має містити лише малі латинські літери, цифри та дефіси (до 45 символів), отримано %q", PineconeIndex)
+	}
+	collections, err := envKeyValues("PINECONE_COLLECTIONS")
+	if err != nil {
+		return err
+	}
+	typeCollections, err := envKeyValues("PINECONE_TYPE_COLLECTIONS")
+	if err != nil {
+		return err
+	}
+	if err := setCollections(collections, typeCollections); err != nil {
+		return err
+	}
+
+	if NoContextMode != "refuse" && NoContextMode != "general" {
+		return fmt.Errorf("NO_CONTEXT_MODE має бути refuse або general, отримано %s", NoContextMode)
+	}
+	if GroupTrigger != "mention" && GroupTrigger != "all" && GroupTrigger != "off" {
+		return fmt.Errorf("GROUP_TRIGGER має бути mention, all або off, отримано %s", GroupTrigger)
+	}
+	if GuardrailMode != "off" && GuardrailMode != "strict" {
+		return fmt.Errorf("GUARDRAIL_MODE має бути off або strict, отримано %s", GuardrailMode)
+	}
+	if GuardrailMode == "strict" && NoContextMode == "general" {
+		return fmt.Errorf("NO_CONTEXT_MODE=general несумісний з GUARDRAIL_MODE=strict: у строгому режимі бот відповідає лише з джерел")
+	}
+	if err := initAnswerPostprocessor(); err != nil {
+		return err
+	}
+
+	queryMinLength, err := envInt("QUERY_MIN_LENGTH", QueryMinLength)
+	if err != nil {
+		return err
+	}
+	if queryMinLength < 1 {
+		return fmt.Errorf("QUERY_MIN_LENGTH має бути додатним, отримано %d", queryMinLength)
+	}
+	QueryMinLength = queryMinLength
+
+	maxUploadBytes, err := envInt("MAX_UPLOAD_BYTES", int(MaxUploadBytes))
+	if err != nil {
+		return err
+	}
+	if maxUploadBytes < 1 {
+		return fmt.Errorf("MAX_UPLOAD_BYTES має бути додатним, отримано %d", maxUploadBytes)
+	}
+	MaxUploadBytes = int64(maxUploadBytes)
+
+	ingestMaxBytes, err := envInt("INGEST_MAX_BYTES", int(IngestMaxBytes))
+	if err != nil {
+		return err
+	}
+	if ingestMaxBytes < 1 {
+		return fmt.Errorf("INGEST_MAX_BYTES має бути додатним, отримано %d", ingestMaxBytes)
+	}
+	IngestMaxBytes = int64(ingestMaxBytes)
+
+	if value := os.Getenv("CSV_DELIMITER"); value != "" {
+		delimiter, err := parseCSVDelimiter(value)
+		if err != nil {
+			return err
+		}
+		CSVDelimiter = delimiter
+	}
+
+	ocrMinTextChars, err := envInt("OCR_MIN_TEXT_CHARS", OCRMinTextChars)
+	if err != nil {
+		return err
+	}
+	if ocrMinTextChars < 0 {
+		return fmt.Errorf("OCR_MIN_TEXT_CHARS не може бути від'ємним, отримано %d", ocrMinTextChars)
+	}
+	OCRMinTextChars = ocrMinTextChars
+
+	temperature, err := envFloat("OPENAI_TEMPERATURE", float64(OpenAITemperature))
+	if err != nil {
+		return err
+	}
+	if temperature < 0 || temperature > 2 {
+		return fmt.Errorf("OPENAI_TEMPERATURE має бути в межах 0-2, отримано %g", temperature)
+	}
+	OpenAITemperature = float32(temperature)
+
+	minScore, err := envFloat("PINECONE_MIN_SCORE", float64(PineconeMinScore))
+	if err != nil {
+		return err
+	}
+	if minScore < 0 || minScore > 1 {
+		return fmt.Errorf("PINECONE_MIN_SCORE має бути в межах 0-1, отримано %g", minScore)
+	}
+	PineconeMinScore = float32(minScore)
+
+	hybridAlpha, err := envFloat("HYBRID_SEARCH_ALPHA", HybridSearchAlpha)
+	if err != nil {
+		return err
+	}
+	if hybridAlpha < 0 || hybridAlpha > 1 {
+		return fmt.Errorf("HYBRID_SEARCH_ALPHA має бути в межах 0-1, отримано %g", hybridAlpha)
+	}
+	HybridSearchAlpha = hybridAlpha
+
+	for _, threshold := range []struct {
+		key    string
+		target *float64
+	}{
+		{"CONFIDENCE_HIGH_SCORE", &ConfidenceHighScore},
+		{"CONFIDENCE_LOW_SCORE", &ConfidenceLowScore},
+	} {
+		value, err := envFloat(threshold.key, *threshold.target)
+		if err != nil {
+			return err
+		}
+		if value < 0 || value > 1 {
+			return fmt.Errorf("%s має бути в межах 0-1, отримано %g", threshold.key, value)
+		}
+		*threshold.target = value
+	}
+	if ConfidenceLowScore > ConfidenceHighScore {
+		return fmt.Errorf("CONFIDENCE_LOW_SCORE (%g) не може перевищувати CONFIDENCE_HIGH_SCORE (%g)", ConfidenceLowScore, ConfidenceHighScore)
+	}
+
+	contextTokens, err := envInt("OPENAI_CONTEXT_TOKENS", OpenAIContextTokens)
+	if err != nil {
+		return err
+	}
+	completionReserve, err := envInt("OPENAI_COMPLETION_RESERVE", OpenAICompletionReserve)
+	if err != nil {
+		return err
+	}
+	if completionReserve < 0 || completionReserve >= contextTokens {
+		return fmt.Errorf("OPENAI_COMPLETION_RESERVE має бути в межах 0-%d, отримано %d", contextTokens-1, completionReserve)
+	}
+	OpenAIContextTokens, OpenAICompletionReserve = contextTokens, completionReserve
+
+	// Відповідь не може бути довшою за зарезервоване для неї місце у вікні контексту
+	maxTokens, err := envInt("OPENAI_MAX_TOKENS", OpenAIMaxTokens)
+	if err != nil {
+		return err
+	}
+	if maxTokens < 1 || maxTokens > OpenAICompletionReserve {
+		return fmt.Errorf("OPENAI_MAX_TOKENS має бути в межах 1-%d (OPENAI_COMPLETION_RESERVE), отримано %d", OpenAICompletionReserve, maxTokens)
+	}
+	OpenAIMaxTokens = maxTokens
+
+	streamEditInterval, err := envDuration("STREAM_EDIT_INTERVAL", StreamEditInterval)
+	if err != nil {
+		return err
+	}
+	if streamEditInterval < 0 {
+		return fmt.Errorf("STREAM_EDIT_INTERVAL не може бути від'ємним, отримано %v", streamEditInterval)
+	}
+	StreamEditInterval = streamEditInterval
+
+	sessionSaveDelay, err := envDuration("SESSION_SAVE_DELAY", SessionSaveDelay)
+	if err != nil {
+		return err
+	}
+	if sessionSaveDelay < 0 {
+		return fmt.Errorf("SESSION_SAVE_DELAY не може бути від'ємним, отримано %v", sessionSaveDelay)
+	}
+	SessionSaveDelay = sessionSaveDelay
+
+	rerankTopN, err := envInt("RERANK_TOP_N", RerankTopN)
+	if err != nil {
+		return err
+	}
+	if rerankTopN < 1 {
+		return fmt.Errorf("RERANK_TOP_N має бути додатним, отримано %d", rerankTopN)
+	}
+	RerankTopN = rerankTopN
+
+	queryExpansionCount, err := envInt("QUERY_EXPANSION_COUNT", QueryExpansionCount)
+	if err != nil {
+		return err
+	}
+	if queryExpansionCount < 1 || queryExpansionCount > 5 {
+		return fmt.Errorf("QUERY_EXPANSION_COUNT має бути від 1 до 5, отримано %d", queryExpansionCount)
+	}
+	QueryExpansionCount = queryExpansionCount
+
+	toolSearchMaxCalls, err := envInt("TOOL_SEARCH_MAX_CALLS", ToolSearchMaxCalls)
+	if err != nil {
+		return err
+	}
+	if toolSearchMaxCalls < 1 || toolSearchMaxCalls > 10 {
+		return fmt.Errorf("TOOL_SEARCH_MAX_CALLS має бути від 1 до 10, отримано %d", toolSearchMaxCalls)
+	}
+	ToolSearchMaxCalls = toolSearchMaxCalls
+
+	queryCacheSize, err := envInt("QUERY_CACHE_SIZE", QueryCacheSize)
+	if err != nil {
+		return err
+	}
+	if queryCacheSize < 0 {
+		return fmt.Errorf("QUERY_CACHE_SIZE не може бути від'ємним, отримано %d", queryCacheSize)
+	}
+	QueryCacheSize = queryCacheSize
+
+	queryCacheTTL, err := envDuration("QUERY_CACHE_TTL", QueryCacheTTL)
+	if err != nil {
+		return err
+	}
+	if queryCacheTTL < 0 {
+		return fmt.Errorf("QUERY_CACHE_TTL не може бути від'ємним, отримано %v", queryCacheTTL)
+	}
+	QueryCacheTTL = queryCacheTTL
+	initQueryCaches()
+
+	rateLimit, err := envInt("RATE_LIMIT_PER_MINUTE", RateLimitPerMinute)
+	if err != nil {
+		return err
+	}
+	if rateLimit < 0 {
+		return fmt.Errorf("RATE_LIMIT_PER_MINUTE не може бути від'ємним, отримано %d", rateLimit)
+	}
+	RateLimitPerMinute = rateLimit
+
+	for _, quota := range []struct {
+		key    string
+		target *int
+	}{
+		{"USER_MAX_DOCUMENTS", &UserMaxDocuments},
+		{"USER_MAX_VECTORS", &UserMaxVectors},
+	} {
+		value, err := envInt(quota.key, *quota.target)
+		if err != nil {
+			return err
+		}
+		if value < 0 {
+			return fmt.Errorf("%s не може бути від'ємним, отримано %d", quota.key, value)
+		}
+		*quota.target = value
+	}
+
+	concurrentUploads, err := envInt("USER_MAX_CONCURRENT_UPLOADS", UserMaxConcurrentUploads)
+	if err != nil {
+		return err
+	}
+	if concurrentUploads < 1 {
+		return fmt.Errorf("USER_MAX_CONCURRENT_UPLOADS має бути не менше 1, отримано %d", concurrentUploads)
+	}
+	UserMaxConcurrentUploads = concurrentUploads
+
+	// Тарифи для оцінки вартості використання OpenAI
+	for _, rate := range []struct {
+		key   string
+		value *float64
+	}{
+		{"OPENAI_EMBEDDING_COST_PER_1K", &OpenAIEmbeddingCostPer1K},
+		{"OPENAI_PROMPT_COST_PER_1K", &OpenAIPromptCostPer1K},
+		{"OPENAI_COMPLETION_COST_PER_1K", &OpenAICompletionCostPer1K},
+	} {
+		cost, err := envFloat(rate.key, *rate.value)
+		if err != nil {
+			return err
+		}
+		if cost < 0 {
+			return fmt.Errorf("%s не може бути від'ємним, отримано %g", rate.key, cost)
+		}
+		*rate.value = cost
+	}
+
+	// Таймаути окремих запитів до OpenAI, Pinecone та інших HTTP сервісів
+	for _, timeout := range []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"OPENAI_EMBEDDING_TIMEOUT", &OpenAIEmbeddingTimeout},
+		{"OPENAI_CHAT_TIMEOUT", &OpenAIChatTimeout},
+		{"PINECONE_TIMEOUT", &PineconeTimeout},
+		{"PINECONE_UPSERT_TIMEOUT", &PineconeUpsertTimeout},
+		{"PINECONE_BREAKER_COOLDOWN", &PineconeBreakerCooldown},
+		{"HTTP_TIMEOUT", &HTTPTimeout},
+	} {
+		duration, err := envDuration(timeout.key, *timeout.value)
+		if err != nil {
+			return err
+		}
+		if duration <= 0 {
+			return fmt.Errorf("%s має бути додатним, отримано %v", timeout.key, duration)
+		}
+		*timeout.value = duration
+	}
+	setHTTPTimeout(HTTPTimeout)
+
+	breakerThreshold, err := envInt("PINECONE_BREAKER_THRESHOLD", PineconeBreakerThreshold)
+	if err != nil {
+		return err
+	}
+	if breakerThreshold < 0 {
+		return fmt.Errorf("PINECONE_BREAKER_THRESHOLD не може бути від'ємним, отримано %d", breakerThreshold)
+	}
+	PineconeBreakerThreshold = breakerThreshold
+
+	// Модель з OPENAI_MODEL завжди дозволена
+	if !isAllowedModel(OpenAIModel) {
+		OpenAIModelAllowlist = append(OpenAIModelAllowlist, OpenAIModel)
+	}
+
+	adminIDs := make(map[int64]bool)
+	for _, value := range splitList(os.Getenv("ADMIN_IDS")) {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ADMIN_IDS має містити Telegram ID через кому: %v", err)
+		}
+		adminIDs[id] = true
+	}
+	AdminIDs = adminIDs
+
+	retryCount, err := envInt("RETRY_COUNT", RetryCount)
+	if err != nil {
+		return err
+	}
+	if retryCount < 0 {
+		return fmt.Errorf("RETRY_COUNT не може бути від'ємним, отримано %d", retryCount)
+	}
+	RetryCount = retryCount
+
+	retryBaseDelay, err := envDuration("RETRY_BASE_DELAY", RetryBaseDelay)
+	if err != nil {
+		return err
+	}
+	if retryBaseDelay < 0 {
+		return fmt.Errorf("RETRY_BASE_DELAY не може бути від'ємним, отримано %v", retryBaseDelay)
+	}
+	RetryBaseDelay = retryBaseDelay
+
+	return nil
+}
+
+// Розмірність векторів активної моделі ембеддингів з урахуванням OPENAI_EMBEDDING_DIMENSIONS; false - невідома модель
+func embeddingDimension() (int, bool) {
+	if EmbeddingProvider == "openai" && OpenAIEmbeddingDimensions > 0 {
+		return OpenAIEmbeddingDimensions, true
+	}
+	dimension, ok := embeddingModelDimensions[embeddingModel()]
+	return dimension, ok
+}
+
+// Перевіряємо, що розмірність моделі ембеддингів збігається з розмірністю індексу
+func validateEmbeddingDimension(indexDimension int) error {
+	dimension, ok := embeddingDimension()
+	if !ok {
+		slog.Warn("Невідома модель ембеддингів, перевірку розмірності пропущено", "model", embeddingModel())
+		return nil
+	}
+	if dimension != indexDimension {
+		return fmt.Errorf("Модель %s створює вектори розмірності %d, а індекс Pinecone %s має розмірність %d",
+			embeddingModel(), dimension, PineconeIndex, indexDimension)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Рядок CSV: текст вибраних колонок для векторизації та всі колонки як метадані
+type csvRecord struct {
+	Text     string
+	Metadata map[string]interface{}
+}
+
+// Роздільник CSV з CSV_DELIMITER: один символ, \t або tab для табуляції
+func parseCSVDelimiter(value string) (rune, error) {
+	if value == `\t` || strings.EqualFold(value, "tab") {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("CSV_DELIMITER має бути одним символом, отримано %q", value)
+	}
+	delimiter, _ := utf8.DecodeRuneInString(value)
+	if delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+		return 0, fmt.Errorf("CSV_DELIMITER не може бути лапками або переносом рядка")
+	}
+	return delimiter, nil
+}
+
+// Розбираємо CSV: перший рядок - назви колонок, кожен наступний - окремий запис.
+// Текст запису складається з колонок CSVTextColumns (або всіх, якщо їх не задано) у вигляді "колонка: значення".
+func extractCSVRecords(data []byte) ([]csvRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.Comma = CSVDelimiter
+	reader.FieldsPerRecord = -1 // Короткі рядки доповнюємо порожніми значеннями замість помилки
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Помилка розбору CSV: %v", err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("CSV має містити рядок заголовків і хоча б один рядок даних")
+	}
+
+	header := rows[0]
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+
+	// Колонки для тексту: задані в CSV_TEXT_COLUMNS, які є у файлі, або всі
+	var textColumns []int
+	for _, name := range CSVTextColumns {
+		for i, column := range header {
+			if strings.EqualFold(column, name) {
+				textColumns = append(textColumns, i)
+			}
+		}
+	}
+	if len(textColumns) == 0 {
+		for i := range header {
+			textColumns = append(textColumns, i)
+		}
+	}
+
+	var records []csvRecord
+	for _, row := range rows[1:] {
+		metadata := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if column == "" || i >= len(row) {
+				continue
+			}
+			if value := strings.TrimSpace(row[i]); value != "" {
+				metadata[column] = value
+			}
+		}
+
+		var lines []string
+		for _, i := range textColumns {
+			if i >= len(row) {
+				continue
+			}
+			if value := strings.TrimSpace(row[i]); value != "" {
+				lines = append(lines, fmt.Sprintf("%s: %s", header[i], value))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		records = append(records, csvRecord{Text: strings.Join(lines, "\n"), Metadata: metadata})
+	}
+
+	return records, nil
+}
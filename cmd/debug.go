@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Довжина фрагмента тексту кожного збігу у звіті /debug
+const debugSnippetLength = 300
+
+// Обробка /debug <запит>: векторизуємо та шукаємо так само, як для відповіді, але показуємо сирі збіги без GPT.
+// Команда адміністратора: доступ перевіряє handleAdminCommand
+func handleDebug(m telebot.Context) error {
+	ctx := requestContext(m)
+	lang := userLanguage(m)
+	conditions, query := parseQueryFilter(strings.TrimSpace(m.Message().Payload))
+	if query == "" {
+		return m.Send(translate(lang, "debug_usage"))
+	}
+	filter, err := buildMetadataFilter(conditions)
+	if err != nil {
+		return m.Send(translate(lang, "filter_invalid", err))
+	}
+
+	started := time.Now()
+	embedding, err := embedQuery(ctx, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка векторизації запиту", "user_id", m.Sender().ID, "stage", "debug_embedding", "error", err)
+		return m.Send(withRequestID(ctx, translate(lang, "embedding_error", userFacingError(err))))
+	}
+
+	namespaces := searchNamespaces(m.Sender().ID)
+	collections := searchCollectionNames(m.Sender().ID)
+	topK, minScore := userSearchSettings(m.Sender().ID)
+	ctx = contextWithTopK(ctx, topK)
+	matches, err := searchWithExpansion(ctx, collections, namespaces, query, embedding, minScore, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка пошуку в Pinecone", "user_id", m.Sender().ID, "stage", "debug_search", "error", err)
+		return m.Send(withRequestID(ctx, translate(lang, "search_error", err)))
+	}
+
+	slog.InfoContext(ctx, "Адміністратор переглянув збіги запиту", "user_id", m.Sender().ID, "matches", len(matches.Matches))
+	return sendLongMessage(m, debugReport(lang, namespaces, collections, topK, minScore, matches, time.Since(started)))
+}
+
+// Звіт /debug мовою lang: ID, оцінка, файл і початок тексту кожного збігу в порядку Pinecone
+func debugReport(lang string, namespaces, collections []string, topK int, minScore float32, matches *pinecone.QueryVectorsResponse, latency time.Duration) string {
+	var sb strings.Builder
+	labels := make([]string, len(namespaces))
+	for i, namespace := range namespaces {
+		labels[i] = namespaceLabel(namespace)
+	}
+	sb.WriteString(translate(lang, "debug_header", strings.Join(labels, ", "), strings.Join(collections, ", "), minScore, topK, latency.Milliseconds()))
+
+	if len(matches.Matches) == 0 {
+		sb.WriteString(translate(lang, "debug_no_matches"))
+		return sb.String()
+	}
+
+	for i, match := range matches.Matches {
+		if match.Vector == nil {
+			continue
+		}
+		var metadata map[string]interface{}
+		if match.Vector.Metadata != nil {
+			metadata = match.Vector.Metadata.AsMap()
+		}
+		file, _ := metadata["file"].(string)
+		text, _ := metadata["text"].(string)
+
+		fmt.Fprintf(&sb, "\n%d. %s — %.4f\n", i+1, match.Vector.Id, match.Score)
+		if namespace, _ := metadata["namespace"].(string); namespace != "" {
+			fmt.Fprintf(&sb, "Namespace: %s\n", namespace)
+		}
+		if file != "" {
+			if chunk, ok := metadata["chunk"].(float64); ok {
+				sb.WriteString(translate(lang, "debug_file_chunk", file, int(chunk)))
+			} else {
+				sb.WriteString(translate(lang, "debug_file", file))
+			}
+		}
+		fmt.Fprintf(&sb, "%s\n", truncateRunes(strings.Join(strings.Fields(text), " "), debugSnippetLength))
+	}
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Сигнатура OLE-контейнера: так зберігаються захищені паролем .docx
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Витягуємо текст абзаців з word/document.xml, кожен абзац з нового рядка
+func extractTextFromDocx(r io.ReaderAt, size int64) (string, error) {
+	header := make([]byte, len(oleSignature))
+	if _, err := r.ReadAt(header, 0); err == nil && bytes.Equal(header, oleSignature) {
+		return "", fmt.Errorf("Документ захищено паролем, витягнути текст неможливо")
+	}
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("Файл DOCX пошкоджено: %v", err)
+	}
+
+	var document *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("Файл DOCX пошкоджено: відсутній word/document.xml")
+	}
+
+	rc, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("Помилка відкриття word/document.xml: %v", err)
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(rc)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("Помилка розбору word/document.xml: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", fmt.Errorf("Документ не містить тексту")
+	}
+
+	return text, nil
+}
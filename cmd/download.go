@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Ліміт Telegram Bot API на розмір файлу, який бот може завантажити
+const telegramBotAPIFileLimit int64 = 20 << 20
+
+// Файл перевищує MaxUploadBytes або ліміт Telegram
+var errFileTooLarge = errors.New("файл завеликий")
+
+// Повідомлення користувачу мовою lang про завеликий файл (size - розмір у байтах, якщо відомий)
+func fileTooLargeMessage(lang string, size int64) string {
+	limit := float64(MaxUploadBytes) / (1 << 20)
+	if size > 0 {
+		return translate(lang, "file_too_large_size", float64(size)/(1<<20), limit)
+	}
+	return translate(lang, "file_too_large", limit)
+}
+
+// Відкриваємо завантаження файлу з Telegram; тіло відповіді закриває викликач
+func openTelegramFile(bot *telebot.Bot, fileID string) (io.ReadCloser, int64, error) {
+	file, err := bot.FileByID(fileID)
+	if err != nil {
+		// Bot API не віддає файли понад 20 МБ
+		if strings.Contains(strings.ToLower(err.Error()), "file is too big") {
+			return nil, 0, errFileTooLarge
+		}
+		return nil, 0, err
+	}
+
+	resp, err := httpClient.Get(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", TelegramToken, file.FilePath))
+	if err != nil {
+		// Помилка запиту містить URL з токеном бота, тому повертаємо лише причину
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, 0, fmt.Errorf("Помилка завантаження файлу з Telegram: %v", urlErr.Err)
+		}
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("Telegram повернув статус %s під час завантаження файлу", resp.Status)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Завантажуємо невеликий файл з Telegram (наприклад, голосове повідомлення) у пам'ять
+func downloadTelegramFile(bot *telebot.Bot, fileID string) ([]byte, error) {
+	body, _, err := openTelegramFile(bot, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxUploadBytes {
+		return nil, errFileTooLarge
+	}
+	return data, nil
+}
+
+// Потоково завантажуємо файл з Telegram у тимчасовий файл, не тримаючи його в пам'яті.
+// Файл більший за limit не зберігається. Повертаємо файл, відкритий з початку, і його розмір;
+// після обробки його треба прибрати через removeTempFile.
+func downloadTelegramFileToTemp(bot *telebot.Bot, fileID string, limit int64) (*os.File, int64, error) {
+	body, contentLength, err := openTelegramFile(bot, fileID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	if contentLength > limit {
+		return nil, contentLength, errFileTooLarge
+	}
+
+	tmpFile, err := os.CreateTemp("", "aibot-upload-")
+	if err != nil {
+		return nil, 0, fmt.Errorf("Помилка створення тимчасового файлу: %v", err)
+	}
+
+	// Копіюємо на байт більше за ліміт, щоб помітити перевищення без Content-Length
+	size, err := io.Copy(tmpFile, io.LimitReader(body, limit+1))
+	if err != nil {
+		removeTempFile(tmpFile)
+		return nil, 0, fmt.Errorf("Помилка завантаження файлу з Telegram: %v", err)
+	}
+	if size > limit {
+		removeTempFile(tmpFile)
+		return nil, 0, errFileTooLarge
+	}
+
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		removeTempFile(tmpFile)
+		return nil, 0, err
+	}
+	return tmpFile, size, nil
+}
+
+// Закриваємо та видаляємо тимчасовий файл
+func removeTempFile(file *os.File) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Не вдалося видалити тимчасовий файл", "path", file.Name(), "error", err)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Постачальник векторів для тексту. Embed векторизує всі тексти одним запитом
+// і повертає вектори в тому самому порядку, що й тексти.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Активний постачальник векторів (EMBEDDING_PROVIDER), вибирається в initEmbedder
+var embedder Embedder = openAIEmbedder{}
+
+// Вибираємо постачальника векторів за EMBEDDING_PROVIDER
+func initEmbedder() error {
+	switch EmbeddingProvider {
+	case "openai":
+		embedder = openAIEmbedder{}
+	case "ollama":
+		embedder = ollamaEmbedder{baseURL: strings.TrimRight(OllamaURL, "/"), client: httpClient}
+	default:
+		return fmt.Errorf("EMBEDDING_PROVIDER має бути openai або ollama, отримано %q", EmbeddingProvider)
+	}
+	embedder = inputLimitEmbedder{next: embedder}
+	slog.Info("Постачальник векторів", "provider", EmbeddingProvider, "model", embeddingModel(), "dimensions", OpenAIEmbeddingDimensions)
+	return nil
+}
+
+// Модель ембеддингів активного постачальника
+func embeddingModel() string {
+	if EmbeddingProvider == "ollama" {
+		return OllamaEmbeddingModel
+	}
+	return OpenAIEmbeddingModel
+}
+
+// Векторизуємо запит користувача; той самий запит (без урахування регістру та пробілів) повторно не векторизуємо
+func embedQuery(ctx context.Context, query string) ([]float32, error) {
+	cacheKey := normalizeQuery(query)
+	if embedding, ok := queryEmbeddingCache.Get(cacheKey); ok {
+		embeddingCacheHits.Add(1)
+		return embedding, nil
+	}
+
+	started := time.Now()
+	embeddings, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Запит успішно векторизовано", "input_len", len([]rune(query)), "latency_ms", time.Since(started).Milliseconds())
+	queryEmbeddingCache.Add(cacheKey, embeddings[0])
+
+	return embeddings[0], nil
+}
+
+// Перевіряємо, що постачальник повернув по одному вектору на кожен текст
+func checkEmbeddingCount(embeddings [][]float32, texts []string) error {
+	if len(embeddings) != len(texts) {
+		return fmt.Errorf("Отримано %d векторів для %d текстів", len(embeddings), len(texts))
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) == 0 {
+			return fmt.Errorf("Порожній вектор для тексту %d", i)
+		}
+	}
+	return nil
+}
+
+// Ембеддинги OpenAI (або OpenAI-сумісного сервера з OPENAI_BASE_URL)
+type openAIEmbedder struct{}
+
+func (openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	client := newOpenAIClient()
+	request := openai.EmbeddingRequest{
+		Model:      openai.EmbeddingModel(OpenAIEmbeddingModel), // Модель для векторизації зі змінної середовища
+		Input:      texts,
+		Dimensions: OpenAIEmbeddingDimensions, // 0 не передається: повна розмірність моделі
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateEmbeddings", OpenAIEmbeddingTimeout, func(ctx context.Context) (openai.EmbeddingResponse, error) {
+		return client.CreateEmbeddings(ctx, request)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка створення ембеддингів через OpenAI: %w", err)
+	}
+	recordEmbeddingUsage(resp.Usage)
+
+	// Порядок визначаємо за Index, а не за позицією у відповіді
+	embeddings := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("OpenAI повернув вектор з некоректним індексом %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	if err := checkEmbeddingCount(embeddings, texts); err != nil {
+		return nil, fmt.Errorf("OpenAI повернув неповну відповідь: %v", err)
+	}
+	return embeddings, nil
+}
+
+// Ембеддинги локального сервера Ollama (POST /api/embed)
+type ollamaEmbedder struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Помилка HTTP від сервера ембеддингів; статус визначає, чи варто повторювати запит
+type embeddingHTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *embeddingHTTPError) Error() string {
+	return fmt.Sprintf("сервер ембеддингів повернув статус %d: %s", e.StatusCode, e.Body)
+}
+
+func (e ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": OllamaEmbeddingModel,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings, err := withContextTimeoutRetry(ctx, "Ollama Embed", OpenAIEmbeddingTimeout, func(ctx context.Context) ([][]float32, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return nil, &embeddingHTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+		}
+
+		var result struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("некоректна відповідь сервера ембеддингів: %v", err)
+		}
+		return result.Embeddings, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка створення ембеддингів через Ollama: %w", err)
+	}
+
+	if err := checkEmbeddingCount(embeddings, texts); err != nil {
+		return nil, fmt.Errorf("Ollama повернув неповну відповідь: %v", err)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// Позначка моделі ембеддингів для метаданих і реєстру: вектори моделі з іншою розмірністю OPENAI_EMBEDDING_DIMENSIONS теж несумісні
+func embeddingModelTag() string {
+	if EmbeddingProvider == "openai" && OpenAIEmbeddingDimensions > 0 {
+		return fmt.Sprintf("%s:%d", embeddingModel(), OpenAIEmbeddingDimensions)
+	}
+	return embeddingModel()
+}
+
+// Порівнюємо налаштовану модель ембеддингів із записаною в реєстрі (викликати після loadRegistry).
+// Якщо модель змінилася, відмовляємося запускатися або, за EMBEDDING_MODEL_CHANGE=reindex, векторизуємо індекс заново у фоні.
+func checkEmbeddingModel() error {
+	current := embeddingModelTag()
+	recorded := registryEmbeddingModel()
+
+	if recorded == "" {
+		// Перший запуск або реєстр, створений до появи цієї перевірки: модель попередніх векторів невідома
+		if registryHasVectors() {
+			slog.Warn("Модель ембеддингів індексу не записана, вважаємо, що це поточна модель", "model", current)
+		}
+		return setRegistryEmbeddingModel(current)
+	}
+	if recorded == current {
+		return nil
+	}
+
+	if EmbeddingModelChange != "reindex" {
+		return fmt.Errorf("Індекс векторизовано моделлю %s, а налаштовано %s, тож пошук за старими векторами не працюватиме. "+
+			"Поверніть попередню модель, перенесіть документи в новий індекс командою aibot reindex "+
+			"або встановіть EMBEDDING_MODEL_CHANGE=reindex, щоб векторизувати їх заново у фоні", recorded, current)
+	}
+
+	slog.Warn("Модель ембеддингів змінилася, векторизуємо індекс заново у фоні; до завершення пошук може пропускати документи",
+		"previous", recorded, "model", current)
+	go func() {
+		count, err := reembedCollections(current)
+		if err != nil {
+			slog.Error("Повторну векторизацію перервано, вона продовжиться після перезапуску", "vectors", count, "error", err)
+			return
+		}
+		if err := setRegistryEmbeddingModel(current); err != nil {
+			slog.Error("Помилка оновлення реєстру документів", "error", err)
+		}
+		slog.Info("Повторну векторизацію завершено", "model", current, "vectors", count)
+	}()
+	return nil
+}
+
+// Векторизуємо заново всі вектори колекцій, метадані яких не позначені моделлю model.
+// Вектори вже векторизовані цією моделлю пропускаються, тож перервана робота продовжується з місця зупинки.
+func reembedCollections(model string) (int, error) {
+	total := 0
+	for _, collection := range collectionNames() {
+		namespaces, err := collectionNamespaces(collection)
+		if err != nil {
+			return total, err
+		}
+		for _, namespace := range namespaces {
+			count, err := reembedNamespace(collection, namespace, model)
+			total += count
+			if err != nil {
+				return total, err
+			}
+			slog.Info("Namespace векторизовано заново", "collection", collection, "namespace", namespace, "vectors", count)
+		}
+	}
+	return total, nil
+}
+
+// Сторінками перелічуємо вектори namespace і замінюємо значення застарілих векторами нової моделі з тими самими ID
+func reembedNamespace(collection, namespace, model string) (int, error) {
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	limit := uint32(exportPageSize)
+	var token *string
+	for {
+		page, err := withTimeoutRetry("Pinecone ListVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.ListVectorsResponse, error) {
+			return index.ListVectors(ctx, &pinecone.ListVectorsRequest{Limit: &limit, PaginationToken: token})
+		})
+		if err != nil {
+			return count, fmt.Errorf("Помилка переліку векторів: %v", err)
+		}
+
+		ids := make([]string, 0, len(page.VectorIds))
+		for _, id := range page.VectorIds {
+			if id != nil {
+				ids = append(ids, *id)
+			}
+		}
+
+		if len(ids) > 0 {
+			fetched, err := withTimeoutRetry("Pinecone FetchVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+				return index.FetchVectors(ctx, ids)
+			})
+			if err != nil {
+				return count, fmt.Errorf("Помилка отримання векторів: %v", err)
+			}
+
+			var texts []string
+			var stale []exportRecord
+			for _, id := range ids {
+				vector, ok := fetched.Vectors[id]
+				if !ok || vector.Metadata == nil {
+					continue
+				}
+				metadata := vector.Metadata.AsMap()
+				if tag, _ := metadata["embedding_model"].(string); tag == model {
+					continue
+				}
+				text, _ := metadata["text"].(string)
+				if text == "" {
+					slog.Warn("Пропущено вектор без тексту в метаданих", "collection", collection, "namespace", namespace, "id", id)
+					continue
+				}
+				texts = append(texts, text)
+				stale = append(stale, exportRecord{Collection: collection, Namespace: namespace, ID: id, Metadata: metadata})
+			}
+
+			if len(stale) > 0 {
+				embeddings, failed, err := embedChunks(context.Background(), texts, nil)
+				if err != nil {
+					return count, fmt.Errorf("Помилка векторизації вектора %s: %v", stale[failed].ID, err)
+				}
+
+				vectors := make([]*pinecone.Vector, 0, len(stale))
+				for i, record := range stale {
+					if err := checkVectorDimension(embeddings[i]); err != nil {
+						return count, err
+					}
+					record.Metadata["embedding_model"] = model
+					metadata, err := metadataStruct(context.Background(), record.Metadata)
+					if err != nil {
+						return count, fmt.Errorf("Помилка перетворення метаданих вектора %s: %v", record.ID, err)
+					}
+					vectors = append(vectors, &pinecone.Vector{Id: record.ID, Values: embeddings[i], SparseValues: documentSparseVector(collection, texts[i]), Metadata: metadata})
+				}
+
+				if _, err := withTimeoutRetry("Pinecone UpsertVectors", PineconeTimeout, func(ctx context.Context) (uint32, error) {
+					return index.UpsertVectors(ctx, vectors)
+				}); err != nil {
+					return count, fmt.Errorf("Запит UpsertVectors не вдався: %v", err)
+				}
+				count += len(vectors)
+			}
+		}
+
+		if page.NextPaginationToken == nil || *page.NextPaginationToken == "" {
+			return count, nil
+		}
+		token = page.NextPaginationToken
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"unicode/utf8"
+)
+
+// Текст довший за EMBEDDING_MAX_INPUT_TOKENS при EMBEDDING_OVERSIZE_MODE=error
+var errEmbeddingInputTooLong = errors.New("текст завеликий для моделі ембеддингів")
+
+// Обгортка постачальника векторів, що не надсилає тексти, довші за ліміт моделі (EMBEDDING_OVERSIZE_MODE)
+type inputLimitEmbedder struct {
+	next Embedder
+}
+
+func (e inputLimitEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := embeddingModel()
+
+	// Кожен текст стає одним або кількома шматками; spans[i] - діапазон шматків тексту i
+	type span struct{ start, end int }
+	inputs := make([]string, 0, len(texts))
+	spans := make([]span, len(texts))
+	oversized := false
+	for i, text := range texts {
+		pieces := splitByTokens(model, text, EmbeddingMaxInputTokens)
+		if len(pieces) > 1 {
+			oversized = true
+			slog.WarnContext(ctx, "Текст перевищує ліміт моделі ембеддингів", "model", model, "limit_tokens", EmbeddingMaxInputTokens,
+				"pieces", len(pieces), "input_len", utf8.RuneCountInString(text), "mode", EmbeddingOversizeMode)
+
+			switch EmbeddingOversizeMode {
+			case "error":
+				return nil, fmt.Errorf("%w: понад %d токенів (EMBEDDING_MAX_INPUT_TOKENS)", errEmbeddingInputTooLong, EmbeddingMaxInputTokens)
+			case "truncate":
+				pieces = pieces[:1]
+			}
+		}
+		spans[i] = span{start: len(inputs), end: len(inputs) + len(pieces)}
+		inputs = append(inputs, pieces...)
+	}
+	if !oversized {
+		return e.next.Embed(ctx, texts)
+	}
+
+	embeddings, err := e.next.Embed(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Вектори шматків одного тексту усереднюємо з вагою за довжиною шматка
+	result := make([][]float32, len(texts))
+	for i, s := range spans {
+		if s.end-s.start == 1 {
+			result[i] = embeddings[s.start]
+			continue
+		}
+		weights := make([]float64, 0, s.end-s.start)
+		for _, piece := range inputs[s.start:s.end] {
+			weights = append(weights, float64(utf8.RuneCountInString(piece)))
+		}
+		result[i] = averageEmbeddings(embeddings[s.start:s.end], weights)
+	}
+	return result, nil
+}
+
+// Зважене середнє векторів, нормалізоване до одиничної довжини, як і вектори моделей ембеддингів
+func averageEmbeddings(embeddings [][]float32, weights []float64) []float32 {
+	sum := make([]float64, len(embeddings[0]))
+	for i, embedding := range embeddings {
+		for j, value := range embedding {
+			sum[j] += float64(value) * weights[i]
+		}
+	}
+
+	norm := 0.0
+	for _, value := range sum {
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+
+	average := make([]float32, len(sum))
+	for j, value := range sum {
+		if norm > 0 {
+			value /= norm
+		}
+		average[j] = float32(value)
+	}
+	return average
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+)
+
+// Спільний для всіх завантажень ліміт одночасних запитів векторизації (EMBEDDING_WORKERS).
+// Запити користувачів його не використовують, тож велике завантаження не блокує відповіді.
+var embeddingSlots = make(chan struct{}, EmbeddingWorkers)
+
+// Створюємо пул на size одночасних запитів векторизації
+func initEmbeddingPool(size int) {
+	embeddingSlots = make(chan struct{}, size)
+}
+
+// Векторизуємо тексти пакетами по EmbeddingBatchSize паралельно в межах спільного пулу, зберігаючи порядок результатів.
+// onDone (може бути nil) отримує кількість уже векторизованих текстів.
+// При помилці решта пакетів не обробляється; повертаємо індекс першого тексту пакета, що не вдався,
+// і вже отримані вектори (для невекторизованих текстів - nil), щоб їх можна було зберегти.
+// Після скасування ctx нові пакети не видаються, а функція повертає ctx.Err().
+func embedChunks(ctx context.Context, texts []string, onDone func(done int)) ([][]float32, int, error) {
+	embeddings := make([][]float32, len(texts))
+
+	jobs := make(chan int) // Індекс першого тексту пакета
+	stop := make(chan struct{})
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		done      int
+		failed    = -1
+		failedErr error
+	)
+
+	batches := (len(texts) + EmbeddingBatchSize - 1) / EmbeddingBatchSize
+	workers := min(cap(embeddingSlots), batches)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range jobs {
+				batch := texts[start:min(start+EmbeddingBatchSize, len(texts))]
+
+				embeddingSlots <- struct{}{}
+				batchEmbeddings, err := embedder.Embed(ctx, batch)
+				<-embeddingSlots
+
+				mu.Lock()
+				if err != nil {
+					if failed < 0 && ctx.Err() == nil {
+						failed, failedErr = start, err
+						close(stop)
+					}
+					mu.Unlock()
+					continue
+				}
+				copy(embeddings[start:], batchEmbeddings)
+				done += len(batch)
+				if onDone != nil {
+					onDone(done)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Видаємо пакети, доки не виникла помилка
+dispatch:
+	for start := 0; start < len(texts); start += EmbeddingBatchSize {
+		select {
+		case jobs <- start:
+		case <-stop:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, -1, err
+	}
+	if failed >= 0 {
+		return embeddings, failed, failedErr
+	}
+	return embeddings, -1, nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Інструкція для перефразування запиту перед пошуком
+const queryExpansionPrompt = `Ти допомагаєш шукати в базі знань. Сформулюй %d альтернативні формулювання запиту користувача:
+перефразування або підпитання, які можуть знайти потрібні фрагменти, якщо запит короткий чи нечіткий.
+Пиши тією самою мовою, що й запит. Відповідай лише JSON-об'єктом {"queries": [...]}.`
+
+// Перефразування запиту моделлю QUERY_EXPANSION_MODEL; якщо не вдалося, повертаємо nil
+func expandQuery(ctx context.Context, query string) []string {
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model: QueryExpansionModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: fmt.Sprintf(queryExpansionPrompt, QueryExpansionCount)},
+			{Role: openai.ChatMessageRoleUser, Content: query},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (expansion)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Розширення запиту не вдалося, шукаємо лише за початковим запитом", "error", err)
+		return nil
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return nil
+	}
+	var result struct {
+		Queries []string `json:"queries"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		slog.WarnContext(ctx, "Некоректна відповідь із перефразуваннями запиту", "error", err)
+		return nil
+	}
+
+	// Порожні формулювання та повтори початкового запиту нічого не додають до пошуку
+	var expansions []string
+	seen := map[string]bool{normalizeQuery(query): true}
+	for _, expansion := range result.Queries {
+		expansion = strings.TrimSpace(expansion)
+		if expansion == "" || seen[normalizeQuery(expansion)] {
+			continue
+		}
+		seen[normalizeQuery(expansion)] = true
+		expansions = append(expansions, expansion)
+		if len(expansions) == QueryExpansionCount {
+			break
+		}
+	}
+	return expansions
+}
+
+// Пошук за запитом, а з QUERY_EXPANSION_ENABLED - ще й за його перефразуваннями.
+// Збіги всіх пошуків зливаються за namespace та ID вектора з найкращою оцінкою, і залишаються searchTopK найкращих
+// з урахуванням MAX_CHUNKS_PER_FILE.
+func searchWithExpansion(ctx context.Context, collections, namespaces []string, query string, embedding []float32, minScore float32, filter *structpb.Struct) (*pinecone.QueryVectorsResponse, error) {
+	matches, err := searchCollections(contextWithKeywords(ctx, query), collections, namespaces, embedding, minScore, filter)
+	if !QueryExpansionEnabled || err != nil {
+		return matches, err
+	}
+
+	started := time.Now()
+	expansions := expandQuery(ctx, query)
+	if len(expansions) == 0 {
+		return matches, nil
+	}
+	slog.InfoContext(ctx, "Запит розширено", "expansions", expansions)
+
+	embeddings, err := embedder.Embed(ctx, expansions)
+	if err != nil {
+		slog.WarnContext(ctx, "Помилка векторизації перефразувань запиту", "error", err)
+		return matches, nil
+	}
+
+	responses := []*pinecone.QueryVectorsResponse{matches}
+	for i, expansion := range expansions {
+		response, err := searchCollections(contextWithKeywords(ctx, expansion), collections, namespaces, embeddings[i], minScore, filter)
+		if err != nil {
+			slog.WarnContext(ctx, "Помилка пошуку за перефразуванням запиту", "expansion", expansion, "error", err)
+			continue
+		}
+		responses = append(responses, response)
+	}
+
+	result := &pinecone.QueryVectorsResponse{Namespace: matches.Namespace, Usage: matches.Usage}
+	result.Matches = mergeMatches(responses, MaxChunksPerFile, searchTopK(ctx))
+
+	slog.InfoContext(ctx, "Збіги пошуку з розширенням запиту", "original", len(matches.Matches), "merged", len(result.Matches),
+		"latency_ms", time.Since(started).Milliseconds())
+	return result, nil
+}
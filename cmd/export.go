@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"github.com/spf13/cobra"
+)
+
+// Скільки ID запитуємо за одну сторінку ListVectors і вектори за один FetchVectors
+const exportPageSize = 100
+
+// Рядок файлу експорту: один вектор разом із колекцією та namespace, звідки його взято
+type exportRecord struct {
+	Collection string                 `json:"collection"`
+	Namespace  string                 `json:"namespace"`
+	ID         string                 `json:"id"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Values     []float32              `json:"values,omitempty"`
+}
+
+// Підкоманда aibot export <файл>: вивантажує всі вектори з метаданими у JSONL для резервної копії або міграції
+var exportCmd = &cobra.Command{
+	Use:   "export <файл>",
+	Short: "Експортувати всі вектори з метаданими з Pinecone у JSONL файл.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := setupLogging(); err != nil {
+			fatal("Некоректні налаштування логування", "error", err)
+		}
+		if PineconeAPIKey == "" {
+			fatal("Відсутня змінна середовища PINECONE_API_KEY.")
+		}
+		if err := loadConfig(); err != nil {
+			fatal("Некоректні налаштування", "error", err)
+		}
+		if err := initPinecone(); err != nil {
+			fatal("Не вдалося підключитися до Pinecone", "error", err)
+		}
+
+		collection, _ := cmd.Flags().GetString("collection")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		withValues, _ := cmd.Flags().GetBool("values")
+
+		collections := collectionNames()
+		if collection != "" {
+			if !isKnownCollection(collection) {
+				fatal("Невідома колекція", "collection", collection)
+			}
+			collections = []string{collection}
+		}
+
+		count, err := exportVectors(args[0], collections, namespace, cmd.Flags().Changed("namespace"), withValues)
+		if err != nil {
+			fatal("Помилка експорту", "file", args[0], "exported", count, "error", err)
+		}
+		fmt.Printf("Експортовано векторів: %d у файл %s\n", count, args[0])
+	},
+}
+
+// Записуємо вектори колекцій у файл path. Якщо onlyNamespace false, експортуємо всі namespace індексу.
+func exportVectors(path string, collections []string, namespace string, onlyNamespace, withValues bool) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("Помилка створення файлу експорту: %v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	total := 0
+	for _, collection := range collections {
+		namespaces := []string{namespace}
+		if !onlyNamespace {
+			namespaces, err = collectionNamespaces(collection)
+			if err != nil {
+				return total, err
+			}
+		}
+
+		for _, ns := range namespaces {
+			count, err := exportNamespace(encoder, collection, ns, withValues)
+			total += count
+			if err != nil {
+				return total, err
+			}
+			slog.Info("Експортовано namespace", "collection", collection, "namespace", ns, "vectors", count)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return total, fmt.Errorf("Помилка запису файлу експорту: %v", err)
+	}
+	return total, file.Close()
+}
+
+// Namespace індексу колекції за статистикою, за абеткою
+func collectionNamespaces(collection string) ([]string, error) {
+	index, err := pineconeIndex(collection, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := withTimeoutRetry("Pinecone DescribeIndexStats", PineconeTimeout, func(ctx context.Context) (*pinecone.DescribeIndexStatsResponse, error) {
+		return index.DescribeIndexStats(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка отримання статистики індексу: %v", err)
+	}
+
+	namespaces := make([]string, 0, len(stats.Namespaces))
+	for namespace := range stats.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// Сторінками перелічуємо ID namespace, дочитуємо вектори та записуємо їх у encoder
+func exportNamespace(encoder *json.Encoder, collection, namespace string, withValues bool) (int, error) {
+	index, err := pineconeIndex(collection, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	limit := uint32(exportPageSize)
+	var token *string
+	for {
+		page, err := withTimeoutRetry("Pinecone ListVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.ListVectorsResponse, error) {
+			return index.ListVectors(ctx, &pinecone.ListVectorsRequest{Limit: &limit, PaginationToken: token})
+		})
+		if err != nil {
+			return count, fmt.Errorf("Помилка переліку векторів: %v", err)
+		}
+
+		ids := make([]string, 0, len(page.VectorIds))
+		for _, id := range page.VectorIds {
+			if id != nil {
+				ids = append(ids, *id)
+			}
+		}
+
+		if len(ids) > 0 {
+			fetched, err := withTimeoutRetry("Pinecone FetchVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+				return index.FetchVectors(ctx, ids)
+			})
+			if err != nil {
+				return count, fmt.Errorf("Помилка отримання векторів: %v", err)
+			}
+
+			// Зберігаємо порядок сторінки, щоб експорт був відтворюваним
+			for _, id := range ids {
+				vector, ok := fetched.Vectors[id]
+				if !ok {
+					continue
+				}
+				record := exportRecord{Collection: collection, Namespace: namespace, ID: id}
+				if vector.Metadata != nil {
+					record.Metadata = vector.Metadata.AsMap()
+				}
+				if withValues {
+					record.Values = vector.Values
+				}
+				if err := encoder.Encode(record); err != nil {
+					return count, fmt.Errorf("Помилка запису файлу експорту: %v", err)
+				}
+				count++
+			}
+			slog.Info("Експортовано сторінку векторів", "collection", collection, "namespace", namespace, "exported", count)
+		}
+
+		if page.NextPaginationToken == nil || *page.NextPaginationToken == "" {
+			return count, nil
+		}
+		token = page.NextPaginationToken
+	}
+}
+
+func init() {
+	aibotCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("collection", "", "Експортувати лише одну колекцію (типово - усі)")
+	exportCmd.Flags().String("namespace", "", "Експортувати лише один namespace (типово - усі)")
+	exportCmd.Flags().Bool("values", false, "Додати значення векторів (без них імпорт векторизує текст заново)")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+const (
+	feedbackCallbackPrefix = "feedback" // Префікс даних кнопок відгуку: feedback|<id>|up або feedback|<id>|down
+	feedbackPendingSize    = 1000       // Скільки останніх відповідей чекають на відгук
+	feedbackPendingTTL     = 24 * time.Hour
+)
+
+// Відповідь, на яку користувач ще може залишити відгук
+type feedbackContext struct {
+	UserID    int64
+	Query     string
+	Answer    string
+	VectorIDs []string
+	Model     string
+}
+
+// Запис відгуку у файлі FeedbackPath (один JSON на рядок)
+type feedbackRecord struct {
+	Time      time.Time `json:"time"`
+	UserID    int64     `json:"user_id"`
+	Rating    string    `json:"rating"` // up або down
+	Query     string    `json:"query"`
+	Answer    string    `json:"answer"`
+	VectorIDs []string  `json:"vector_ids"`
+	Model     string    `json:"model"`
+}
+
+var (
+	// Відповіді з кнопками відгуку за ID з даних кнопки
+	pendingFeedback = newLRUCache[feedbackContext](feedbackPendingSize, feedbackPendingTTL)
+
+	// Запис у файл відгуків по одному
+	feedbackFileMu sync.Mutex
+
+	// Загальна кількість відгуків для /stats (разом із записаними до перезапуску)
+	feedbackUp   atomic.Int64
+	feedbackDown atomic.Int64
+)
+
+// Рахуємо відгуки, вже записані у файл
+func loadFeedbackStats(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Помилка читання відгуків: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var record feedbackRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		switch record.Rating {
+		case "up":
+			feedbackUp.Add(1)
+		case "down":
+			feedbackDown.Add(1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Помилка читання відгуків: %v", err)
+	}
+	return nil
+}
+
+// Кнопки 👍/👎 під відповіддю; контекст відповіді зберігається до натискання
+func feedbackMarkup(userID int64, query, answer string, vectorIDs []string, model string) *telebot.ReplyMarkup {
+	id, err := callbackID()
+	if err != nil {
+		slog.Warn("Не вдалося створити ID відгуку", "error", err)
+		return nil
+	}
+
+	pendingFeedback.Add(id, feedbackContext{UserID: userID, Query: query, Answer: answer, VectorIDs: vectorIDs, Model: model})
+
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		telebot.Btn{Text: "👍", Data: strings.Join([]string{feedbackCallbackPrefix, id, "up"}, "|")},
+		telebot.Btn{Text: "👎", Data: strings.Join([]string{feedbackCallbackPrefix, id, "down"}, "|")},
+	))
+	return markup
+}
+
+// Випадковий ID для даних inline-кнопки: не повторюється після перезапуску, на відміну від лічильника
+func callbackID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Обробка натискання кнопки відгуку: записуємо оцінку та прибираємо кнопки
+func handleFeedbackCallback(c telebot.Context, data string) error {
+	ctx := requestContext(c)
+	lang := userLanguage(c)
+	parts := strings.Split(data, "|")
+	if len(parts) != 3 || (parts[2] != "up" && parts[2] != "down") {
+		return c.Respond(&telebot.CallbackResponse{Text: translate(lang, "feedback_invalid")})
+	}
+	id, rating := parts[1], parts[2]
+
+	feedback, ok := pendingFeedback.Get(id)
+	if !ok || feedback.UserID != c.Sender().ID {
+		return c.Respond(&telebot.CallbackResponse{Text: translate(lang, "feedback_expired")})
+	}
+	pendingFeedback.Remove(id)
+
+	record := feedbackRecord{
+		Time:      time.Now().UTC(),
+		UserID:    feedback.UserID,
+		Rating:    rating,
+		Query:     feedback.Query,
+		Answer:    feedback.Answer,
+		VectorIDs: feedback.VectorIDs,
+		Model:     feedback.Model,
+	}
+	if err := appendFeedback(record); err != nil {
+		slog.ErrorContext(ctx, "Помилка запису відгуку", "user_id", feedback.UserID, "error", err)
+		return c.Respond(&telebot.CallbackResponse{Text: translate(lang, "feedback_error")})
+	}
+	if rating == "up" {
+		feedbackUp.Add(1)
+	} else {
+		feedbackDown.Add(1)
+	}
+	slog.InfoContext(ctx, "Отримано відгук на відповідь", "user_id", feedback.UserID, "rating", rating)
+
+	// Прибираємо кнопки, щоб не можна було проголосувати вдруге
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+		slog.WarnContext(ctx, "Не вдалося прибрати кнопки відгуку", "error", err)
+	}
+
+	return c.Respond(&telebot.CallbackResponse{Text: translate(lang, "feedback_thanks")})
+}
+
+// Дописуємо відгук у файл FeedbackPath
+func appendFeedback(record feedbackRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	feedbackFileMu.Lock()
+	defer feedbackFileMu.Unlock()
+
+	file, err := os.OpenFile(FeedbackPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Рядок про відгуки для звіту /stats
+func feedbackReport() string {
+	return fmt.Sprintf("Відгуки: 👍 %d, 👎 %d\n", feedbackUp.Load(), feedbackDown.Load())
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Префікси запиту, які перетворюються на фільтр за метаданими: префікс -> поле метаданих
+var queryFilterFields = map[string]string{
+	"file":   "file",
+	"type":   "type",
+	"author": "author",
+}
+
+// Тип документа для метаданих і фільтра type: розширення файлу без крапки
+func documentType(fileName string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+}
+
+// Відокремлюємо фільтри на початку запиту, наприклад `file:resume.pdf type:pdf що він уміє?`.
+// Значення з пробілами беруться в лапки: file:"my resume.pdf". Повертаємо умови фільтра та решту запиту.
+func parseQueryFilter(query string) (map[string][]string, string) {
+	conditions := make(map[string][]string)
+	rest := strings.TrimSpace(query)
+
+	for {
+		key, value, remainder, ok := cutFilterPrefix(rest)
+		if !ok {
+			break
+		}
+		if key == "type" {
+			value = strings.TrimPrefix(strings.ToLower(value), ".")
+		}
+		conditions[queryFilterFields[key]] = append(conditions[queryFilterFields[key]], value)
+		rest = strings.TrimSpace(remainder)
+	}
+
+	return conditions, rest
+}
+
+// Відрізаємо один префікс key:value з початку тексту
+func cutFilterPrefix(text string) (key, value, rest string, ok bool) {
+	key, after, found := strings.Cut(text, ":")
+	if !found || strings.ContainsAny(key, " \t\n") {
+		return "", "", "", false
+	}
+	key = strings.ToLower(key)
+	if _, supported := queryFilterFields[key]; !supported {
+		return "", "", "", false
+	}
+
+	if strings.HasPrefix(after, `"`) {
+		end := strings.Index(after[1:], `"`)
+		if end < 0 {
+			return "", "", "", false
+		}
+		value, rest = after[1:end+1], after[end+2:]
+	} else {
+		value, rest, _ = strings.Cut(after, " ")
+	}
+	if value == "" {
+		return "", "", "", false
+	}
+
+	return key, value, rest, true
+}
+
+// Формуємо фільтр Pinecone з умов: одне значення - $eq, кілька - $in; різні поля поєднуються через І
+func buildMetadataFilter(conditions map[string][]string) (*structpb.Struct, error) {
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	fields := make(map[string]interface{}, len(conditions))
+	for field, values := range conditions {
+		if len(values) == 1 {
+			fields[field] = map[string]interface{}{"$eq": values[0]}
+			continue
+		}
+		in := make([]interface{}, len(values))
+		for i, value := range values {
+			in[i] = value
+		}
+		fields[field] = map[string]interface{}{"$in": in}
+	}
+
+	filter, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, fmt.Errorf("Помилка створення фільтра: %v", err)
+	}
+	return filter, nil
+}
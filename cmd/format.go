@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Вбудовані елементи Markdown, які перетворюються на теги Telegram HTML
+var (
+	markdownInlineCode = regexp.MustCompile("`([^`\n]+)`")
+	markdownLink       = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+	markdownBoldStar   = regexp.MustCompile(`\*\*([^\s*](?:[^\n]*?[^\s])?)\*\*`)
+	markdownBoldLine   = regexp.MustCompile(`(^|[^\p{L}\p{N}_])__([^\s_](?:[^\n]*?[^\s_])?)__([^\p{L}\p{N}_]|$)`)
+	markdownStrike     = regexp.MustCompile(`~~([^~\n]+?)~~`)
+	markdownItalicStar = regexp.MustCompile(`(^|[^\p{L}\p{N}_*])\*([^*\s](?:[^*\n]*?[^*\s])?)\*([^\p{L}\p{N}_*]|$)`)
+	markdownItalicLine = regexp.MustCompile(`(^|[^\p{L}\p{N}_])_([^_\s](?:[^_\n]*?[^_\s])?)_([^\p{L}\p{N}_]|$)`)
+	markdownIdentifier = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	markdownHeadingRe  = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	markdownBulletRe   = regexp.MustCompile(`^(\s*)[*+-]\s+`)
+)
+
+// Перетворюємо Markdown відповіді GPT на HTML, який розуміє Telegram (ParseMode HTML).
+// Весь текст поза тегами екранується, тож випадкові <, > та & не ламають розмітку.
+func markdownToTelegramHTML(text string) string {
+	var out []string
+	var code []string
+	inCode := false
+	language := ""
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if !inCode {
+				inCode, language, code = true, strings.TrimSpace(strings.TrimPrefix(trimmed, "```")), nil
+				continue
+			}
+			out = append(out, codeBlockHTML(language, code))
+			inCode = false
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+		out = append(out, markdownLineToHTML(line))
+	}
+
+	// Незакритий блок коду (наприклад, обрізана відповідь) все одно показуємо як код
+	if inCode {
+		out = append(out, codeBlockHTML(language, code))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// Блок коду з необов'язковою мовою підсвічування
+func codeBlockHTML(language string, lines []string) string {
+	body := html.EscapeString(strings.Join(lines, "\n"))
+	if language == "" {
+		return "<pre>" + body + "</pre>"
+	}
+	return fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, html.EscapeString(language), body)
+}
+
+// Перетворюємо один рядок поза блоком коду
+func markdownLineToHTML(line string) string {
+	// Заголовки Telegram не підтримує, тож робимо їх жирними
+	if match := markdownHeadingRe.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+		return "<b>" + inlineMarkdownToHTML(match[1]) + "</b>"
+	}
+	line = markdownBulletRe.ReplaceAllString(line, "$1• ")
+	return inlineMarkdownToHTML(line)
+}
+
+// Перетворюємо вбудовану розмітку; вміст `коду` не обробляється як Markdown
+func inlineMarkdownToHTML(text string) string {
+	// Ховаємо код за заповнювачами, щоб зірочки та підкреслення в ньому не стали форматуванням
+	var spans []string
+	text = markdownInlineCode.ReplaceAllStringFunc(text, func(match string) string {
+		spans = append(spans, "<code>"+html.EscapeString(match[1:len(match)-1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	text = html.EscapeString(text)
+	text = markdownLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	// Спершу жирний, щоб вкладений курсив (**a *b* c**) лишився всередині тегу
+	text = markdownBoldStar.ReplaceAllString(text, "<b>$1</b>")
+	text = replaceDelimited(markdownBoldLine, text, func(groups []string) string {
+		// __init__ та інші імена з коду - не форматування
+		if markdownIdentifier.MatchString(groups[2]) {
+			return groups[0]
+		}
+		return groups[1] + "<b>" + groups[2] + "</b>" + groups[3]
+	})
+	text = markdownStrike.ReplaceAllString(text, "<s>$1</s>")
+	text = replaceDelimited(markdownItalicStar, text, func(groups []string) string {
+		return groups[1] + "<i>" + groups[2] + "</i>" + groups[3]
+	})
+	text = replaceDelimited(markdownItalicLine, text, func(groups []string) string {
+		return groups[1] + "<i>" + groups[2] + "</i>" + groups[3]
+	})
+
+	for i, span := range spans {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return text
+}
+
+// Замінюємо збіги re з межами слова в групах 1 і 3. Межа поглинається збігом, тож сусідні
+// виділення ("_a_ _b_") знаходяться лише з наступного проходу; повторюємо, доки текст змінюється.
+func replaceDelimited(re *regexp.Regexp, text string, replace func(groups []string) string) string {
+	for {
+		replaced := re.ReplaceAllStringFunc(text, func(match string) string {
+			return replace(re.FindStringSubmatch(match))
+		})
+		if replaced == text {
+			return text
+		}
+		text = replaced
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestMarkdownToTelegramHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bold", "**важливо**", "<b>важливо</b>"},
+		{"bold with nested italic", "**a *b* c**", "<b>a <i>b</i> c</b>"},
+		{"italic with nested bold", "*a **b** c*", "<i>a <b>b</b> c</i>"},
+		{"italic star", "це *курсив* тут", "це <i>курсив</i> тут"},
+		{"multiplication is not italic", "2*3*4", "2*3*4"},
+		{"underscore bold", "це __жирний текст__ тут", "це <b>жирний текст</b> тут"},
+		{"underscore italic", "_a_ і _b_", "<i>a</i> і <i>b</i>"},
+		{"dunder identifier", "метод __init__ класу", "метод __init__ класу"},
+		{"snake_case", "змінна snake_case_name", "змінна snake_case_name"},
+		{"snake_case in Cyrillic", "поле дата_створення_документа", "поле дата_створення_документа"},
+		{"double underscores inside word", "my__var__name", "my__var__name"},
+		{"inline code keeps markup", "виклик `a*b*c` і `__init__`", "виклик <code>a*b*c</code> і <code>__init__</code>"},
+		{"inline code is escaped", "`<div>`", "<code>&lt;div&gt;</code>"},
+		{"fenced code", "```\n**не жирний**\n```", "<pre>**не жирний**</pre>"},
+		{"fenced code with language", "```go\nx := a * b\n```", `<pre><code class="language-go">x := a * b</code></pre>`},
+		{"unclosed fence", "текст\n```\nкод", "текст\n<pre>код</pre>"},
+		{"escaping", "a < b & c", "a &lt; b &amp; c"},
+		{"heading", "## Заголовок", "<b>Заголовок</b>"},
+		{"bullet", "* пункт", "• пункт"},
+		{"link", "[сайт](https://example.com)", `<a href="https://example.com">сайт</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToTelegramHTML(tt.in); got != tt.want {
+				t.Errorf("markdownToTelegramHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
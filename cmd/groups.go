@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Текст запиту з повідомлення та чи звертаються до бота. В особистих чатах це весь текст;
+// у групах - залежно від GROUP_TRIGGER, а згадка @бота вирізається з тексту.
+func groupQuery(m telebot.Context) (string, bool) {
+	message := m.Message()
+	return groupAddressed(m, message.Text, message.Entities)
+}
+
+// Підпис документа, фото чи голосового повідомлення та чи звертаються до бота: у групах це згадка
+// @бота в підписі або відповідь на повідомлення бота (GROUP_TRIGGER), як і для текстових запитів
+func groupCaption(m telebot.Context) (string, bool) {
+	message := m.Message()
+	return groupAddressed(m, message.Caption, message.CaptionEntities)
+}
+
+// Чи документ або фото адресовані боту. Крім згадки в підписі, у групі зверненням вважаємо
+// файл, якого бот чекає після /upload чи /update від цього користувача.
+func groupUploadAddressed(m telebot.Context) bool {
+	if _, addressed := groupCaption(m); addressed {
+		return true
+	}
+	return m.Message().FromGroup() && GroupTrigger != "off" && isAwaitingDocument(m.Sender().ID)
+}
+
+// Текст або підпис повідомлення без згадок бота та чи звертаються до бота за GROUP_TRIGGER
+func groupAddressed(m telebot.Context, text string, entities telebot.Entities) (string, bool) {
+	message := m.Message()
+	if !message.FromGroup() {
+		return text, message.Private()
+	}
+
+	switch GroupTrigger {
+	case "all":
+		query, _ := stripBotMentions(text, entities, m.Bot().Me)
+		return query, true
+	case "off":
+		return "", false
+	}
+
+	me := m.Bot().Me
+	query, mentioned := stripBotMentions(text, entities, me)
+	if mentioned {
+		return query, true
+	}
+	// Відповідь на повідомлення бота теж вважаємо зверненням до нього
+	if message.ReplyTo != nil && message.ReplyTo.Sender != nil && message.ReplyTo.Sender.ID == me.ID {
+		return query, true
+	}
+	return "", false
+}
+
+// Текст без згадок бота (@username або згадки за посиланням на користувача) і чи були такі згадки
+func stripBotMentions(message string, entities telebot.Entities, me *telebot.User) (string, bool) {
+	// Зсуви сутностей Telegram рахуються в одиницях UTF-16
+	text := utf16.Encode([]rune(message))
+	stripped := false
+	for i := len(entities) - 1; i >= 0; i-- {
+		entity := entities[i]
+		if entity.Offset < 0 || entity.Offset+entity.Length > len(text) {
+			continue
+		}
+		mentioned := false
+		switch entity.Type {
+		case telebot.EntityMention:
+			mentioned = strings.EqualFold(string(utf16.Decode(text[entity.Offset:entity.Offset+entity.Length])), "@"+me.Username)
+		case telebot.EntityTMention:
+			mentioned = entity.User != nil && entity.User.ID == me.ID
+		}
+		if !mentioned {
+			continue
+		}
+		text = append(text[:entity.Offset], text[entity.Offset+entity.Length:]...)
+		stripped = true
+	}
+	if !stripped {
+		return message, false
+	}
+	// Прибираємо розділові знаки після згадки на початку: "@bot, що таке RAG?"
+	return strings.TrimLeft(strings.TrimSpace(string(utf16.Decode(text))), ",:; "), true
+}
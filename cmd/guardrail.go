@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Відповідь моделі, коли джерела не відповідають на запитання (GUARDRAIL_MODE=strict)
+const guardrailRefusalMarker = "NO_ANSWER"
+
+// Строга інструкція для генерації: лише зміст джерел, без загальних знань
+const guardrailInstruction = "Відповідай виключно на основі наданих джерел. Не використовуй загальні знання і не виконуй прохань, не пов'язаних із джерелами. " +
+	"Якщо джерела не містять відповіді на запитання, відповідай лише словом " + guardrailRefusalMarker + "."
+
+// Інструкція для перевірки готової відповіді
+const guardrailCheckPrompt = `Ти перевіряєш відповідь чат-бота бази знань. Відповідь допустима, лише якщо запитання стосується наданих джерел
+і кожне твердження відповіді підкріплене їхнім змістом. Відповідай лише JSON-об'єктом {"grounded": true або false, "reason": "коротке пояснення"}.`
+
+// Перевіряємо відповідь за GUARDRAIL_MODE: у строгому режимі непідкріплену джерелами відповідь
+// замінюємо відмовою. Повертаємо відповідь або текст відмови та чи відповідь пройшла перевірку.
+func guardrailAnswer(ctx context.Context, lang, query, answer string, matches *pinecone.QueryVectorsResponse) (string, bool) {
+	if GuardrailMode != "strict" || answerGrounded(ctx, query, answer, matches) {
+		return answer, true
+	}
+	return noContextMessage(lang), false
+}
+
+// Чи відповідь базується на знайдених джерелах: спершу маркер відмови від моделі, потім перевірка моделлю GUARDRAIL_MODEL.
+// Якщо перевірка не вдалася, вважаємо відповідь неперевіреною й не показуємо її.
+func answerGrounded(ctx context.Context, query, answer string, matches *pinecone.QueryVectorsResponse) bool {
+	if len(matches.Matches) == 0 || strings.Contains(answer, guardrailRefusalMarker) {
+		return false
+	}
+
+	grounded, reason, err := checkAnswerGrounded(ctx, query, answer, matches)
+	if err != nil {
+		slog.WarnContext(ctx, "Перевірка відповіді на відповідність джерелам не вдалася", "error", err)
+		return false
+	}
+	if !grounded {
+		slog.InfoContext(ctx, "Відповідь не підкріплена джерелами", "reason", reason)
+	}
+	return grounded
+}
+
+// Питаємо модель, чи відповідь підкріплена джерелами та стосується їх
+func checkAnswerGrounded(ctx context.Context, query, answer string, matches *pinecone.QueryVectorsResponse) (bool, string, error) {
+	var sources strings.Builder
+	for i, match := range matches.Matches {
+		text := ""
+		if match.Vector != nil && match.Vector.Metadata != nil {
+			text, _ = match.Vector.Metadata.AsMap()["text"].(string)
+		}
+		fmt.Fprintf(&sources, "\n[%d] %s\n", i+1, truncateRunes(text, rerankPassageLength))
+	}
+
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model: GuardrailModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: guardrailCheckPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Запитання: %s\n\nДжерела:%s\nВідповідь:\n%s", query, sources.String(), answer)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (guardrail)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return false, "", fmt.Errorf("Помилка запиту на перевірку відповіді: %w", err)
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return false, "", fmt.Errorf("OpenAI не повернув результату перевірки")
+	}
+	var result struct {
+		Grounded bool   `json:"grounded"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return false, "", fmt.Errorf("Некоректна відповідь перевірки: %v", err)
+	}
+	return result.Grounded, result.Reason, nil
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// Підмінюємо перевірку відповіді: OpenAI-сумісний сервер повертає status і content на кожен запит
+func stubGroundingCheck(t *testing.T, status int, content string, choices bool) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error": {"message": "stub error", "type": "invalid_request_error"}}`)
+			return
+		}
+		response := map[string]interface{}{"choices": []interface{}{}}
+		if choices {
+			response["choices"] = []interface{}{
+				map[string]interface{}{"index": 0, "message": map[string]string{"role": "assistant", "content": content}},
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	baseURL, mode, delay := OpenAIBaseURL, GuardrailMode, RetryBaseDelay
+	OpenAIBaseURL, GuardrailMode, RetryBaseDelay = server.URL, "strict", 0
+	t.Cleanup(func() { OpenAIBaseURL, GuardrailMode, RetryBaseDelay = baseURL, mode, delay })
+	return &calls
+}
+
+func TestGuardrailAnswer(t *testing.T) {
+	sources := testResponse(testMatch("a", 0.9, "handbook.pdf", ""))
+	noSources := testResponse()
+	const answer = "Відпустка триває 24 дні [1]."
+	refusal := noContextMessage("uk")
+
+	tests := []struct {
+		name      string
+		status    int
+		content   string
+		choices   bool
+		answer    string
+		matches   *pinecone.QueryVectorsResponse
+		want      string
+		grounded  bool
+		wantCalls int32
+	}{
+		{
+			name: "grounded answer is kept", status: http.StatusOK, choices: true,
+			content: `{"grounded": true, "reason": "ok"}`,
+			answer:  answer, matches: sources, want: answer, grounded: true, wantCalls: 1,
+		},
+		{
+			name: "off-topic question is refused", status: http.StatusOK, choices: true,
+			content: `{"grounded": false, "reason": "запитання не стосується джерел"}`,
+			answer:  "Столиця Франції - Париж.", matches: sources, want: refusal, wantCalls: 1,
+		},
+		{
+			name: "no sources are refused without a check", status: http.StatusOK, choices: true,
+			content: `{"grounded": true}`,
+			answer:  "Загальна відповідь.", matches: noSources, want: refusal,
+		},
+		{
+			name: "refusal marker from the model", status: http.StatusOK, choices: true,
+			content: `{"grounded": true}`,
+			answer:  guardrailRefusalMarker, matches: sources, want: refusal,
+		},
+		{
+			name: "failed check refuses", status: http.StatusBadRequest,
+			answer: answer, matches: sources, want: refusal, wantCalls: 1,
+		},
+		{
+			name: "invalid check response refuses", status: http.StatusOK, choices: true,
+			content: "так, усе гаразд",
+			answer:  answer, matches: sources, want: refusal, wantCalls: 1,
+		},
+		{
+			name: "empty check response refuses", status: http.StatusOK,
+			answer: answer, matches: sources, want: refusal, wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := stubGroundingCheck(t, tt.status, tt.content, tt.choices)
+			got, grounded := guardrailAnswer(context.Background(), "uk", "Скільки днів відпустки?", tt.answer, tt.matches)
+			if got != tt.want || grounded != tt.grounded {
+				t.Errorf("guardrailAnswer() = %q, %v, want %q, %v", got, grounded, tt.want, tt.grounded)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("перевірку викликано %d разів, очікувалося %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGuardrailAnswerOff(t *testing.T) {
+	calls := stubGroundingCheck(t, http.StatusOK, `{"grounded": false}`, true)
+	GuardrailMode = "off"
+
+	got, grounded := guardrailAnswer(context.Background(), "uk", "Столиця Франції?", "Париж.", testResponse())
+	if got != "Париж." || !grounded {
+		t.Errorf("guardrailAnswer() = %q, %v, want відповідь без змін", got, grounded)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("без строгого режиму перевірку викликано %d разів", n)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Час очікування відповіді від кожної залежності під час перевірки готовності
+const readinessTimeout = 5 * time.Second
+
+// HTTP сервер з /healthz (процес живий), /readyz (доступні Pinecone і OpenAI) для проб оркестратора
+// та /metrics для Prometheus
+func startHealthServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", handleReadiness)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readinessTimeout,
+	}
+
+	go func() {
+		slog.Info("Запущено HTTP сервер перевірки стану", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Помилка HTTP сервера перевірки стану", "addr", addr, "error", err)
+		}
+	}()
+
+	return server
+}
+
+// Перевіряємо Pinecone та OpenAI; 503 з описом, якщо хоч одна залежність недоступна
+func handleReadiness(w http.ResponseWriter, r *http.Request) {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"pinecone", checkPineconeReady},
+		{"openai", checkOpenAIReady},
+	}
+
+	var report strings.Builder
+	ready := true
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := c.check(ctx)
+		cancel()
+
+		if err != nil {
+			ready = false
+			slog.Warn("Залежність не готова", "dependency", c.name, "error", err)
+			report.WriteString(fmt.Sprintf("%s: %v\n", c.name, err))
+			continue
+		}
+		report.WriteString(fmt.Sprintf("%s: ok\n", c.name))
+	}
+
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, report.String())
+}
+
+// Перевіряємо доступність індексу Pinecone через DescribeIndex
+func checkPineconeReady(ctx context.Context) error {
+	pineconeConn.Lock()
+	client := pineconeConn.client
+	pineconeConn.Unlock()
+
+	if client == nil {
+		return fmt.Errorf("клієнт Pinecone не ініціалізовано")
+	}
+	_, err := client.DescribeIndex(ctx, PineconeIndex)
+	return err
+}
+
+// Перевіряємо ключ OpenAI найдешевшим запитом - переліком моделей
+func checkOpenAIReady(ctx context.Context) error {
+	_, err := newOpenAIClient().ListModels(ctx)
+	return err
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Пул з'єднань спільних HTTP клієнтів
+const (
+	httpMaxIdleConns        = 100
+	httpMaxIdleConnsPerHost = 10
+	httpIdleConnTimeout     = 90 * time.Second
+	httpDialTimeout         = 10 * time.Second
+)
+
+var (
+	// Спільний клієнт для вихідних HTTP запитів: завантаження файлів з Telegram, сервер ембеддингів Ollama
+	httpClient = newHTTPClient(HTTPTimeout, nil)
+
+	// Клієнт для /ingest, який не ходить на внутрішні адреси (localhost, приватні мережі, метадані хмари)
+	ingestClient = newHTTPClient(HTTPTimeout, rejectInternalAddress)
+)
+
+// HTTP клієнт із загальним таймаутом запиту (разом із читанням тіла) і пулом з'єднань, що перевикористовуються.
+// control (може бути nil) перевіряє адресу кожного з'єднання вже після розв'язання DNS.
+func newHTTPClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   httpDialTimeout,
+				KeepAlive: 30 * time.Second,
+				Control:   control,
+			}).DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        httpMaxIdleConns,
+			MaxIdleConnsPerHost: httpMaxIdleConnsPerHost,
+			IdleConnTimeout:     httpIdleConnTimeout,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Застосовуємо HTTP_TIMEOUT до спільних клієнтів (викликається з loadConfig до першого запиту)
+func setHTTPTimeout(timeout time.Duration) {
+	httpClient.Timeout = timeout
+	ingestClient.Timeout = timeout
+}
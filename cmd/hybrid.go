@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Параметр насичення частоти слова, як у BM25: повтори слова в частині дають дедалі менший внесок
+const sparseTermSaturation = 1.2
+
+// Поширені слова, які є майже в кожній частині й лише розмивають пошук за ключовими словами
+var sparseStopWords = map[string]bool{
+	"і": true, "й": true, "та": true, "в": true, "у": true, "на": true, "з": true, "із": true, "до": true, "за": true,
+	"що": true, "як": true, "це": true, "не": true, "по": true, "від": true, "для": true, "про": true, "чи": true, "а": true,
+	"the": true, "a": true, "an": true, "and": true, "or": true, "of": true, "to": true, "in": true, "on": true, "is": true,
+	"are": true, "for": true, "with": true, "what": true, "how": true, "it": true, "this": true, "that": true, "be": true,
+}
+
+// Ключ ваги векторного пошуку в context.Context
+type denseWeightKey struct{}
+
+// Ключ тексту запиту для пошуку за ключовими словами в context.Context
+type keywordQueryKey struct{}
+
+// Гібридний пошук увімкнено, якщо частина ваги припадає на ключові слова
+func hybridSearchEnabled() bool {
+	return HybridSearchAlpha < 1
+}
+
+// Метрика нових індексів: розріджені вектори Pinecone підтримує лише для dotproduct
+func indexMetric() pinecone.IndexMetric {
+	if hybridSearchEnabled() {
+		return pinecone.Dotproduct
+	}
+	return pinecone.Cosine
+}
+
+// Контекст пошуку, у якому текст query шукається ще й за ключовими словами
+func contextWithKeywords(ctx context.Context, query string) context.Context {
+	return context.WithValue(ctx, keywordQueryKey{}, query)
+}
+
+// Текст запиту для пошуку за ключовими словами з контексту
+func keywordQuery(ctx context.Context) (string, bool) {
+	query, ok := ctx.Value(keywordQueryKey{}).(string)
+	return query, ok
+}
+
+// Контекст пошуку з власною вагою векторного пошуку замість HYBRID_SEARCH_ALPHA
+func contextWithDenseWeight(ctx context.Context, alpha float64) context.Context {
+	return context.WithValue(ctx, denseWeightKey{}, alpha)
+}
+
+// Вага векторного пошуку з контексту або HYBRID_SEARCH_ALPHA
+func denseWeight(ctx context.Context) float64 {
+	if alpha, ok := ctx.Value(denseWeightKey{}).(float64); ok {
+		return alpha
+	}
+	return HybridSearchAlpha
+}
+
+// Слова тексту в нижньому регістрі без розділових знаків, коротких слів і стоп-слів
+func sparseTerms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, field := range fields {
+		if sparseStopWords[field] {
+			continue
+		}
+		// Однолітерні слова відкидаємо, але цифри залишаємо: вони бувають частиною номерів і кодів
+		if first, size := utf8.DecodeRuneInString(field); size == len(field) && !unicode.IsDigit(first) {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// Індекс виміру розрідженого вектора для слова (хешування ознак замість словника)
+func sparseIndex(term string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(term))
+	return hash.Sum32()
+}
+
+// Розріджений вектор тексту для пошуку за ключовими словами. Частина документа отримує вагу слова
+// за насиченою частотою (як TF у BM25), запит - однакову вагу для кожного слова. Вектор нормується,
+// тож скалярний добуток векторів запиту та частини лежить у межах 0-1, як і косинусна подібність.
+// Повертаємо nil, якщо в тексті немає жодного ключового слова.
+func sparseVector(text string, query bool) *pinecone.SparseValues {
+	counts := make(map[uint32]float64)
+	for _, term := range sparseTerms(text) {
+		counts[sparseIndex(term)]++
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	sparse := &pinecone.SparseValues{
+		Indices: make([]uint32, 0, len(counts)),
+		Values:  make([]float32, 0, len(counts)),
+	}
+	var norm float64
+	weights := make([]float64, 0, len(counts))
+	for index, count := range counts {
+		weight := 1.0
+		if !query {
+			weight = count * (sparseTermSaturation + 1) / (count + sparseTermSaturation)
+		}
+		sparse.Indices = append(sparse.Indices, index)
+		weights = append(weights, weight)
+		norm += weight * weight
+	}
+	norm = math.Sqrt(norm)
+	for _, weight := range weights {
+		sparse.Values = append(sparse.Values, float32(weight/norm))
+	}
+	return sparse
+}
+
+// Чи індекс колекції підтримує розріджені вектори (лише метрика dotproduct)
+func sparseSupported(collection string) bool {
+	pineconeConn.Lock()
+	defer pineconeConn.Unlock()
+
+	index, ok := pineconeConn.indexes[collection]
+	return ok && index.metric == pinecone.Dotproduct
+}
+
+// Розріджений вектор частини для індексу колекції, якщо індекс підтримує пошук за ключовими словами
+func documentSparseVector(collection, text string) *pinecone.SparseValues {
+	if !sparseSupported(collection) {
+		return nil
+	}
+	return sparseVector(text, false)
+}
+
+// Зважуємо векторну та ключову частини запиту: оцінка dotproduct-індексу тоді дорівнює
+// alpha*векторна подібність + (1-alpha)*збіг ключових слів. Вектор запиту не змінюємо, бо його кешовано.
+func hybridQuery(embedding []float32, sparse *pinecone.SparseValues, alpha float64) ([]float32, *pinecone.SparseValues) {
+	dense := make([]float32, len(embedding))
+	for i, value := range embedding {
+		dense[i] = value * float32(alpha)
+	}
+	weighted := &pinecone.SparseValues{
+		Indices: sparse.Indices,
+		Values:  make([]float32, len(sparse.Values)),
+	}
+	for i, value := range sparse.Values {
+		weighted.Values[i] = value * float32(1-alpha)
+	}
+	return dense, weighted
+}
+
+// Обробка /keyword <запит>: шукаємо лише за ключовими словами, без векторної подібності.
+// Допомагає знайти точні назви, номери та абревіатури, які векторний пошук пропускає.
+func handleKeywordSearch(m telebot.Context) error {
+	ctx := requestContext(m)
+	lang := userLanguage(m)
+	conditions, query := parseQueryFilter(strings.TrimSpace(m.Message().Payload))
+	if query == "" {
+		return m.Send(translate(lang, "keyword_usage"))
+	}
+	if sparseVector(query, true) == nil {
+		return m.Send(translate(lang, "keyword_no_terms"))
+	}
+	filter, err := buildMetadataFilter(conditions)
+	if err != nil {
+		return m.Send(translate(lang, "filter_invalid", err))
+	}
+
+	started := time.Now()
+	namespaces := searchNamespaces(m.Sender().ID)
+	// Без векторної частини запит до індексу без розріджених векторів шукав би за нульовим вектором
+	collections := slices.DeleteFunc(searchCollectionNames(m.Sender().ID), func(collection string) bool {
+		return !sparseSupported(collection)
+	})
+	if len(collections) == 0 {
+		return m.Send(translate(lang, "keyword_unsupported"))
+	}
+	topK, _ := userSearchSettings(m.Sender().ID)
+	ctx = contextWithDenseWeight(contextWithKeywords(contextWithTopK(ctx, topK), query), 0)
+
+	// Векторна частина має нульову вагу, тож запит не векторизуємо; нульова оцінка означає відсутність спільних слів
+	embedding := make([]float32, pineconeConn.dimension)
+	matches, err := searchCollections(ctx, collections, namespaces, embedding, math.SmallestNonzeroFloat32, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка пошуку в Pinecone", "user_id", m.Sender().ID, "stage", "keyword_search", "error", err)
+		return m.Send(withRequestID(ctx, translate(lang, "search_error", err)))
+	}
+
+	slog.InfoContext(ctx, "Пошук за ключовими словами", "user_id", m.Sender().ID, "matches", len(matches.Matches))
+	return sendLongMessage(m, debugReport(lang, namespaces, collections, topK, 0, matches, time.Since(started)))
+}
@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Мова інтерфейсу за замовчуванням
+const defaultLanguage = "uk"
+
+// Каталог повідомлень інтерфейсу за кодом мови; відсутні ключі беруться з defaultLanguage
+var messageCatalog = map[string]map[string]string{
+	"uk": {
+		"start":                      "Цей чат-бот створений для надавання інформації про людину та її трудовий досвід. Версія чат-боту: %s",
+		"timeout":                    "Сервіс не відповів вчасно. Спробуйте ще раз трохи пізніше.",
+		"awaiting_document":          "Я чекаю на файл. Надішліть документ або скористайтеся /cancel, щоб скасувати.",
+		"rate_limited":               "Забагато запитів. Будь ласка, зачекайте хвилину і спробуйте знову.",
+		"filter_needs_query":         "Після фільтра потрібно вказати запит, наприклад: file:resume.pdf який у нього досвід?",
+		"filter_invalid":             "Некоректний фільтр запиту: %v",
+		"embedding_error":            "Помилка у генерації вектору: %v",
+		"kb_unavailable":             "База знань тимчасово недоступна. Спробуйте ще раз за кілька хвилин.",
+		"kb_empty":                   "База знань порожня. Спершу завантажте документи (PDF, DOCX, JSON, CSV, TXT або MD).",
+		"no_matches":                 "На жаль, у базі знань немає інформації, яка відповідає на це запитання. Спробуйте сформулювати його інакше.",
+		"general_knowledge":          "ℹ️ У завантажених документах немає відповіді на це запитання, тому відповідь базується на загальних знаннях моделі й може бути неточною.",
+		"generation_error":           "GPT-4 не зміг згенерувати відповідь: %v",
+		"answer_truncated":           "✂️ Відповідь обрізано через обмеження довжини. Напишіть «продовжуй», щоб отримати продовження.",
+		"confidence_high":            "🟢 Впевненість: висока",
+		"confidence_medium":          "🟡 Впевненість: середня",
+		"confidence_low":             "🔴 Впевненість: низька",
+		"low_confidence":             "⚠️ Знайдені джерела лише частково стосуються запитання, тож ця відповідь може бути неточною.",
+		"query_empty":                "Будь ласка, введіть запит.",
+		"query_too_short":            "Запит закороткий (мінімальна довжина: %d). Напишіть запитання докладніше, наприклад: який у нього досвід?",
+		"query_no_text":              "Запит має містити слова. Напишіть запитання текстом, наприклад: який у нього досвід?",
+		"unknown_command":            "Невідома команда. Щоб поставити запитання, напишіть його без «/» на початку.",
+		"openai_quota":               "Сервіс відповідей тимчасово недоступний. Спробуйте пізніше або зверніться до адміністратора бота.",
+		"openai_rate_limit":          "Сервіс відповідей зараз перевантажений. Спробуйте ще раз за хвилину.",
+		"openai_auth":                "Сервіс відповідей неправильно налаштований. Зверніться до адміністратора бота.",
+		"update_unchanged":           "%s не змінився, у векторній базі нічого не оновлено.",
+		"update_done":                "%s оновлено у векторній базі: нових частин %d, видалених %d, без змін %d.",
+		"voice_download_error":       "Помилка завантаження голосового повідомлення: %v",
+		"voice_error":                "Не вдалося розпізнати голосове повідомлення: %v",
+		"voice_empty":                "Не вдалося розібрати слова в голосовому повідомленні. Спробуйте ще раз.",
+		"voice_transcript":           "🎙 Ваш запит: %s",
+		"upload_prompt":              "Надішліть документ (PDF, DOCX, JSON, CSV, TXT або MD). Щоб передумати, скористайтеся /cancel.",
+		"upload_queued":              "Документ %s у черзі: перед ним обробляється ще %d. Щоб скасувати, надішліть /cancel.",
+		"upload_cancelled_file":      "Завантаження %s скасовано.",
+		"upload_rejected":            "Не вдалося обробити %s: %v. Надішліть файл ще раз трохи пізніше.",
+		"file_too_large_size":        "Файл завеликий (%.1f МБ). Максимальний розмір - %.0f МБ. Розділіть документ на менші частини або стисніть його.",
+		"file_too_large":             "Файл завеликий. Максимальний розмір - %.0f МБ. Розділіть документ на менші частини або стисніть його.",
+		"quota_exceeded":             "Квоту бази знань вичерпано: %s. Видаліть непотрібні документи через /delete <файл> (перелік - /list) і спробуйте ще раз.",
+		"quota_documents":            "документів %d з %d",
+		"quota_vectors":              "частин %d з %d",
+		"file_download_error":        "Помилка завантаження файлу: %v",
+		"file_read_error":            "Помилка читання файлу: %v",
+		"unknown_format":             "Невідомий формат файлу. Завантажте, будь ласка, тільки PDF, DOCX, JSON, CSV, TXT або MD.",
+		"type_detected":              "Файл %s розпізнано за вмістом як %s.",
+		"stage_pdf":                  "Витягуємо текст з PDF…",
+		"stage_ocr":                  "PDF не має текстового шару, розпізнаємо текст (OCR)…",
+		"stage_docx":                 "Витягуємо текст з DOCX…",
+		"stage_records":              "Розбиваємо записи на частини…",
+		"stage_rows":                 "Розбиваємо рядки на частини…",
+		"stage_chunking":             "Розбиваємо текст на частини…",
+		"stage_page":                 "Завантажуємо сторінку…",
+		"stage_images":               "Розпізнаємо вміст зображень…",
+		"stage_upsert":               "Додаємо у Pinecone пакет %d (частин: %d)…",
+		"progress_embedded":          "Векторизовано частин",
+		"upload_progress":            "%s: %d з %d",
+		"pdf_error":                  "Помилка обробки PDF файла: %v",
+		"pdf_upload_error":           "Помилка завантаження PDF у векторну базу: %v",
+		"pdf_ocr_note":               " Текст розпізнано за допомогою OCR, тож можливі неточності.",
+		"docx_error":                 "Помилка обробки DOCX файла: %v",
+		"docx_upload_error":          "Помилка завантаження DOCX у векторну базу: %v",
+		"json_error":                 "Помилка обробки JSON файла.",
+		"json_empty":                 "JSON не містить текстових даних для векторизації.",
+		"json_upload_error":          "Помилка завантаження даних з JSON у Pinecone: %v",
+		"csv_not_utf8":               "CSV не є коректним текстом у кодуванні UTF-8. Збережіть його в UTF-8 і спробуйте ще раз.",
+		"csv_error":                  "Помилка обробки CSV файла: %v",
+		"csv_empty":                  "CSV не містить текстових даних для векторизації.",
+		"csv_upload_error":           "Помилка завантаження даних з CSV у Pinecone: %v",
+		"text_not_utf8":              "Файл не є коректним текстом у кодуванні UTF-8. Збережіть його в UTF-8 і спробуйте ще раз.",
+		"file_upload_error":          "Помилка завантаження файлу у векторну базу: %v",
+		"page_error":                 "Не вдалося завантажити сторінку: %v",
+		"page_empty":                 "Сторінка не містить тексту для векторизації.",
+		"page_upload_error":          "Помилка завантаження сторінки у векторну базу: %v",
+		"image_download_error":       "Помилка завантаження зображення: %v",
+		"image_extract_error":        "Не вдалося розпізнати вміст зображення: %v",
+		"image_upload_error":         "Помилка завантаження зображення у векторну базу: %v",
+		"vision_error":               "Не вдалося проаналізувати зображення: %v",
+		"photo_index_button":         "📥 Додати до бази знань",
+		"photo_index_expired":        "Ці зображення вже неможливо додати.",
+		"photo_index_started":        "Додаємо зображення до бази знань…",
+		"kind_json":                  "JSON (записів: %d)",
+		"kind_csv":                   "CSV (рядків: %d)",
+		"kind_file":                  "Файл %s (%s)",
+		"kind_page":                  "Сторінку %s",
+		"kind_image":                 "Зображення %s",
+		"upload_present":             "%s вже є у векторній базі, нових частин не додано.",
+		"upload_done":                "%s успішно завантажено та додано до векторної бази (частин: %d).",
+		"upload_done_duplicates":     "%s успішно завантажено та додано до векторної бази (нових частин: %d, вже наявних: %d).",
+		"upload_resume_hint":         "\nВстигли додати частин: %d з %d. Повторіть завантаження - додані частини буде пропущено, і воно продовжиться з місця зупинки.",
+		"upload_cancelled":           "Завантаження скасовано. Встигли додати частин: %d.",
+		"cancel_nothing":             "Немає чого скасовувати.",
+		"cancel_uploads":             "Скасовано завантажень: %d.",
+		"cancel_awaiting":            "Гаразд, більше не чекаю на документ. Можете ставити запитання.",
+		"ingest_usage":               "Вкажіть посилання: /ingest <url>",
+		"delete_usage":               "Вкажіть назву файлу: /delete <файл>",
+		"delete_error":               "Помилка видалення файлу: %v",
+		"delete_not_found":           "Файл %s не знайдено у векторній базі.",
+		"delete_done":                "Файл %s видалено. Видалено векторів: %d.",
+		"model_current":              "Поточна модель: %s\nДоступні моделі: %s",
+		"model_invalid":              "%v. Доступні моделі: %s",
+		"model_changed":              "Модель змінено на %s.",
+		"index_stats_error":          "Помилка отримання статистики індексу: %v",
+		"list_empty":                 "Документів у реєстрі немає. Векторів в індексі: %d.",
+		"list_header":                "Документів: %d, векторів в індексі: %d\n\n",
+		"list_entry":                 "• %s — частин: %d\n",
+		"collection_all_label":       "усі колекції",
+		"collection_status":          "Пошук: %s\nКолекції:\n%s\nЩоб обрати колекцію, надішліть /collection <назва>, щоб шукати в усіх - /collection all.",
+		"collection_entry":           "%s %s (індекс %s)\n",
+		"collection_all":             "Шукаємо в усіх колекціях. Нові документи потраплятимуть у колекцію за їхнім типом.",
+		"collection_unknown":         "Невідома колекція %s. Доступні колекції: %s",
+		"collection_selected":        "Обрано колекцію %s: пошук і нові документи стосуватимуться лише її.",
+		"reset_done":                 "Історію розмови очищено. Можете починати нову розмову.",
+		"update_prompt":              "Надішліть нову версію документа з тією самою назвою файлу: змінені частини буде векторизовано заново, а застарілі видалено з бази. Також можна надіслати файл з підписом update. Щоб передумати, скористайтеся /cancel.",
+		"keyword_usage":              "Використання: /keyword <слова>. Знаходить частини документів, що містять ці слова, наприклад назви, номери чи абревіатури.",
+		"keyword_no_terms":           "У запиті немає ключових слів для пошуку: вкажіть назви, номери чи інші значущі слова.",
+		"keyword_unsupported":        "Пошук за ключовими словами потребує індексу з метрикою dotproduct, а індекси вибраних колекцій її не мають. Створіть такий індекс командою aibot init-index або перенесіть документи командою aibot reindex --create.",
+		"search_error":               "Помилка пошуку в Pinecone: %v",
+		"debug_usage":                "Використання: /debug <запит>. Можна додати фільтри, як у звичайному запиті, наприклад file:resume.pdf.",
+		"debug_header":               "🔎 Namespace: %s, колекції: %s\nПоріг оцінки: %.2f, TopK: %d, час пошуку: %d мс\n",
+		"debug_no_matches":           "\nЗбігів немає.",
+		"debug_file_chunk":           "Файл: %s (частина %d)\n",
+		"debug_file":                 "Файл: %s\n",
+		"settings_current":           "Налаштування пошуку:\ntopk: %d (типово %d)\nthreshold: %.2f (типово %.2f)\n\nЗмінити: /set topk <1-%d>, /set threshold <0-1>. Повернути типове значення: /set topk %s.",
+		"settings_usage":             "Використання: /set topk <1-%d> або /set threshold <0-1>. Без аргументів - поточні значення.",
+		"settings_topk_default":      "topk повернуто до типового значення %d.",
+		"settings_topk_invalid":      "topk має бути цілим числом від 1 до %d.",
+		"settings_topk_set":          "topk = %d: пошук повертатиме до %d фрагментів.",
+		"settings_threshold_default": "threshold повернуто до типового значення %.2f.",
+		"settings_threshold_invalid": "threshold має бути числом від 0 до 1, наприклад 0.7.",
+		"settings_threshold_set":     "threshold = %.2f: фрагменти з нижчою оцінкою відкидатимуться.",
+		"settings_unknown":           "Невідоме налаштування %s. Доступні: topk, threshold.",
+		"summarize_usage":            "Вкажіть назву файлу: /summarize <файл>. Перелік ваших документів - /list.",
+		"summarize_fetch_error":      "Не вдалося отримати текст документа: %v",
+		"summarize_no_text":          "У векторній базі не знайдено тексту файлу %s. Можливо, його вже видалено.",
+		"summarize_cancelled":        "Підсумок скасовано.",
+		"summarize_error":            "Не вдалося підсумувати документ: %v",
+		"summarize_similar":          "Файл %s не знайдено. Можливо, ви мали на увазі: %s",
+		"summarize_not_found":        "Файл %s не знайдено серед ваших документів. Перелік завантажених документів - /list.",
+		"feedback_invalid":           "Некоректний відгук.",
+		"feedback_expired":           "Відгук для цієї відповіді вже неможливо залишити.",
+		"feedback_error":             "Не вдалося зберегти відгук, спробуйте пізніше.",
+		"feedback_thanks":            "Дякуємо за відгук!",
+		"source_chunk":               "%s, частина %d",
+		"source_expired":             "Цей фрагмент уже недоступний. Поставте запитання ще раз.",
+		"inline_document":            "Документ",
+		"inline_empty_title":         "Нічого не знайдено",
+		"inline_empty_description":   "У базі знань немає релевантних фрагментів для цього запиту.",
+		"inline_empty_text":          "Не знайдено релевантних збігів у базі знань.",
+		"preview_header":             "Попередній перегляд (у базу нічого не додано).\n\nФайл: %s\nКолекція: %s\nNamespace: %s\nЧастин: %d",
+		"preview_duplicates":         " (з них уже є в базі: %d)",
+		"preview_first":              "\n\nПерша частина:\n%s",
+		"preview_last":               "\n\nОстання частина (%d):\n%s",
+		"preview_metadata":           "\n\nМетадані першої частини:\n%s",
+		"yes":                        "так",
+		"no":                         "ні",
+		"whoami_all_collections":     "усі (%s)",
+		"whoami_report":              "Адміністратор: %s\nЗбережених пар запит-відповідь в історії: %d з %d\nОчікується документ: %s\nNamespace завантажень: %s\nNamespace пошуку: %s\nКолекції пошуку: %s\nПошук: topk %d, threshold %.2f",
+		"admin_only":                 "Вибачте, ця команда доступна лише адміністраторам.",
+		"help_commands":              "Доступні команди:\n",
+		"help_admin_commands":        "\nКоманди адміністратора:\n",
+		"help_footer":                "\nЩоб поставити запитання, просто надішліть його текстом або голосовим повідомленням.",
+	},
+	"en": {
+		"start":                      "This chatbot answers questions about a person and their work experience. Chatbot version: %s",
+		"timeout":                    "The service did not respond in time. Please try again a bit later.",
+		"awaiting_document":          "I'm waiting for a file. Send a document or use /cancel to cancel.",
+		"rate_limited":               "Too many requests. Please wait a minute and try again.",
+		"filter_needs_query":         "Add a question after the filter, for example: file:resume.pdf what is their experience?",
+		"filter_invalid":             "Invalid query filter: %v",
+		"embedding_error":            "Failed to create the query vector: %v",
+		"kb_unavailable":             "The knowledge base is temporarily unavailable. Please try again in a few minutes.",
+		"kb_empty":                   "The knowledge base is empty. Upload documents first (PDF, DOCX, JSON, CSV, TXT or MD).",
+		"no_matches":                 "Unfortunately, the knowledge base has no information that answers this question. Try rephrasing it.",
+		"general_knowledge":          "ℹ️ The uploaded documents don't answer this question, so this answer is based on the model's general knowledge and may be inaccurate.",
+		"generation_error":           "The model could not generate an answer: %v",
+		"answer_truncated":           "✂️ The answer was cut off because of the length limit. Write \"continue\" to get the rest.",
+		"confidence_high":            "🟢 Confidence: high",
+		"confidence_medium":          "🟡 Confidence: medium",
+		"confidence_low":             "🔴 Confidence: low",
+		"low_confidence":             "⚠️ The sources found only partly match the question, so this answer may be inaccurate.",
+		"query_empty":                "Please enter a question.",
+		"query_too_short":            "The question is too short (minimum length: %d). Please add more detail, for example: what is their experience?",
+		"query_no_text":              "The question must contain words. Write it as text, for example: what is their experience?",
+		"unknown_command":            "Unknown command. To ask a question, write it without a leading \"/\".",
+		"openai_quota":               "The answering service is temporarily unavailable. Please try again later or contact the bot administrator.",
+		"openai_rate_limit":          "The answering service is overloaded right now. Please try again in a minute.",
+		"openai_auth":                "The answering service is misconfigured. Please contact the bot administrator.",
+		"update_unchanged":           "%s has not changed, nothing was updated in the vector database.",
+		"update_done":                "%s updated in the vector database: new chunks %d, removed %d, unchanged %d.",
+		"voice_download_error":       "Failed to download the voice message: %v",
+		"voice_error":                "Could not recognize the voice message: %v",
+		"voice_empty":                "Could not make out any words in the voice message. Please try again.",
+		"voice_transcript":           "🎙 Your question: %s",
+		"upload_prompt":              "Send a document (PDF, DOCX, JSON, CSV, TXT or MD). To change your mind, use /cancel.",
+		"upload_queued":              "Document %s is queued: %d more are being processed before it. To cancel, send /cancel.",
+		"upload_cancelled_file":      "Upload of %s cancelled.",
+		"upload_rejected":            "Could not process %s: %v. Please send the file again a bit later.",
+		"file_too_large_size":        "The file is too large (%.1f MB). The maximum size is %.0f MB. Split the document into smaller parts or compress it.",
+		"file_too_large":             "The file is too large. The maximum size is %.0f MB. Split the document into smaller parts or compress it.",
+		"quota_exceeded":             "The knowledge base quota is used up: %s. Delete documents you no longer need with /delete <file> (see /list) and try again.",
+		"quota_documents":            "documents %d of %d",
+		"quota_vectors":              "chunks %d of %d",
+		"file_download_error":        "Failed to download the file: %v",
+		"file_read_error":            "Failed to read the file: %v",
+		"unknown_format":             "Unknown file format. Please upload only PDF, DOCX, JSON, CSV, TXT or MD.",
+		"type_detected":              "File %s was recognized by its content as %s.",
+		"stage_pdf":                  "Extracting text from the PDF…",
+		"stage_ocr":                  "The PDF has no text layer, recognizing the text (OCR)…",
+		"stage_docx":                 "Extracting text from the DOCX…",
+		"stage_records":              "Splitting records into chunks…",
+		"stage_rows":                 "Splitting rows into chunks…",
+		"stage_chunking":             "Splitting text into chunks…",
+		"stage_page":                 "Downloading the page…",
+		"stage_images":               "Recognizing the image contents…",
+		"stage_upsert":               "Adding batch %d to Pinecone (chunks: %d)…",
+		"progress_embedded":          "Chunks embedded",
+		"upload_progress":            "%s: %d of %d",
+		"pdf_error":                  "Failed to process the PDF file: %v",
+		"pdf_upload_error":           "Failed to add the PDF to the vector database: %v",
+		"pdf_ocr_note":               " The text was recognized with OCR, so it may contain errors.",
+		"docx_error":                 "Failed to process the DOCX file: %v",
+		"docx_upload_error":          "Failed to add the DOCX to the vector database: %v",
+		"json_error":                 "Failed to process the JSON file.",
+		"json_empty":                 "The JSON contains no text data to embed.",
+		"json_upload_error":          "Failed to add the JSON data to Pinecone: %v",
+		"csv_not_utf8":               "The CSV is not valid UTF-8 text. Save it as UTF-8 and try again.",
+		"csv_error":                  "Failed to process the CSV file: %v",
+		"csv_empty":                  "The CSV contains no text data to embed.",
+		"csv_upload_error":           "Failed to add the CSV data to Pinecone: %v",
+		"text_not_utf8":              "The file is not valid UTF-8 text. Save it as UTF-8 and try again.",
+		"file_upload_error":          "Failed to add the file to the vector database: %v",
+		"page_error":                 "Could not download the page: %v",
+		"page_empty":                 "The page contains no text to embed.",
+		"page_upload_error":          "Failed to add the page to the vector database: %v",
+		"image_download_error":       "Failed to download the image: %v",
+		"image_extract_error":        "Could not recognize the image contents: %v",
+		"image_upload_error":         "Failed to add the image to the vector database: %v",
+		"vision_error":               "Could not analyze the image: %v",
+		"photo_index_button":         "📥 Add to the knowledge base",
+		"photo_index_expired":        "These images can no longer be added.",
+		"photo_index_started":        "Adding the images to the knowledge base…",
+		"kind_json":                  "JSON (records: %d)",
+		"kind_csv":                   "CSV (rows: %d)",
+		"kind_file":                  "File %s (%s)",
+		"kind_page":                  "Page %s",
+		"kind_image":                 "Image %s",
+		"upload_present":             "%s is already in the vector database, no new chunks were added.",
+		"upload_done":                "%s was uploaded and added to the vector database (chunks: %d).",
+		"upload_done_duplicates":     "%s was uploaded and added to the vector database (new chunks: %d, already present: %d).",
+		"upload_resume_hint":         "\nChunks added before the error: %d of %d. Upload the file again - the added chunks will be skipped and the upload will resume where it stopped.",
+		"upload_cancelled":           "Upload cancelled. Chunks added: %d.",
+		"cancel_nothing":             "There is nothing to cancel.",
+		"cancel_uploads":             "Uploads cancelled: %d.",
+		"cancel_awaiting":            "OK, I'm no longer waiting for a document. You can ask questions.",
+		"ingest_usage":               "Specify a link: /ingest <url>",
+		"delete_usage":               "Specify a file name: /delete <file>",
+		"delete_error":               "Failed to delete the file: %v",
+		"delete_not_found":           "File %s was not found in the vector database.",
+		"delete_done":                "File %s deleted. Vectors deleted: %d.",
+		"model_current":              "Current model: %s\nAvailable models: %s",
+		"model_invalid":              "%v. Available models: %s",
+		"model_changed":              "Model changed to %s.",
+		"index_stats_error":          "Failed to get the index statistics: %v",
+		"list_empty":                 "There are no documents in the registry. Vectors in the index: %d.",
+		"list_header":                "Documents: %d, vectors in the index: %d\n\n",
+		"list_entry":                 "• %s — chunks: %d\n",
+		"collection_all_label":       "all collections",
+		"collection_status":          "Search: %s\nCollections:\n%s\nTo choose a collection, send /collection <name>; to search all of them, send /collection all.",
+		"collection_entry":           "%s %s (index %s)\n",
+		"collection_all":             "Searching all collections. New documents will go to the collection for their type.",
+		"collection_unknown":         "Unknown collection %s. Available collections: %s",
+		"collection_selected":        "Collection %s selected: search and new documents will use only this collection.",
+		"reset_done":                 "Conversation history cleared. You can start a new conversation.",
+		"update_prompt":              "Send the new version of the document with the same file name: changed chunks will be embedded again and outdated ones removed from the database. You can also send the file with the caption update. To change your mind, use /cancel.",
+		"keyword_usage":              "Usage: /keyword <words>. Finds document chunks that contain these words, such as names, numbers or abbreviations.",
+		"keyword_no_terms":           "The query has no keywords to search for: specify names, numbers or other meaningful words.",
+		"keyword_unsupported":        "Keyword search needs an index with the dotproduct metric, and the indexes of the selected collections don't have it. Create such an index with aibot init-index or move the documents with aibot reindex --create.",
+		"search_error":               "Pinecone search failed: %v",
+		"debug_usage":                "Usage: /debug <query>. You can add filters as in a regular query, for example file:resume.pdf.",
+		"debug_header":               "🔎 Namespace: %s, collections: %s\nScore threshold: %.2f, TopK: %d, search time: %d ms\n",
+		"debug_no_matches":           "\nNo matches.",
+		"debug_file_chunk":           "File: %s (chunk %d)\n",
+		"debug_file":                 "File: %s\n",
+		"settings_current":           "Search settings:\ntopk: %d (default %d)\nthreshold: %.2f (default %.2f)\n\nChange: /set topk <1-%d>, /set threshold <0-1>. Restore the default: /set topk %s.",
+		"settings_usage":             "Usage: /set topk <1-%d> or /set threshold <0-1>. Without arguments - the current values.",
+		"settings_topk_default":      "topk restored to the default value %d.",
+		"settings_topk_invalid":      "topk must be a whole number from 1 to %d.",
+		"settings_topk_set":          "topk = %d: search will return up to %d chunks.",
+		"settings_threshold_default": "threshold restored to the default value %.2f.",
+		"settings_threshold_invalid": "threshold must be a number from 0 to 1, for example 0.7.",
+		"settings_threshold_set":     "threshold = %.2f: chunks with a lower score will be discarded.",
+		"settings_unknown":           "Unknown setting %s. Available: topk, threshold.",
+		"summarize_usage":            "Specify a file name: /summarize <file>. Your documents are listed by /list.",
+		"summarize_fetch_error":      "Could not get the document text: %v",
+		"summarize_no_text":          "No text of file %s was found in the vector database. It may have been deleted.",
+		"summarize_cancelled":        "Summary cancelled.",
+		"summarize_error":            "Could not summarize the document: %v",
+		"summarize_similar":          "File %s was not found. Did you mean: %s",
+		"summarize_not_found":        "File %s was not found among your documents. Your uploaded documents are listed by /list.",
+		"feedback_invalid":           "Invalid feedback.",
+		"feedback_expired":           "Feedback can no longer be left for this answer.",
+		"feedback_error":             "Could not save the feedback, please try later.",
+		"feedback_thanks":            "Thank you for the feedback!",
+		"source_chunk":               "%s, chunk %d",
+		"source_expired":             "This chunk is no longer available. Please ask the question again.",
+		"inline_document":            "Document",
+		"inline_empty_title":         "Nothing found",
+		"inline_empty_description":   "The knowledge base has no relevant chunks for this query.",
+		"inline_empty_text":          "No relevant matches were found in the knowledge base.",
+		"preview_header":             "Preview (nothing was added to the database).\n\nFile: %s\nCollection: %s\nNamespace: %s\nChunks: %d",
+		"preview_duplicates":         " (already in the database: %d)",
+		"preview_first":              "\n\nFirst chunk:\n%s",
+		"preview_last":               "\n\nLast chunk (%d):\n%s",
+		"preview_metadata":           "\n\nMetadata of the first chunk:\n%s",
+		"yes":                        "yes",
+		"no":                         "no",
+		"whoami_all_collections":     "all (%s)",
+		"whoami_report":              "Administrator: %s\nSaved question-answer pairs in history: %d of %d\nWaiting for a document: %s\nUpload namespace: %s\nSearch namespaces: %s\nSearch collections: %s\nSearch: topk %d, threshold %.2f",
+		"admin_only":                 "Sorry, this command is available to administrators only.",
+		"help_commands":              "Available commands:\n",
+		"help_admin_commands":        "\nAdministrator commands:\n",
+		"help_footer":                "\nTo ask a question, just send it as text or a voice message.",
+		"command_start":              "About the bot",
+		"command_upload":             "Upload a document to the knowledge base",
+		"command_update":             "Update an uploaded document",
+		"command_cancel":             "Stop waiting for a document or cancel uploads",
+		"command_ingest":             "Add a web page: /ingest <url>",
+		"command_delete":             "Delete a document: /delete <file>",
+		"command_summarize":          "Short summary of a document: /summarize <file>",
+		"command_stats":              "Usage statistics",
+		"command_model":              "View and change the GPT model",
+		"command_debug":              "Pinecone matches for a query without an answer",
+		"command_keyword":            "Keyword search: /keyword <words>",
+		"command_list":               "List of uploaded documents",
+		"command_collection":         "Choose a collection to search",
+		"command_set":                "Search settings: /set topk|threshold <value>",
+		"command_whoami":             "Your session state",
+		"command_reset":              "Clear the conversation history",
+		"command_help":               "List of commands",
+	},
+}
+
+// Відмова, коли в базі знань немає релевантних даних: NO_CONTEXT_MESSAGE або повідомлення каталогу
+func noContextMessage(lang string) string {
+	if NoContextMessage != "" {
+		return NoContextMessage
+	}
+	return translate(lang, "no_matches")
+}
+
+// Назви мов для інструкції моделі, якою мовою відповідати
+var languageNames = map[string]string{
+	"uk": "українською",
+	"en": "англійською",
+	"ru": "російською",
+}
+
+// Повідомлення з каталогу мовою lang з підставленими аргументами
+func translate(lang, key string, args ...any) string {
+	text, ok := messageCatalog[lang][key]
+	if !ok {
+		text = messageCatalog[defaultLanguage][key]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Мова інтерфейсу користувача з налаштувань Telegram, якщо для неї є каталог
+func userLanguage(m telebot.Context) string {
+	if sender := m.Sender(); sender != nil {
+		code := strings.ToLower(sender.LanguageCode)
+		if i := strings.IndexAny(code, "-_"); i >= 0 {
+			code = code[:i]
+		}
+		if _, ok := messageCatalog[code]; ok {
+			return code
+		}
+	}
+	return defaultLanguage
+}
+
+// Мова відповіді на запит: мова самого запиту, якщо для неї є каталог, інакше мова з Telegram
+func replyLanguage(m telebot.Context, query string) string {
+	if lang := detectLanguage(query); lang != "" {
+		if _, ok := messageCatalog[lang]; ok {
+			return lang
+		}
+	}
+	return userLanguage(m)
+}
+
+// Поширені англійські слова для розпізнавання латинського тексту
+var englishWords = map[string]bool{
+	"the": true, "is": true, "are": true, "what": true, "how": true, "who": true, "which": true, "where": true,
+	"when": true, "why": true, "does": true, "did": true, "do": true, "and": true, "of": true, "to": true,
+	"in": true, "has": true, "have": true, "can": true, "his": true, "her": true, "their": true, "about": true,
+	"tell": true, "me": true, "with": true, "for": true, "a": true, "an": true,
+}
+
+// Визначаємо мову запиту за алфавітом і характерними літерами або словами.
+// Повертаємо uk, ru, en або порожній рядок, якщо мову визначити не вдалося.
+func detectLanguage(text string) string {
+	var cyrillic, latin, ukrainian, russian int
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case strings.ContainsRune("іїєґ", r):
+			ukrainian++
+			cyrillic++
+		case strings.ContainsRune("ыэъё", r):
+			russian++
+			cyrillic++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic > latin:
+		if russian > ukrainian {
+			return "ru"
+		}
+		return "uk"
+	case latin > 0:
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+			if englishWords[word] {
+				return "en"
+			}
+		}
+	}
+	return ""
+}
+
+// Інструкція для моделі, якою мовою відповідати на запит
+func languageInstruction(query string) string {
+	if name, ok := languageNames[detectLanguage(query)]; ok {
+		return fmt.Sprintf("Відповідай %s мовою - мовою запитання користувача, навіть якщо знайдені дані іншою мовою.", name)
+	}
+	return "Відповідай тією мовою, якою користувач поставив запитання, навіть якщо знайдені дані іншою мовою."
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Дієслова форматування в повідомленні каталогу (%d, %.2f, %v...), без екранованого %%
+var formatVerb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z]`)
+
+func TestMessageCatalogComplete(t *testing.T) {
+	for lang, messages := range messageCatalog {
+		if lang == defaultLanguage {
+			continue
+		}
+		for key, text := range messageCatalog[defaultLanguage] {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: немає перекладу %q", lang, key)
+				continue
+			}
+			// Аргументи підставляються в тому самому порядку, тож і дієслова мають збігатися
+			want := formatVerb.FindAllString(strings.ReplaceAll(text, "%%", ""), -1)
+			got := formatVerb.FindAllString(strings.ReplaceAll(translated, "%%", ""), -1)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s: %q має дієслова форматування %v, очікувалося %v", lang, key, got, want)
+			}
+		}
+		// Описи команд мають переклад лише для мов, відмінних від мови реєстрації
+		for key := range messages {
+			if _, ok := messageCatalog[defaultLanguage][key]; !ok && !strings.HasPrefix(key, "command_") {
+				t.Errorf("%s: %q немає в каталозі %s", lang, key, defaultLanguage)
+			}
+		}
+	}
+}
+
+func TestCommandDescriptionsTranslated(t *testing.T) {
+	saved := botCommands
+	t.Cleanup(func() { botCommands = saved })
+	botCommands = []botCommand{{Text: "help", Description: "Перелік команд"}, {Text: "unknown", Description: "Без перекладу"}}
+
+	commands := telegramCommands("en", false)
+	if commands[0].Description != "List of commands" {
+		t.Errorf("опис /help англійською = %q", commands[0].Description)
+	}
+	if commands[1].Description != "Без перекладу" {
+		t.Errorf("опис без перекладу = %q, очікувався опис з реєстрації", commands[1].Description)
+	}
+	if description := telegramCommands(defaultLanguage, false)[0].Description; description != "Перелік команд" {
+		t.Errorf("опис /help мовою за замовчуванням = %q", description)
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"github.com/spf13/cobra"
+)
+
+// Підсумок імпорту для звіту в консолі
+type importResult struct {
+	Imported   int // Додано (або було б додано в режимі dry-run) векторів
+	Reembedded int // З них векторизовано заново з тексту метаданих
+	Skipped    int // Пропущено: немає ні значень потрібної розмірності, ні тексту
+}
+
+// Підкоманда aibot import <файл>: відновлює вектори з файлу, створеного aibot export
+var importCmd = &cobra.Command{
+	Use:   "import <файл>",
+	Short: "Імпортувати вектори з JSONL файлу aibot export у Pinecone.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := setupLogging(); err != nil {
+			fatal("Некоректні налаштування логування", "error", err)
+		}
+		if PineconeAPIKey == "" {
+			fatal("Відсутня змінна середовища PINECONE_API_KEY.")
+		}
+		if err := loadConfig(); err != nil {
+			fatal("Некоректні налаштування", "error", err)
+		}
+		if err := initPinecone(); err != nil {
+			fatal("Не вдалося підключитися до Pinecone", "error", err)
+		}
+
+		// Імпортовані документи додаються до наявного реєстру
+		if err := loadRegistry(RegistryPath); err != nil {
+			fatal("Не вдалося завантажити реєстр документів", "error", err)
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		reembed, _ := cmd.Flags().GetBool("reembed")
+		collection, _ := cmd.Flags().GetString("collection")
+		if collection != "" && !isKnownCollection(collection) {
+			fatal("Невідома колекція", "collection", collection)
+		}
+
+		result, err := importVectors(args[0], collection, reembed, dryRun)
+		if err != nil {
+			fatal("Помилка імпорту", "file", args[0], "imported", result.Imported, "error", err)
+		}
+
+		verb := "Імпортовано"
+		if dryRun {
+			verb = "Було б імпортовано (dry-run)"
+		}
+		fmt.Printf("%s векторів: %d (векторизовано заново: %d), пропущено: %d\n", verb, result.Imported, result.Reembedded, result.Skipped)
+	},
+}
+
+// Читаємо файл експорту та додаємо вектори пакетами по PineconeUpsertBatchSize.
+// collection (якщо не порожня) замінює колекцію із запису. Вектори без значень, значення іншої розмірності
+// або всі вектори з reembed векторизуються заново з поля text метаданих. У режимі dryRun нічого не записуємо.
+func importVectors(path, collection string, reembed, dryRun bool) (importResult, error) {
+	var result importResult
+
+	file, err := os.Open(path)
+	if err != nil {
+		return result, fmt.Errorf("Помилка відкриття файлу імпорту: %v", err)
+	}
+	defer file.Close()
+
+	var batch []exportRecord
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := importBatch(batch, reembed, dryRun, &result)
+		batch = batch[:0]
+		return err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20) // Рядок зі значеннями вектора може бути великим
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record exportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return result, fmt.Errorf("Рядок %d: некоректний JSON: %v", line, err)
+		}
+		if record.ID == "" {
+			return result, fmt.Errorf("Рядок %d: відсутній id вектора", line)
+		}
+		if collection != "" {
+			record.Collection = collection
+		}
+		if record.Collection == "" {
+			record.Collection = defaultCollection
+		}
+		if !isKnownCollection(record.Collection) {
+			return result, fmt.Errorf("Рядок %d: невідома колекція %q, вкажіть іншу через --collection", line, record.Collection)
+		}
+
+		// Пакет містить вектори лише однієї колекції та namespace
+		if len(batch) > 0 && (batch[0].Collection != record.Collection || batch[0].Namespace != record.Namespace || len(batch) >= PineconeUpsertBatchSize) {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+		batch = append(batch, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("Помилка читання файлу імпорту: %v", err)
+	}
+	return result, flush()
+}
+
+// Векторизуємо за потреби та додаємо пакет записів однієї колекції та namespace
+func importBatch(records []exportRecord, reembed, dryRun bool, result *importResult) error {
+	collection, namespace := records[0].Collection, records[0].Namespace
+
+	// Записи, які потрібно векторизувати заново, та їхні тексти
+	var toEmbed []int
+	var texts []string
+	ready := make([]exportRecord, 0, len(records))
+	for _, record := range records {
+		if !reembed && len(record.Values) == pineconeConn.dimension {
+			ready = append(ready, record)
+			continue
+		}
+
+		text, _ := record.Metadata["text"].(string)
+		if text == "" {
+			slog.Warn("Пропущено вектор без значень потрібної розмірності та без тексту", "collection", collection, "namespace", namespace,
+				"id", record.ID, "dimension", len(record.Values), "expected", pineconeConn.dimension)
+			result.Skipped++
+			continue
+		}
+		toEmbed = append(toEmbed, len(ready))
+		texts = append(texts, text)
+		ready = append(ready, record)
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		result.Imported += len(ready)
+		result.Reembedded += len(texts)
+		return nil
+	}
+
+	if len(texts) > 0 {
+		embeddings, failed, err := embedChunks(context.Background(), texts, nil)
+		if err != nil {
+			return fmt.Errorf("Помилка векторизації вектора %s: %v", ready[toEmbed[failed]].ID, err)
+		}
+		for i, position := range toEmbed {
+			ready[position].Values = embeddings[i]
+			ready[position].Metadata["embedding_model"] = embeddingModelTag()
+		}
+	}
+
+	vectors := make([]*pinecone.Vector, 0, len(ready))
+	registered := make(map[string][]registeredVector)
+	for _, record := range ready {
+		if err := checkVectorDimension(record.Values); err != nil {
+			return err
+		}
+		metadata, err := metadataStruct(context.Background(), record.Metadata)
+		if err != nil {
+			return fmt.Errorf("Помилка перетворення метаданих вектора %s: %v", record.ID, err)
+		}
+		text, _ := record.Metadata["text"].(string)
+		vectors = append(vectors, &pinecone.Vector{Id: record.ID, Values: record.Values, SparseValues: documentSparseVector(collection, text), Metadata: metadata})
+
+		// Реєструємо вектор, щоб документ був видимий у /list і видалявся через /delete
+		if fileName, ok := record.Metadata["file"].(string); ok && fileName != "" {
+			hash, _ := record.Metadata["content_hash"].(string)
+			registered[fileName] = append(registered[fileName], registeredVector{ID: record.ID, Hash: hash})
+		}
+	}
+
+	if err := upsertVectorsToPinecone(context.Background(), collection, namespace, vectors); err != nil {
+		return err
+	}
+	for fileName, ids := range registered {
+		if err := registerVectors(collection, namespace, fileName, ids...); err != nil {
+			slog.Error("Помилка оновлення реєстру документів", "file", fileName, "error", err)
+		}
+	}
+
+	result.Imported += len(vectors)
+	result.Reembedded += len(texts)
+	slog.Info("Імпортовано пакет векторів", "collection", collection, "namespace", namespace, "vectors", len(vectors), "total", result.Imported)
+	return nil
+}
+
+func init() {
+	aibotCmd.AddCommand(importCmd)
+
+	importCmd.Flags().Bool("dry-run", false, "Лише перевірити файл і показати, що було б імпортовано")
+	importCmd.Flags().Bool("reembed", false, "Векторизувати всі записи заново з тексту (після зміни моделі ембеддингів)")
+	importCmd.Flags().String("collection", "", "Імпортувати всі вектори в цю колекцію замість указаної в записах")
+}
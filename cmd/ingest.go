@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Теги, вміст яких не є текстом сторінки
+var skippedHTMLTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "nav": true, "header": true, "footer": true, "form": true,
+}
+
+// Блокові теги, після яких починається новий рядок
+var blockHTMLTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "ul": true, "ol": true, "main": true,
+}
+
+// Забороняємо з'єднання з внутрішніми адресами вже після розв'язання DNS
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("адреса %s недоступна для завантаження", host)
+	}
+	return nil
+}
+
+// Завантажуємо сторінку та повертаємо її читабельний текст, заголовок і тип вмісту
+func fetchURLText(rawURL string) (text, title, mediaType string, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", "", "", fmt.Errorf("Некоректне посилання, потрібна адреса http:// або https://")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HTTPTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return "", "", "", err
+	}
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9")
+
+	resp, err := ingestClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", "", "", fmt.Errorf("Помилка завантаження сторінки: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("Сторінка повернула статус %s", resp.Status)
+	}
+	if resp.ContentLength > IngestMaxBytes {
+		return "", "", "", fmt.Errorf("Сторінка завелика (%d байт, максимум %d)", resp.ContentLength, IngestMaxBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err = mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Невідомий тип вмісту сторінки: %q", contentType)
+	}
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !isHTML && mediaType != "text/plain" && mediaType != "text/markdown" {
+		return "", "", "", fmt.Errorf("Тип вмісту %s не підтримується, потрібна HTML або текстова сторінка", mediaType)
+	}
+
+	// Читаємо на байт більше за ліміт, щоб помітити перевищення без Content-Length
+	body, err := io.ReadAll(io.LimitReader(resp.Body, IngestMaxBytes+1))
+	if err != nil {
+		return "", "", "", fmt.Errorf("Помилка читання сторінки: %v", err)
+	}
+	if int64(len(body)) > IngestMaxBytes {
+		return "", "", "", fmt.Errorf("Сторінка завелика (максимум %d байт)", IngestMaxBytes)
+	}
+
+	// Перекодовуємо в UTF-8 за charset із заголовка або самої сторінки
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Невідоме кодування сторінки: %v", err)
+	}
+	if !isHTML {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", "", "", fmt.Errorf("Помилка читання сторінки: %v", err)
+		}
+		return string(data), "", mediaType, nil
+	}
+
+	text, title, err = htmlToText(reader)
+	return text, title, mediaType, err
+}
+
+// Перетворюємо HTML на текст: без скриптів, стилів і навігації, блоки з нового рядка
+func htmlToText(r io.Reader) (text, title string, err error) {
+	tokenizer := html.NewTokenizer(r)
+
+	var sb strings.Builder
+	skipDepth := 0
+	inTitle := false
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", "", fmt.Errorf("Помилка розбору HTML: %v", err)
+			}
+			return normalizeLines(sb.String()), strings.TrimSpace(title), nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if skippedHTMLTags[tag] && tokenType == html.StartTagToken {
+				skipDepth++
+			}
+			if tag == "title" {
+				inTitle = true
+			}
+			if blockHTMLTags[tag] {
+				sb.WriteString("\n")
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if skippedHTMLTags[tag] && skipDepth > 0 {
+				skipDepth--
+			}
+			if tag == "title" {
+				inTitle = false
+			}
+			if blockHTMLTags[tag] {
+				sb.WriteString("\n")
+			}
+
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+				continue
+			}
+			if skipDepth == 0 {
+				sb.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// Стискаємо пробіли в рядках і прибираємо порожні рядки
+func normalizeLines(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"github.com/spf13/cobra"
+)
+
+// Скільки чекаємо, доки новий індекс стане готовим
+const initIndexReadyTimeout = 5 * time.Minute
+
+// Підкоманда aibot init-index: створює serverless індекс Pinecone, якщо його ще немає
+var initIndexCmd = &cobra.Command{
+	Use:   "init-index",
+	Short: "Створити індекс Pinecone з розмірністю моделі ембеддингів, якщо його ще немає.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := setupLogging(); err != nil {
+			fatal("Некоректні налаштування логування", "error", err)
+		}
+		if PineconeAPIKey == "" {
+			fatal("Відсутня змінна середовища PINECONE_API_KEY.")
+		}
+
+		dimension, _ := cmd.Flags().GetInt("dimension")
+		cloud, _ := cmd.Flags().GetString("cloud")
+
+		host, created, err := ensurePineconeIndex(PineconeIndex, dimension, pinecone.Cloud(cloud))
+		if err != nil {
+			fatal("Не вдалося створити індекс Pinecone", "index", PineconeIndex, "error", err)
+		}
+
+		if created {
+			fmt.Printf("Індекс %s створено. Хост: %s\n", PineconeIndex, host)
+		} else {
+			fmt.Printf("Індекс %s вже існує. Хост: %s\n", PineconeIndex, host)
+		}
+	},
+}
+
+// Створюємо індекс name у регіоні PineconeEnv, якщо його немає, і чекаємо на готовність.
+// Розмірність 0 означає розмірність активної моделі ембеддингів. Повертаємо хост індексу.
+func ensurePineconeIndex(name string, dimension int, cloud pinecone.Cloud) (host string, created bool, err error) {
+	if dimension == 0 {
+		known, ok := embeddingDimension()
+		if !ok {
+			return "", false, fmt.Errorf("Невідома розмірність моделі %s, вкажіть її через --dimension", embeddingModel())
+		}
+		dimension = known
+	}
+	if dimension < 1 {
+		return "", false, fmt.Errorf("Розмірність має бути додатною, отримано %d", dimension)
+	}
+
+	client, err := pinecone.NewClient(pinecone.NewClientParams{ApiKey: PineconeAPIKey})
+	if err != nil {
+		return "", false, fmt.Errorf("Помилка створення клієнта Pinecone: %v", err)
+	}
+
+	indexes, err := withTimeoutRetry("Pinecone ListIndexes", PineconeTimeout, func(ctx context.Context) ([]*pinecone.Index, error) {
+		return client.ListIndexes(ctx)
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("Помилка отримання списку індексів: %v", err)
+	}
+	for _, index := range indexes {
+		if index.Name != name {
+			continue
+		}
+		if int(index.Dimension) != dimension {
+			slog.Warn("Розмірність наявного індексу відрізняється від очікуваної", "index", name, "dimension", index.Dimension, "expected", dimension)
+		}
+		if index.Metric != indexMetric() {
+			slog.Warn("Метрика наявного індексу відрізняється від очікуваної", "index", name, "metric", index.Metric, "expected", indexMetric())
+		}
+		return index.Host, false, nil
+	}
+
+	metric := indexMetric()
+	slog.Info("Створюємо індекс Pinecone", "index", name, "dimension", dimension, "metric", metric, "cloud", cloud, "region", PineconeEnv)
+	_, err = withTimeoutRetry("Pinecone CreateServerlessIndex", PineconeTimeout, func(ctx context.Context) (*pinecone.Index, error) {
+		return client.CreateServerlessIndex(ctx, &pinecone.CreateServerlessIndexRequest{
+			Name:      name,
+			Dimension: int32(dimension),
+			Metric:    metric,
+			Cloud:     cloud,
+			Region:    PineconeEnv,
+		})
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("Помилка створення індексу: %v", err)
+	}
+
+	// Хост з'являється, коли індекс стає готовим
+	deadline := time.Now().Add(initIndexReadyTimeout)
+	for {
+		index, err := withTimeoutRetry("Pinecone DescribeIndex", PineconeTimeout, func(ctx context.Context) (*pinecone.Index, error) {
+			return client.DescribeIndex(ctx, name)
+		})
+		if err != nil {
+			return "", true, fmt.Errorf("Помилка опису створеного індексу: %v", err)
+		}
+		if index.Status != nil && index.Status.Ready && index.Host != "" {
+			return index.Host, true, nil
+		}
+		if time.Now().After(deadline) {
+			return index.Host, true, fmt.Errorf("Індекс створено, але він не став готовим за %v", initIndexReadyTimeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func init() {
+	aibotCmd.AddCommand(initIndexCmd)
+
+	initIndexCmd.Flags().Int("dimension", 0, "Розмірність векторів (типово - за моделлю ембеддингів)")
+	initIndexCmd.Flags().String("cloud", string(pinecone.Aws), "Хмара serverless індексу: aws, gcp або azure")
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	telebot "gopkg.in/telebot.v3"
+)
+
+const (
+	inlineCacheTTL       = time.Minute // Скільки зберігаємо результати inline-запиту
+	inlineMinQueryLength = 3           // Коротші запити не шукаємо: Telegram надсилає їх на кожне натискання клавіші
+	inlineSnippetLength  = 200         // Довжина опису результату в символах
+)
+
+// Кешовані результати inline-запиту
+type inlineCacheEntry struct {
+	results telebot.Results
+	expires time.Time
+}
+
+// Короткочасний кеш inline-результатів за namespace і текстом запиту
+var inlineCache = struct {
+	sync.Mutex
+	entries map[string]inlineCacheEntry
+}{entries: make(map[string]inlineCacheEntry)}
+
+// Обробка inline-запиту: векторизація, пошук у Pinecone і знайдені фрагменти як статті
+func handleInlineQuery(c telebot.Context) error {
+	query := c.Query()
+	text := strings.TrimSpace(query.Text)
+	if len([]rune(text)) < inlineMinQueryLength {
+		return c.Answer(&telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true})
+	}
+
+	namespaces := searchNamespaces(query.Sender.ID)
+	collections := searchCollectionNames(query.Sender.ID)
+	topK, minScore := userSearchSettings(query.Sender.ID)
+	lang := userLanguage(c)
+	key := lang + "\x00" + strings.Join(namespaces, ",") + "\x00" + strings.Join(collections, ",") + "\x00" + fmt.Sprintf("%d/%.2f", topK, minScore) + "\x00" + text
+	if results, ok := cachedInlineResults(key); ok {
+		return c.Answer(&telebot.QueryResponse{Results: results, CacheTime: int(inlineCacheTTL.Seconds()), IsPersonal: true})
+	}
+
+	// Поза кешем inline-запити рахуються в ліміт так само, як звичайні
+	if !allowRequest(query.Sender.ID) {
+		return c.Answer(&telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true})
+	}
+
+	ctx := requestContext(c)
+	queriesHandled.Add(1)
+	metricQueries.Inc()
+	started := time.Now()
+	defer func() { metricQueryDuration.Observe(time.Since(started).Seconds()) }()
+	slog.InfoContext(ctx, "Inline-запит користувача", "user_id", query.Sender.ID, "query_len", len([]rune(text)))
+
+	embedding, err := embedQuery(ctx, text)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка векторизації запиту", "user_id", query.Sender.ID, "stage", "inline_embedding", "error", err)
+		metricErrors.WithLabelValues("embedding").Inc()
+		return c.Answer(&telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true})
+	}
+
+	matches, err := searchCollections(contextWithKeywords(contextWithTopK(ctx, topK), text), collections, namespaces, embedding, minScore, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка пошуку в Pinecone", "user_id", query.Sender.ID, "stage", "inline_search", "error", err)
+		metricErrors.WithLabelValues("search").Inc()
+		return c.Answer(&telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true})
+	}
+
+	results := inlineResults(lang, matches)
+	storeInlineResults(key, results)
+
+	return c.Answer(&telebot.QueryResponse{Results: results, CacheTime: int(inlineCacheTTL.Seconds()), IsPersonal: true})
+}
+
+// Перетворюємо збіги на статті з назвою файлу та фрагментом тексту мовою lang
+func inlineResults(lang string, matches *pinecone.QueryVectorsResponse) telebot.Results {
+	results := telebot.Results{}
+	for i, match := range matches.Matches {
+		if match.Vector == nil || match.Vector.Metadata == nil {
+			continue
+		}
+		metadata := match.Vector.Metadata.AsMap()
+
+		text, _ := metadata["text"].(string)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		// Назва документа з його метаданих (наприклад, PDF) зрозуміліша за назву файлу
+		file, _ := metadata["title"].(string)
+		if file == "" {
+			file, _ = metadata["file"].(string)
+		}
+		if file == "" {
+			file = translate(lang, "inline_document")
+		}
+
+		result := &telebot.ArticleResult{
+			Title:       fmt.Sprintf("%s (%.0f%%)", file, match.Score*100),
+			Description: truncateRunes(text, inlineSnippetLength),
+			Text:        truncateTelegram(fmt.Sprintf("📄 %s\n\n%s", file, text), telegramMessageLimit),
+		}
+		result.SetResultID(strconv.Itoa(i))
+		results = append(results, result)
+	}
+
+	// Порожній результат теж показуємо, щоб користувач бачив, що пошук відбувся
+	if len(results) == 0 {
+		result := &telebot.ArticleResult{
+			Title:       translate(lang, "inline_empty_title"),
+			Description: translate(lang, "inline_empty_description"),
+			Text:        translate(lang, "inline_empty_text"),
+		}
+		result.SetResultID("empty")
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Обрізаємо текст до limit символів з трикрапкою
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// Результати з кешу, якщо вони ще актуальні
+func cachedInlineResults(key string) (telebot.Results, bool) {
+	inlineCache.Lock()
+	defer inlineCache.Unlock()
+
+	entry, ok := inlineCache.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.results, true
+}
+
+// Зберігаємо результати в кеш і прибираємо прострочені записи
+func storeInlineResults(key string, results telebot.Results) {
+	inlineCache.Lock()
+	defer inlineCache.Unlock()
+
+	now := time.Now()
+	for k, entry := range inlineCache.entries {
+		if now.After(entry.expires) {
+			delete(inlineCache.entries, k)
+		}
+	}
+	inlineCache.entries[key] = inlineCacheEntry{results: results, expires: now.Add(inlineCacheTTL)}
+}
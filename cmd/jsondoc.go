@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Запис JSON документа: текст для векторизації та решта полів як метадані
+type jsonRecord struct {
+	Text     string
+	Metadata map[string]interface{}
+}
+
+// Розбираємо JSON на записи: масив - по запису на елемент, об'єкт - один запис
+func extractJSONRecords(data interface{}) []jsonRecord {
+	var items []interface{}
+	if list, ok := data.([]interface{}); ok {
+		items = list
+	} else {
+		items = []interface{}{data}
+	}
+
+	var records []jsonRecord
+	for _, item := range items {
+		record := jsonRecordFrom(item)
+		if strings.TrimSpace(record.Text) != "" {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Текст запису береться з полів JSONTextFields, а якщо їх немає - з усіх рядкових значень рекурсивно.
+// Метадані - поля JSONMetadataFields, а якщо їх не задано - прості поля верхнього рівня, крім текстових.
+func jsonRecordFrom(item interface{}) jsonRecord {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return jsonRecord{Text: strings.Join(collectJSONStrings("", item), "\n")}
+	}
+
+	var texts []string
+	textKeys := make(map[string]bool)
+	for _, field := range JSONTextFields {
+		if value, ok := jsonFieldValue(object, field); ok {
+			texts = append(texts, collectJSONStrings("", value)...)
+			textKeys[field] = true
+		}
+	}
+	if len(texts) == 0 {
+		texts = collectJSONStrings("", object)
+		textKeys = nil
+	}
+
+	// Метадані з полів, заданих явно, під назвою поля з крапками
+	metadata := make(map[string]interface{})
+	if len(JSONMetadataFields) > 0 {
+		for _, field := range JSONMetadataFields {
+			value, ok := jsonFieldValue(object, field)
+			if !ok {
+				continue
+			}
+			if v, ok := jsonMetadataValue(value); ok {
+				metadata[field] = v
+			}
+		}
+		return jsonRecord{Text: strings.Join(texts, "\n"), Metadata: metadata}
+	}
+
+	// Решту простих полів зберігаємо як метадані (Pinecone не приймає вкладених об'єктів)
+	for key, value := range object {
+		if textKeys[key] {
+			continue
+		}
+		if v, ok := jsonMetadataValue(value); ok {
+			metadata[key] = v
+		}
+	}
+
+	return jsonRecord{Text: strings.Join(texts, "\n"), Metadata: metadata}
+}
+
+// Значення поля за шляхом через крапку (author.name); спершу шукаємо ключ цілком, бо крапка може бути в назві
+func jsonFieldValue(object map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := object[path]; ok {
+		return value, true
+	}
+	head, rest, found := strings.Cut(path, ".")
+	if !found {
+		return nil, false
+	}
+	child, ok := object[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return jsonFieldValue(child, rest)
+}
+
+// Рекурсивно збираємо рядкові значення у вигляді "шлях: значення"
+func collectJSONStrings(path string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		if path == "" {
+			return []string{v}
+		}
+		return []string{fmt.Sprintf("%s: %s", path, v)}
+	case map[string]interface{}:
+		// Сортуємо ключі, щоб текст був однаковим при повторному завантаженні
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var result []string
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			result = append(result, collectJSONStrings(childPath, v[key])...)
+		}
+		return result
+	case []interface{}:
+		var result []string
+		for i, item := range v {
+			result = append(result, collectJSONStrings(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return result
+	}
+	return nil
+}
+
+// Значення, яке Pinecone приймає в метаданих: рядок, число, булеве або список рядків
+func jsonMetadataValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string, float64, bool:
+		return v, true
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	}
+	return nil, false
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Налаштовуємо slog: рівень з LOG_LEVEL (debug/info/warn/error) та формат з LOG_FORMAT (text/json)
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(envOrDefault("LOG_LEVEL", "info"))); err != nil {
+		return fmt.Errorf("LOG_LEVEL має бути debug, info, warn або error: %v", err)
+	}
+
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactSecrets}
+
+	var handler slog.Handler
+	switch format := envOrDefault("LOG_FORMAT", "text"); format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("LOG_FORMAT має бути text або json, отримано %s", format)
+	}
+
+	// slog.SetDefault також перенаправляє стандартний log, яким користуються бібліотеки
+	slog.SetDefault(slog.New(requestIDHandler{handler}))
+
+	return nil
+}
+
+// Записуємо помилку в лог і завершуємо процес
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Прибираємо токени та ключі API з будь-якого значення в лозі, навіть на рівні debug
+func redactSecrets(_ []string, attr slog.Attr) slog.Attr {
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		value = attr.Value.String()
+	case slog.KindAny:
+		err, ok := attr.Value.Any().(error)
+		if !ok {
+			return attr
+		}
+		value = err.Error()
+	default:
+		return attr
+	}
+
+	redacted := value
+	for _, secret := range []string{TelegramToken, OpenAIKey, PineconeAPIKey} {
+		if secret != "" {
+			redacted = strings.ReplaceAll(redacted, secret, "[REDACTED]")
+		}
+	}
+	if redacted != value {
+		return slog.String(attr.Key, redacted)
+	}
+
+	return attr
+}
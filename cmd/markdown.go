@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"strings"
+)
+
+// Розділ Markdown документа з шляхом заголовків ("Досвід > Проєкти")
+type markdownSection struct {
+	Heading string
+	Text    string
+}
+
+// Ділимо Markdown на розділи за ATX-заголовками (#, ## ...), ігноруючи # всередині блоків коду.
+// Рядок заголовка залишається в тексті розділу, щоб він потрапив і в ембеддинг.
+func splitMarkdownSections(text string) []markdownSection {
+	var sections []markdownSection
+	var headings []string // Поточний стек заголовків за рівнями
+	var current strings.Builder
+	inCode := false
+
+	flush := func() {
+		if body := strings.TrimSpace(current.String()); body != "" {
+			var path []string
+			for _, heading := range headings {
+				if heading != "" {
+					path = append(path, heading)
+				}
+			}
+			sections = append(sections, markdownSection{
+				Heading: strings.Join(path, " > "),
+				Text:    body,
+			})
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+
+		if level, title := markdownHeading(trimmed); !inCode && level > 0 {
+			flush()
+
+			// Заголовок рівня N замінює всі заголовки рівня N і нижче
+			for len(headings) >= level {
+				headings = headings[:len(headings)-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, title)
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return sections
+}
+
+// Рівень і текст ATX-заголовка або 0, якщо рядок не є заголовком
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(line[level:], "# "))
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Заміна некоректних UTF-8 послідовностей: structpb не приймає такі рядки
+const utf8Replacement = "\uFFFD"
+
+// Ліміт Pinecone на розмір метаданих одного вектора
+const pineconeMetadataLimit = 40 * 1024
+
+// Метадані вектора для Pinecone: непідтримувані значення приводимо до безпечних, а не зриваємо все завантаження.
+// Pinecone приймає рядки, числа, булеві значення та списки рядків.
+func metadataStruct(ctx context.Context, metadata map[string]interface{}) (*structpb.Struct, error) {
+	safe := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		addMetadataValue(ctx, safe, key, value)
+	}
+	if err := fitMetadataSize(ctx, safe, PineconeMaxMetadataBytes); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(safe)
+}
+
+// Вкладаємо метадані в limit байтів JSON: обрізаємо поле text і позначаємо це в text_truncated.
+// Помилка - лише коли метадані не вміщуються навіть без тексту.
+func fitMetadataSize(ctx context.Context, metadata map[string]interface{}, limit int) error {
+	size, err := metadataSize(metadata)
+	if err != nil || size <= limit {
+		return err
+	}
+
+	full, _ := metadata["text"].(string)
+	metadata["text_truncated"] = true
+	metadata["text"] = ""
+	if size, err = metadataSize(metadata); err != nil || size > limit {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Метадані завеликі для Pinecone навіть без тексту частини: %d байтів при ліміті %d (PINECONE_MAX_METADATA_BYTES)", size, limit)
+	}
+
+	// Екранування в JSON змінює розмір нерівномірно, тож найдовший текст, що вміщується, шукаємо діленням навпіл
+	low, high := 0, len(full)
+	for low < high {
+		middle := (low + high + 1) / 2
+		metadata["text"] = truncateUTF8(full, middle)
+		if size, err = metadataSize(metadata); err != nil {
+			return err
+		}
+		if size <= limit {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+	text := truncateUTF8(full, low)
+	metadata["text"] = text
+
+	slog.WarnContext(ctx, "Текст частини обрізано, щоб метадані вмістилися в ліміт Pinecone", "file", metadata["file"], "chunk", metadata["chunk"],
+		"text_bytes", len(full), "stored_bytes", len(text), "limit", limit)
+	return nil
+}
+
+// Розмір метаданих у байтах JSON, як їх рахує Pinecone
+func metadataSize(metadata map[string]interface{}) (int, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(metadata); err != nil {
+		return 0, fmt.Errorf("Помилка серіалізації метаданих: %v", err)
+	}
+	return buf.Len() - 1, nil // Encode додає перенесення рядка
+}
+
+// Перші не більше maxBytes байтів рядка без розрізання символу UTF-8
+func truncateUTF8(text string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(text) <= maxBytes {
+		return text
+	}
+	for maxBytes > 0 && !utf8.RuneStart(text[maxBytes]) {
+		maxBytes--
+	}
+	return text[:maxBytes]
+}
+
+// Додаємо значення під ключем key: вкладені об'єкти розгортаємо в ключі "батько.дитина",
+// решту непідтримуваних типів перетворюємо на рядки, а те, що перетворити неможливо, відкидаємо з попередженням
+func addMetadataValue(ctx context.Context, metadata map[string]interface{}, key string, value interface{}) {
+	key = strings.ToValidUTF8(key, utf8Replacement)
+
+	switch v := value.(type) {
+	case nil:
+		return // Pinecone не приймає null
+	case string:
+		metadata[key] = strings.ToValidUTF8(v, utf8Replacement)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		metadata[key] = v
+	case float32:
+		addMetadataValue(ctx, metadata, key, float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			metadata[key] = fmt.Sprint(v)
+			return
+		}
+		metadata[key] = v
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			metadata[key] = f
+		} else {
+			metadata[key] = v.String()
+		}
+	case []byte:
+		if utf8.Valid(v) {
+			metadata[key] = string(v)
+		} else {
+			metadata[key] = base64.StdEncoding.EncodeToString(v)
+		}
+	case time.Time:
+		metadata[key] = v.UTC().Format(time.RFC3339)
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = strings.ToValidUTF8(item, utf8Replacement)
+		}
+		metadata[key] = list
+	case map[string]interface{}:
+		for childKey, childValue := range v {
+			addMetadataValue(ctx, metadata, key+"."+childKey, childValue)
+		}
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if item == nil {
+				continue
+			}
+			list = append(list, metadataString(item))
+		}
+		metadata[key] = list
+	case fmt.Stringer:
+		metadata[key] = strings.ToValidUTF8(v.String(), utf8Replacement)
+	default:
+		addReflectedMetadataValue(ctx, metadata, key, value)
+	}
+}
+
+// Типи, які не розпізнано напряму (мапи з нерядковими ключами, зрізи інших типів, вказівники, структури)
+func addReflectedMetadataValue(ctx context.Context, metadata map[string]interface{}, key string, value interface{}) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String: // Власні рядкові та числові типи зводимо до базових
+		metadata[key] = strings.ToValidUTF8(rv.String(), utf8Replacement)
+		return
+	case reflect.Bool:
+		metadata[key] = rv.Bool()
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		metadata[key] = rv.Int()
+		return
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		metadata[key] = rv.Uint()
+		return
+	case reflect.Float32, reflect.Float64:
+		addMetadataValue(ctx, metadata, key, rv.Float())
+		return
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return
+		}
+		addMetadataValue(ctx, metadata, key, rv.Elem().Interface())
+		return
+	case reflect.Map:
+		for _, mapKey := range rv.MapKeys() {
+			addMetadataValue(ctx, metadata, key+"."+fmt.Sprint(mapKey.Interface()), rv.MapIndex(mapKey).Interface())
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = rv.Index(i).Interface()
+		}
+		addMetadataValue(ctx, metadata, key, list)
+		return
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		slog.WarnContext(ctx, "Поле метаданих відкинуто: непідтримуваний тип", "key", key, "type", fmt.Sprintf("%T", value))
+		return
+	}
+
+	// Структури та інші типи зберігаємо як JSON або текстове подання
+	slog.WarnContext(ctx, "Поле метаданих перетворено на рядок", "key", key, "type", fmt.Sprintf("%T", value))
+	metadata[key] = metadataString(value)
+}
+
+// Рядкове подання значення для списку рядків: рядки як є, решта - JSON або fmt
+func metadataString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.ToValidUTF8(v, utf8Replacement)
+	case fmt.Stringer:
+		return strings.ToValidUTF8(v.String(), utf8Replacement)
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return strings.ToValidUTF8(string(data), utf8Replacement)
+	}
+	return strings.ToValidUTF8(fmt.Sprint(value), utf8Replacement)
+}
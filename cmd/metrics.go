@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Метрики Prometheus, доступні на /metrics сервера перевірки стану
+var (
+	metricQueries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tgbot_queries_total",
+		Help: "Кількість оброблених запитів користувачів (текстових, голосових та inline).",
+	})
+	metricUploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tgbot_uploads_total",
+		Help: "Кількість завантажень документів за типом.",
+	}, []string{"type"})
+	metricErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tgbot_errors_total",
+		Help: "Кількість помилок обробки запитів за етапом (embedding, search, generation).",
+	}, []string{"stage"})
+	metricQueryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tgbot_query_duration_seconds",
+		Help:    "Час обробки запиту від отримання до відповіді.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	})
+	metricExternalCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tgbot_external_call_duration_seconds",
+		Help:    "Тривалість окремих спроб запитів до OpenAI та Pinecone.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+	metricMatchScore = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tgbot_match_score",
+		Help:    "Оцінки збігів Pinecone до відсікання за порогом PINECONE_MIN_SCORE.",
+		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	})
+)
+
+// Типи документів, що мають окрему мітку в tgbot_uploads_total; решта рахується як other
+var metricUploadTypes = map[string]bool{
+	"pdf": true, "docx": true, "json": true, "csv": true, "txt": true, "md": true, "markdown": true, "web": true,
+}
+
+// Реєструємо метрики бота в типовому реєстрі Prometheus
+func registerMetrics() {
+	prometheus.MustRegister(metricQueries, metricUploads, metricErrors, metricQueryDuration, metricExternalCallDuration, metricMatchScore)
+}
+
+// Враховуємо завантаження документа типу kind (розширення файлу або web)
+func recordUploadMetric(kind string) {
+	if !metricUploadTypes[kind] {
+		kind = "other"
+	}
+	metricUploads.WithLabelValues(kind).Inc()
+}
+
+// Враховуємо тривалість запиту до зовнішнього сервісу; operation має вигляд "OpenAI CreateEmbeddings"
+func observeExternalCall(operation string, started time.Time) {
+	service, name, ok := strings.Cut(operation, " ")
+	if !ok {
+		service, name = "unknown", operation
+	}
+	metricExternalCallDuration.WithLabelValues(strings.ToLower(service), name).Observe(time.Since(started).Seconds())
+}
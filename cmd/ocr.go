@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Максимальний час OCR одного документа
+const ocrTimeout = 5 * time.Minute
+
+// Витягуємо текст з PDF, а якщо текстового шару немає або він замалий - розпізнаємо сторінки через OCR.
+// OCR вмикається OCR_ENABLED=true і потребує встановлених pdftoppm (poppler-utils) та tesseract.
+func extractPDFTextWithOCR(file *os.File, size int64, status *uploadStatus) (text string, usedOCR bool, err error) {
+	text, err = extractTextFromPDF(file, size)
+	if !OCREnabled {
+		return text, false, err
+	}
+	if err != nil && !errors.Is(err, errPDFNoText) {
+		return "", false, err
+	}
+	if err == nil && utf8.RuneCountInString(text) >= OCRMinTextChars {
+		return text, false, nil
+	}
+
+	status.Stage("stage_ocr")
+	ocrText, ocrErr := ocrPDF(file.Name())
+	if ocrErr != nil {
+		slog.Error("Помилка OCR", "error", ocrErr)
+		if err != nil {
+			return "", false, fmt.Errorf("PDF не містить тексту, а OCR не вдався: %v", ocrErr)
+		}
+		return text, false, nil
+	}
+	if strings.TrimSpace(ocrText) == "" {
+		if err != nil {
+			return "", false, fmt.Errorf("PDF не містить тексту, і OCR також не розпізнав жодного тексту")
+		}
+		return text, false, nil
+	}
+
+	// Беремо результат OCR, лише якщо він змістовніший за текстовий шар
+	if utf8.RuneCountInString(ocrText) <= utf8.RuneCountInString(text) {
+		return text, false, nil
+	}
+	return ocrText, true, nil
+}
+
+// Рендеримо сторінки PDF у PNG через pdftoppm і розпізнаємо кожну через tesseract
+func ocrPDF(input string) (string, error) {
+	for _, tool := range []string{"pdftoppm", "tesseract"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return "", fmt.Errorf("для OCR потрібна програма %s: %v", tool, err)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "aibot-ocr-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+	defer cancel()
+
+	if output, err := exec.CommandContext(ctx, "pdftoppm", "-r", "300", "-png", input, filepath.Join(dir, "page")).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("pdftoppm: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// pdftoppm доповнює номери сторінок нулями, тож сортування за назвою зберігає порядок
+	pages, err := filepath.Glob(filepath.Join(dir, "page*.png"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(pages)
+
+	var sb strings.Builder
+	for i, page := range pages {
+		output, err := exec.CommandContext(ctx, "tesseract", page, "stdout", "-l", OCRLanguages).Output()
+		if err != nil {
+			return "", fmt.Errorf("tesseract, сторінка %d: %v", i+1, err)
+		}
+		sb.WriteString(strings.TrimSpace(string(output)))
+		sb.WriteString("\n")
+	}
+
+	slog.Info("OCR завершено", "pages", len(pages))
+	return strings.TrimSpace(sb.String()), nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Види помилок OpenAI, про які користувачу повідомляємо окремо, без деталей API
+const (
+	openAIErrorQuota     = "openai_quota"      // Вичерпано квоту або ліміт оплати
+	openAIErrorRateLimit = "openai_rate_limit" // Забагато запитів до API
+	openAIErrorAuth      = "openai_auth"       // Недійсний або відкликаний ключ API
+)
+
+// Визначаємо вид помилки OpenAI за кодом, типом і HTTP статусом; порожній рядок для інших помилок
+func classifyOpenAIError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var statusCode int
+	var code, errType string
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		statusCode = apiErr.HTTPStatusCode
+		code, _ = apiErr.Code.(string)
+		errType = apiErr.Type
+	case errors.As(err, &reqErr):
+		statusCode = reqErr.HTTPStatusCode
+	default:
+		return ""
+	}
+
+	switch {
+	case code == "insufficient_quota" || errType == "insufficient_quota" || code == "billing_hard_limit_reached":
+		return openAIErrorQuota
+	case code == "invalid_api_key" || statusCode == http.StatusUnauthorized:
+		return openAIErrorAuth
+	case code == "rate_limit_exceeded" || statusCode == http.StatusTooManyRequests:
+		return openAIErrorRateLimit
+	}
+	return ""
+}
+
+// Зрозуміле повідомлення мовою lang про помилку OpenAI, якщо її вид відомий.
+// Повні деталі помилки пишемо лише в лог, щоб не показувати користувачу внутрішні дані API.
+func openAIErrorMessage(lang string, err error) (string, bool) {
+	kind := classifyOpenAIError(err)
+	if kind == "" {
+		return "", false
+	}
+	if kind != openAIErrorRateLimit {
+		slog.Error("Проблема з обліковим записом OpenAI, потрібне втручання адміністратора", "kind", kind, "error", err)
+	}
+	return translate(lang, kind), true
+}
+
+// Текст помилки для користувача: для відомих помилок OpenAI і недоступної бази знань - повідомлення без деталей API
+func userFacingError(err error) string {
+	if message, ok := openAIErrorMessage(defaultLanguage, err); ok {
+		return message
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return translate(defaultLanguage, "kb_unavailable")
+	}
+	return err.Error()
+}
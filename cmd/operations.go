@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+)
+
+// Тривалі операції користувачів (індексація документів), які можна скасувати через /cancel
+var userOperations = struct {
+	sync.Mutex
+	nextID  uint64
+	cancels map[int64]map[uint64]context.CancelFunc
+}{cancels: make(map[int64]map[uint64]context.CancelFunc)}
+
+// Реєструємо операцію користувача в межах контексту запиту parent; finish треба викликати після її завершення
+func startOperation(parent context.Context, userID int64) (ctx context.Context, finish func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	userOperations.Lock()
+	defer userOperations.Unlock()
+
+	userOperations.nextID++
+	id := userOperations.nextID
+	if userOperations.cancels[userID] == nil {
+		userOperations.cancels[userID] = make(map[uint64]context.CancelFunc)
+	}
+	userOperations.cancels[userID][id] = cancel
+
+	return ctx, func() {
+		userOperations.Lock()
+		defer userOperations.Unlock()
+
+		delete(userOperations.cancels[userID], id)
+		if len(userOperations.cancels[userID]) == 0 {
+			delete(userOperations.cancels, userID)
+		}
+		cancel()
+	}
+}
+
+// Скасовуємо всі операції користувача; повертаємо кількість скасованих
+func cancelOperations(userID int64) int {
+	userOperations.Lock()
+	defer userOperations.Unlock()
+
+	cancels := userOperations.cancels[userID]
+	for _, cancel := range cancels {
+		cancel()
+	}
+	delete(userOperations.cancels, userID)
+
+	return len(cancels)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Поле Info PDF може бути будь-якої довжини, тож обрізаємо його перед збереженням у метадані
+const pdfInfoMaxLength = 300
+
+// Метадані PDF зі словника Info та кількість сторінок.
+// Відсутні або пошкоджені поля пропускаються; якщо PDF не вдається прочитати, повертаємо порожню мапу.
+func readPDFInfo(r io.ReaderAt, size int64) (info map[string]interface{}) {
+	info = make(map[string]interface{})
+
+	// Бібліотека панікує на пошкоджених файлах; метадані необов'язкові, тож лише пишемо в лог
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("Не вдалося прочитати метадані PDF", "error", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		slog.Warn("Не вдалося прочитати метадані PDF", "error", err)
+		return info
+	}
+	info["pages"] = reader.NumPage()
+
+	dict := reader.Trailer().Key("Info")
+	if dict.Kind() != pdf.Dict {
+		return info
+	}
+	if title := pdfInfoText(dict, "Title"); title != "" {
+		info["title"] = title
+	}
+	if author := pdfInfoText(dict, "Author"); author != "" {
+		info["author"] = author
+	}
+	if created, ok := parsePDFDate(pdfInfoText(dict, "CreationDate")); ok {
+		info["created_at"] = created.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// Текстове поле словника Info без керівних символів і зайвих пробілів
+func pdfInfoText(dict pdf.Value, key string) string {
+	value := dict.Key(key)
+	if value.Kind() != pdf.String {
+		return ""
+	}
+	text := strings.Map(func(r rune) rune {
+		if r < ' ' || r == '�' {
+			return ' '
+		}
+		return r
+	}, value.Text())
+	return truncateRunes(strings.Join(strings.Fields(text), " "), pdfInfoMaxLength)
+}
+
+// Розбираємо дату PDF у форматі D:YYYYMMDDHHmmSSOHH'mm' (усі частини після року необов'язкові)
+func parsePDFDate(value string) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	value = strings.ReplaceAll(value, "'", "")
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	// Часовий пояс Z, +HHmm або -HHmm; без нього вважаємо час UTC
+	zone := ""
+	if i := strings.IndexAny(value, "Z+-"); i >= 0 {
+		value, zone = value[:i], value[i:]
+	}
+	if strings.HasPrefix(zone, "Z") {
+		zone = ""
+	}
+
+	layouts := map[int]string{4: "2006", 6: "200601", 8: "20060102", 10: "2006010215", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, false
+	}
+	if len(zone) == 5 {
+		value += zone
+		layout += "-0700"
+	}
+
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
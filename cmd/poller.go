@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Спосіб отримання оновлень від Telegram: long polling (типово) або webhook
+func newPoller() (telebot.Poller, error) {
+	switch BotMode {
+	case "polling":
+		return &telebot.LongPoller{Timeout: 10 * time.Second}, nil
+	case "webhook":
+		return newWebhook()
+	default:
+		return nil, fmt.Errorf("BOT_MODE має бути polling або webhook, отримано %s", BotMode)
+	}
+}
+
+// Webhook з HTTP сервером на WEBHOOK_LISTEN; telebot сам реєструє WEBHOOK_PUBLIC_URL у Telegram
+// і коректно зупиняє сервер під час aibot.Stop()
+func newWebhook() (*telebot.Webhook, error) {
+	publicURL := os.Getenv("WEBHOOK_PUBLIC_URL")
+	if publicURL == "" {
+		return nil, fmt.Errorf("Для режиму webhook потрібна змінна WEBHOOK_PUBLIC_URL")
+	}
+
+	webhook := &telebot.Webhook{
+		Listen:      envOrDefault("WEBHOOK_LISTEN", ":8080"),
+		SecretToken: os.Getenv("WEBHOOK_SECRET_TOKEN"), // Telegram передає його в кожному запиті
+		Endpoint:    &telebot.WebhookEndpoint{PublicURL: publicURL},
+	}
+
+	// TLS на самому боті, якщо він не стоїть за проксі, що термінує HTTPS
+	cert, key := os.Getenv("WEBHOOK_TLS_CERT"), os.Getenv("WEBHOOK_TLS_KEY")
+	if (cert == "") != (key == "") {
+		return nil, fmt.Errorf("WEBHOOK_TLS_CERT і WEBHOOK_TLS_KEY потрібно задавати разом")
+	}
+	if cert != "" {
+		webhook.TLS = &telebot.WebhookTLS{Cert: cert, Key: key}
+	}
+
+	return webhook, nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Типові вступи-застереження моделі, що не несуть змісту ("Based on the provided context, ...")
+const defaultAnswerStripPrefixes = `(based on|according to) the (provided|given) (context|sources|documents|information)|` +
+	`(на основі|згідно з|відповідно до) надан\pL* (контекст\pL*|джерел\pL*|документ\pL*|інформаці\pL*)|` +
+	`(на основе|согласно|исходя из) предоставленн\pL* (контекст\pL*|источник\pL*|документ\pL*|информаци\pL*)`
+
+// Типові завершальні фрази ввічливості ("I hope this helps!")
+const defaultAnswerStripSuffixes = `i hope (this|that) helps|let me know if you have any (other|more|further) questions|` +
+	`сподіваюся, це допоможе|якщо у вас є (інші|додаткові) запитання, звертайтеся|` +
+	`надеюсь, это поможет|если у вас есть (другие|дополнительные) вопросы, обращайтесь`
+
+// Скомпільовані шаблони ANSWER_STRIP_PREFIXES і ANSWER_STRIP_SUFFIXES (nil - не обрізати)
+var (
+	answerPrefixPattern *regexp.Regexp
+	answerSuffixPattern *regexp.Regexp
+)
+
+// Компілюємо шаблони обрізання відповіді. Шаблон прив'язується до початку (кінця) відповіді,
+// тож такий самий текст усередині відповіді ніколи не видаляється.
+func initAnswerPostprocessor() error {
+	answerPrefixPattern, answerSuffixPattern = nil, nil
+	if !AnswerStripEnabled {
+		return nil
+	}
+
+	var err error
+	if AnswerStripPrefixes != "" {
+		// Після вступу має йти розділовий знак або пробіл, щоб не відрізати частину слова: "Based on the provided context, ..."
+		answerPrefixPattern, err = regexp.Compile(`(?i)^\s*(?:` + AnswerStripPrefixes + `)(?:\s*[,:;.—-]\s*|\s+)`)
+		if err != nil {
+			return fmt.Errorf("Некоректний ANSWER_STRIP_PREFIXES: %v", err)
+		}
+	}
+	if AnswerStripSuffixes != "" {
+		// Перед завершенням має бути межа речення чи слова; сам розділовий знак зберігаємо (група 1)
+		answerSuffixPattern, err = regexp.Compile(`(?i)(^|[\s.!?,;:])\s*(?:` + AnswerStripSuffixes + `)\s*[.!]*\s*$`)
+		if err != nil {
+			return fmt.Errorf("Некоректний ANSWER_STRIP_SUFFIXES: %v", err)
+		}
+	}
+	return nil
+}
+
+// Прибираємо зі відповіді шаблонні вступ і завершення (ANSWER_STRIP_ENABLED).
+// Якщо після обрізання нічого не залишається, повертаємо відповідь без змін.
+func stripAnswerBoilerplate(answer string) string {
+	stripped := answer
+	if answerPrefixPattern != nil {
+		if loc := answerPrefixPattern.FindStringIndex(stripped); loc != nil && loc[1] > 0 {
+			stripped = capitalizeFirst(stripped[loc[1]:])
+		}
+	}
+	if answerSuffixPattern != nil {
+		stripped = strings.TrimRightFunc(answerSuffixPattern.ReplaceAllString(stripped, "${1}"), unicode.IsSpace)
+	}
+
+	if strings.TrimSpace(stripped) == "" {
+		return answer
+	}
+	return stripped
+}
+
+// Перша літера з великої, щоб відповідь після вилученого вступу починалася як речення
+func capitalizeFirst(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	if r == utf8.RuneError || !unicode.IsLower(r) {
+		return text
+	}
+	return string(unicode.ToUpper(r)) + text[size:]
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// Вмикаємо обрізання відповіді з заданими шаблонами на час тесту
+func setAnswerStrip(t *testing.T, prefixes, suffixes string) error {
+	t.Helper()
+	enabled, oldPrefixes, oldSuffixes := AnswerStripEnabled, AnswerStripPrefixes, AnswerStripSuffixes
+	t.Cleanup(func() {
+		AnswerStripEnabled, AnswerStripPrefixes, AnswerStripSuffixes = enabled, oldPrefixes, oldSuffixes
+		if err := initAnswerPostprocessor(); err != nil {
+			t.Errorf("не вдалося відновити шаблони: %v", err)
+		}
+	})
+	AnswerStripEnabled, AnswerStripPrefixes, AnswerStripSuffixes = true, prefixes, suffixes
+	return initAnswerPostprocessor()
+}
+
+func TestStripAnswerBoilerplate(t *testing.T) {
+	if err := setAnswerStrip(t, defaultAnswerStripPrefixes, defaultAnswerStripSuffixes); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"prefix", "Based on the provided context, the office opens at 9.", "The office opens at 9."},
+		{"ukrainian prefix", "На основі наданого контексту: відпустка триває 24 дні.", "Відпустка триває 24 дні."},
+		{"suffix", "The office opens at 9. I hope this helps!", "The office opens at 9."},
+		{"prefix and suffix", "According to the given sources, it is 24 days. Let me know if you have any other questions.", "It is 24 days."},
+		{"prefix in the middle is kept", "The policy says that, based on the provided context, managers decide.",
+			"The policy says that, based on the provided context, managers decide."},
+		{"suffix in the middle is kept", "I hope this helps explain the rule: managers decide.", "I hope this helps explain the rule: managers decide."},
+		{"prefix inside a word is kept", "Based on the provided contextual data the answer is 5.", "Based on the provided contextual data the answer is 5."},
+		{"only boilerplate is returned unchanged", "I hope this helps!", "I hope this helps!"},
+		{"only prefix and suffix", "Based on the provided context, I hope this helps.", "Based on the provided context, I hope this helps."},
+		{"plain answer", "Відпустка триває 24 дні.", "Відпустка триває 24 дні."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripAnswerBoilerplate(tt.in); got != tt.want {
+				t.Errorf("stripAnswerBoilerplate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripAnswerBoilerplateDisabled(t *testing.T) {
+	if err := setAnswerStrip(t, defaultAnswerStripPrefixes, defaultAnswerStripSuffixes); err != nil {
+		t.Fatal(err)
+	}
+	AnswerStripEnabled = false
+	if err := initAnswerPostprocessor(); err != nil {
+		t.Fatal(err)
+	}
+
+	const answer = "Based on the provided context, the office opens at 9. I hope this helps!"
+	if got := stripAnswerBoilerplate(answer); got != answer {
+		t.Errorf("без ANSWER_STRIP_ENABLED відповідь змінено: %q", got)
+	}
+}
+
+func TestInitAnswerPostprocessorInvalidPattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes string
+		suffixes string
+		variable string
+	}{
+		{"invalid prefix", "(based on", defaultAnswerStripSuffixes, "ANSWER_STRIP_PREFIXES"},
+		{"invalid suffix", defaultAnswerStripPrefixes, "i hope [this", "ANSWER_STRIP_SUFFIXES"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := setAnswerStrip(t, tt.prefixes, tt.suffixes)
+			if err == nil || !strings.Contains(err.Error(), tt.variable) {
+				t.Fatalf("очікувалася помилка з назвою %s, отримано %v", tt.variable, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// Довжина зразка частини в попередньому перегляді в символах
+const previewSampleLength = 300
+
+// Ключ режиму попереднього перегляду в context.Context
+type previewUploadKey struct{}
+
+// Підпис до документа, що вмикає попередній перегляд замість завантаження
+func isPreviewCaption(caption string) bool {
+	caption = strings.TrimSpace(caption)
+	return strings.EqualFold(caption, "preview") || strings.EqualFold(caption, "перегляд")
+}
+
+// Контекст завантаження, у якому частини лише показуються, а не додаються в базу
+func contextWithPreview(ctx context.Context) context.Context {
+	return context.WithValue(ctx, previewUploadKey{}, true)
+}
+
+// Чи завантаження виконується в режимі попереднього перегляду
+func isPreviewUpload(ctx context.Context) bool {
+	preview, _ := ctx.Value(previewUploadKey{}).(bool)
+	return preview
+}
+
+// Звіт попереднього перегляду мовою lang: кількість частин, перша та остання частини і метадані, які було б збережено
+func uploadPreview(lang, collection, namespace, fileName string, chunks []documentChunk, duplicates int, baseMetadata map[string]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(translate(lang, "preview_header", fileName, collection, namespace, len(chunks)))
+	if duplicates > 0 {
+		sb.WriteString(translate(lang, "preview_duplicates", duplicates))
+	}
+
+	sb.WriteString(translate(lang, "preview_first", truncateRunes(chunks[0].Text, previewSampleLength)))
+	if len(chunks) > 1 {
+		last := len(chunks) - 1
+		sb.WriteString(translate(lang, "preview_last", last, truncateRunes(chunks[last].Text, previewSampleLength)))
+	}
+
+	// Метадані першої частини без тексту, який уже показано вище
+	metadata := chunkMetadata(fileName, chunks[0], 0, len(chunks), contentHash(chunks[0].Text), baseMetadata)
+	delete(metadata, "text")
+	if data, err := json.MarshalIndent(metadata, "", "  "); err == nil {
+		sb.WriteString(translate(lang, "preview_metadata", data))
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Типові шаблони запиту до GPT; {{.Query}} - запит користувача, {{.Context}} - знайдені в Pinecone дані
+const (
+	defaultSystemPrompt = "Ти чат-асистент, який відповідає на основі даних з векторної бази Pinecone. Всі відповіді мають базуватися на знайденій інформації. Якщо знайдено кілька варіантів, надай зведення з кожного."
+	defaultUserPrompt   = "Ось ваш запит: {{.Query}}. Ось знайдені дані через Pinecone: {{.Context}}"
+)
+
+// Дані для підстановки в шаблони запиту
+type promptData struct {
+	Query   string
+	Context string
+}
+
+// Шаблони системної інструкції та повідомлення користувача, заповнюються в loadPrompts
+var promptTemplates struct {
+	system *template.Template
+	user   *template.Template
+}
+
+// Зчитуємо шаблони з SYSTEM_PROMPT/SYSTEM_PROMPT_FILE та USER_PROMPT/USER_PROMPT_FILE і перевіряємо їх
+func loadPrompts() error {
+	systemText, err := promptSource("SYSTEM_PROMPT", defaultSystemPrompt)
+	if err != nil {
+		return err
+	}
+	userText, err := promptSource("USER_PROMPT", defaultUserPrompt)
+	if err != nil {
+		return err
+	}
+
+	system, err := template.New("system").Parse(systemText)
+	if err != nil {
+		return fmt.Errorf("Помилка розбору шаблону SYSTEM_PROMPT: %v", err)
+	}
+	user, err := template.New("user").Parse(userText)
+	if err != nil {
+		return fmt.Errorf("Помилка розбору шаблону USER_PROMPT: %v", err)
+	}
+	promptTemplates.system, promptTemplates.user = system, user
+
+	// Пробне заповнення виявляє невідомі поля та шаблони, що гублять контекст або запит
+	const queryMarker, contextMarker = "\x00query\x00", "\x00context\x00"
+	systemPrompt, userPrompt, err := renderPrompts(queryMarker, contextMarker)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(systemPrompt+userPrompt, contextMarker) {
+		return fmt.Errorf("Шаблони запиту мають містити {{.Context}}, інакше GPT не отримає знайдених даних")
+	}
+	if !strings.Contains(systemPrompt+userPrompt, queryMarker) {
+		return fmt.Errorf("Шаблони запиту мають містити {{.Query}}, інакше GPT не отримає запиту користувача")
+	}
+
+	return nil
+}
+
+// Текст шаблону зі змінної name, файлу з name_FILE або типовий
+func promptSource(name, fallback string) (string, error) {
+	if text := os.Getenv(name); text != "" {
+		return text, nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return fallback, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Помилка читання %s_FILE: %v", name, err)
+	}
+	return string(data), nil
+}
+
+// Заповнюємо шаблони системної інструкції та повідомлення користувача
+func renderPrompts(query, context string) (systemPrompt, userPrompt string, err error) {
+	data := promptData{Query: query, Context: context}
+
+	var sb strings.Builder
+	if err := promptTemplates.system.Execute(&sb, data); err != nil {
+		return "", "", fmt.Errorf("Помилка заповнення шаблону SYSTEM_PROMPT: %v", err)
+	}
+	systemPrompt = sb.String()
+
+	sb.Reset()
+	if err := promptTemplates.user.Execute(&sb, data); err != nil {
+		return "", "", fmt.Errorf("Помилка заповнення шаблону USER_PROMPT: %v", err)
+	}
+	userPrompt = sb.String()
+
+	return systemPrompt, userPrompt, nil
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"container/list"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRU кеш з обмеженим розміром і часом життя записів
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // Від нещодавно використаних до давно використаних
+	entries map[string]*list.Element
+}
+
+// Запис кешу з часом, після якого він вважається застарілим
+type lruEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// Новий кеш на size записів; nil, якщо кешування вимкнено (size або ttl дорівнює нулю)
+func newLRUCache[V any](size int, ttl time.Duration) *lruCache[V] {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &lruCache[V]{size: size, ttl: ttl, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Значення за ключем, якщо воно є і ще не застаріло (безпечно для nil)
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*lruEntry[V])
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Додаємо або оновлюємо значення; найдавніше використаний запис витісняється (безпечно для nil)
+func (c *lruCache[V]) Add(key string, value V) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value = &lruEntry[V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Видаляємо запис за ключем (безпечно для nil)
+func (c *lruCache[V]) Remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// Видаляємо записи з ключами, що починаються з prefix; повертаємо кількість видалених (безпечно для nil)
+func (c *lruCache[V]) RemovePrefix(prefix string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(element)
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+var (
+	// Кеш векторів запитів: той самий запит не векторизується повторно
+	queryEmbeddingCache *lruCache[[]float32]
+
+	// Кеш готових відповідей (ANSWER_CACHE_ENABLED) за namespace, моделлю та запитом
+	answerCache *lruCache[string]
+
+	// Влучання в кеші для /stats
+	embeddingCacheHits atomic.Int64
+	answerCacheHits    atomic.Int64
+)
+
+// Створюємо кеші запитів за налаштуваннями QUERY_CACHE_SIZE і QUERY_CACHE_TTL
+func initQueryCaches() {
+	queryEmbeddingCache = newLRUCache[[]float32](QueryCacheSize, QueryCacheTTL)
+	answerCache = nil
+	if AnswerCacheEnabled {
+		answerCache = newLRUCache[string](QueryCacheSize, QueryCacheTTL)
+	}
+}
+
+// Нормалізований запит як ключ кешу: без зайвих пробілів і регістру
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// Ключ кешу відповідей; namespace іде першим, щоб інвалідувати відповіді одного namespace.
+// search - налаштування пошуку користувача, від яких залежать знайдені джерела.
+func answerCacheKey(namespace, collection, model, search, query string) string {
+	return namespace + "\x00" + collection + "\x00" + model + "\x00" + search + "\x00" + normalizeQuery(query)
+}
+
+// Скидаємо кешовані відповіді namespace після зміни його документів
+func invalidateAnswerCache(namespace string) {
+	if removed := answerCache.RemovePrefix(namespace + "\x00"); removed > 0 {
+		slog.Debug("Скинуто кешовані відповіді", "namespace", namespace, "removed", removed)
+	}
+}
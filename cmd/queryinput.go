@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Схоже на команду бота: /слово або /слово@бот на початку запиту
+var commandLikeRx = regexp.MustCompile(`^/[A-Za-z0-9_]+(@\w+)?(\s|$)`)
+
+// Нормалізуємо текстовий запит і перевіряємо, чи варто його обробляти.
+// Повертаємо очищений запит або ключ повідомлення з каталогу з причиною відмови.
+func sanitizeQuery(query string) (cleaned string, problem string) {
+	// Зайві пробіли й переноси рядків не впливають на зміст запиту
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return "", "query_empty"
+	}
+
+	// Відомі команди обробляються окремими обробниками, тож сюди потрапляють лише невідомі
+	if commandLikeRx.MatchString(query) {
+		return "", "unknown_command"
+	}
+	// Випадковий "/" перед звичайним текстом просто прибираємо
+	query = strings.TrimSpace(strings.TrimLeft(query, "/"))
+
+	if !hasLetterOrDigit(query) {
+		return "", "query_no_text"
+	}
+	if len([]rune(query)) < QueryMinLength {
+		return "", "query_too_short"
+	}
+	return query, ""
+}
+
+// Чи є в тексті хоча б одна літера або цифра (а не лише розділові знаки чи емодзі)
+func hasLetterOrDigit(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import "strings"
+
+// Використання бази знань у namespace за реєстром документів
+func namespaceUsage(namespace string) (documents, vectors int) {
+	for _, entry := range listRegistry(namespace) {
+		documents++
+		vectors += entry.Chunks
+	}
+	return documents, vectors
+}
+
+// Перевіряємо квоту користувача (USER_MAX_DOCUMENTS, USER_MAX_VECTORS) перед новим завантаженням.
+// Адміністраторів не обмежуємо, а повторне завантаження наявного файлу не додає документ.
+// Якщо квоту вичерпано, повертаємо повідомлення для користувача мовою lang з поточним використанням.
+func checkUploadQuota(lang string, userID int64, fileName string) (string, bool) {
+	if (UserMaxDocuments == 0 && UserMaxVectors == 0) || isAdmin(userID) {
+		return "", true
+	}
+
+	namespace := userNamespace(userID)
+	documents, vectors := namespaceUsage(namespace)
+	documentsFull := UserMaxDocuments > 0 && documents >= UserMaxDocuments &&
+		(fileName == "" || len(registryFileVectors(namespace, fileName)) == 0)
+	vectorsFull := UserMaxVectors > 0 && vectors >= UserMaxVectors
+	if !documentsFull && !vectorsFull {
+		return "", true
+	}
+
+	var usage []string
+	if UserMaxDocuments > 0 {
+		usage = append(usage, translate(lang, "quota_documents", documents, UserMaxDocuments))
+	}
+	if UserMaxVectors > 0 {
+		usage = append(usage, translate(lang, "quota_vectors", vectors, UserMaxVectors))
+	}
+	return translate(lang, "quota_exceeded", strings.Join(usage, ", ")), false
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+// Вікно, в якому рахуються запити користувача
+const rateLimitWindow = time.Minute
+
+// Час останніх запитів кожного користувача для ковзного вікна
+var rateLimiter = struct {
+	sync.Mutex
+	requests map[int64][]time.Time
+}{requests: make(map[int64][]time.Time)}
+
+// Чи можна обробити ще один запит користувача (не більше RateLimitPerMinute за хвилину)
+func allowRequest(userID int64) bool {
+	if RateLimitPerMinute <= 0 {
+		return true
+	}
+
+	rateLimiter.Lock()
+	defer rateLimiter.Unlock()
+
+	now := time.Now()
+
+	// Відкидаємо запити, що вийшли за межі вікна
+	recent := rateLimiter.requests[userID][:0]
+	for _, t := range rateLimiter.requests[userID] {
+		if now.Sub(t) < rateLimitWindow {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= RateLimitPerMinute {
+		rateLimiter.requests[userID] = recent
+		return false
+	}
+
+	rateLimiter.requests[userID] = append(recent, now)
+	return true
+}
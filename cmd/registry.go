@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Локальний реєстр документів: колекція -> namespace -> назва файлу -> ID його векторів у Pinecone.
+// Pinecone не вміє перелічувати вектори за метаданими, тому ведемо реєстр самі.
+// Окремо зберігаємо хеші вмісту частин (хеш -> ID), щоб не дублювати однаковий текст у namespace колекції.
+var documentRegistry = struct {
+	sync.RWMutex
+	path        string
+	collections map[string]map[string]*registryNamespace
+	model       string // Модель ембеддингів, якою векторизовано індекс
+}{
+	collections: make(map[string]map[string]*registryNamespace),
+}
+
+// Документи та хеші вмісту частин одного namespace колекції
+type registryNamespace struct {
+	Files  map[string][]string `json:"files"`
+	Hashes map[string]string   `json:"hashes,omitempty"`
+}
+
+// Формат файлу реєстру на диску. Files і Hashes - формат до появи колекцій у реєстрі (namespace -> ...),
+// такі записи належать типовій колекції.
+type registryFile struct {
+	Collections    map[string]map[string]*registryNamespace `json:"collections"`
+	Files          map[string]map[string][]string           `json:"files,omitempty"`
+	Hashes         map[string]map[string]string             `json:"hashes,omitempty"`
+	EmbeddingModel string                                   `json:"embedding_model,omitempty"`
+}
+
+// Вектор, доданий до реєстру, разом з хешем його тексту
+type registeredVector struct {
+	ID   string
+	Hash string
+}
+
+// Namespace колекції в реєстрі; create створює відсутній (викликати під documentRegistry.Lock)
+func registryNamespaceLocked(collection, namespace string, create bool) *registryNamespace {
+	namespaces := documentRegistry.collections[collection]
+	if namespaces == nil {
+		if !create {
+			return nil
+		}
+		namespaces = make(map[string]*registryNamespace)
+		documentRegistry.collections[collection] = namespaces
+	}
+	entry := namespaces[namespace]
+	if !create {
+		return entry
+	}
+	// Записи з файлу реєстру можуть не мати порожніх мап
+	if entry == nil {
+		entry = &registryNamespace{}
+		namespaces[namespace] = entry
+	}
+	if entry.Files == nil {
+		entry.Files = make(map[string][]string)
+	}
+	if entry.Hashes == nil {
+		entry.Hashes = make(map[string]string)
+	}
+	return entry
+}
+
+// Прибираємо з реєстру namespace без документів і колекції без namespace (викликати під documentRegistry.Lock)
+func pruneRegistryLocked(collection, namespace string) {
+	namespaces := documentRegistry.collections[collection]
+	if entry, ok := namespaces[namespace]; ok && len(entry.Files) == 0 && len(entry.Hashes) == 0 {
+		delete(namespaces, namespace)
+	}
+	if len(namespaces) == 0 {
+		delete(documentRegistry.collections, collection)
+	}
+}
+
+// Завантажуємо реєстр з диска; відсутній файл означає порожній реєстр
+func loadRegistry(path string) error {
+	documentRegistry.Lock()
+	defer documentRegistry.Unlock()
+
+	documentRegistry.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Помилка читання реєстру документів: %v", err)
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("Помилка розбору реєстру документів: %v", err)
+	}
+
+	// Старий формат реєстру без хешів: одразу namespace -> файл -> ID
+	if file.Collections == nil && file.Files == nil {
+		if err := json.Unmarshal(data, &file.Files); err != nil {
+			return fmt.Errorf("Помилка розбору реєстру документів: %v", err)
+		}
+	}
+	if file.Collections != nil {
+		documentRegistry.collections = file.Collections
+	}
+	// Реєстр до появи колекцій не знав колекції векторів, тож записуємо їх у типову.
+	// Вектори інших колекцій /delete і /update все одно знаходять за фільтром.
+	for namespace, files := range file.Files {
+		entry := registryNamespaceLocked(defaultCollection, namespace, true)
+		for fileName, ids := range files {
+			entry.Files[fileName] = ids
+		}
+	}
+	for namespace, hashes := range file.Hashes {
+		entry := registryNamespaceLocked(defaultCollection, namespace, true)
+		for hash, id := range hashes {
+			entry.Hashes[hash] = id
+		}
+	}
+	documentRegistry.model = file.EmbeddingModel
+
+	return nil
+}
+
+// Записуємо реєстр на диск через тимчасовий файл (викликати під documentRegistry.Lock)
+func saveRegistryLocked() error {
+	if documentRegistry.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(registryFile{
+		Collections:    documentRegistry.collections,
+		EmbeddingModel: documentRegistry.model,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Помилка серіалізації реєстру документів: %v", err)
+	}
+
+	tmpPath := documentRegistry.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("Помилка запису реєстру документів: %v", err)
+	}
+	if err := os.Rename(tmpPath, documentRegistry.path); err != nil {
+		return fmt.Errorf("Помилка збереження реєстру документів: %v", err)
+	}
+
+	return nil
+}
+
+// Модель ембеддингів, записана в реєстрі (порожня, якщо її ще не записували)
+func registryEmbeddingModel() string {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+	return documentRegistry.model
+}
+
+// Записуємо в реєстр модель ембеддингів, якою векторизовано індекс
+func setRegistryEmbeddingModel(model string) error {
+	documentRegistry.Lock()
+	defer documentRegistry.Unlock()
+
+	documentRegistry.model = model
+	return saveRegistryLocked()
+}
+
+// Чи є в реєстрі хоча б один вектор
+func registryHasVectors() bool {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	for _, namespaces := range documentRegistry.collections {
+		for _, entry := range namespaces {
+			for _, ids := range entry.Files {
+				if len(ids) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Додаємо вектори файлу та хеші їхнього вмісту до реєстру namespace колекції
+func registerVectors(collection, namespace, fileName string, vectors ...registeredVector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	documentRegistry.Lock()
+	defer documentRegistry.Unlock()
+
+	entry := registryNamespaceLocked(collection, namespace, true)
+	for _, vector := range vectors {
+		// ID детерміновані, тож повторне завантаження не має дублювати їх у реєстрі
+		if !slices.Contains(entry.Files[fileName], vector.ID) {
+			entry.Files[fileName] = append(entry.Files[fileName], vector.ID)
+		}
+		if vector.Hash != "" {
+			entry.Hashes[vector.Hash] = vector.ID
+		}
+	}
+	return saveRegistryLocked()
+}
+
+// ID вектора з таким самим вмістом у namespace колекції, якщо він уже завантажений
+func registryVectorByHash(collection, namespace, hash string) (string, bool) {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return "", false
+	}
+	id, ok := entry.Hashes[hash]
+	return id, ok
+}
+
+// ID векторів файлу з реєстру namespace колекції
+func registryVectorIDs(collection, namespace, fileName string) []string {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return nil
+	}
+	return append([]string(nil), entry.Files[fileName]...)
+}
+
+// ID векторів файлу з реєстру namespace в усіх колекціях: колекція -> ID
+func registryFileVectors(namespace, fileName string) map[string][]string {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	vectors := make(map[string][]string)
+	for collection, namespaces := range documentRegistry.collections {
+		if entry, ok := namespaces[namespace]; ok && len(entry.Files[fileName]) > 0 {
+			vectors[collection] = append([]string(nil), entry.Files[fileName]...)
+		}
+	}
+	return vectors
+}
+
+// Видаляємо з хешів namespace ті, що вказують на вектори removed, на які вже не посилається жоден файл
+// (викликати під documentRegistry.Lock)
+func removeHashesLocked(entry *registryNamespace, removed map[string]bool) {
+	for _, ids := range entry.Files {
+		for _, id := range ids {
+			delete(removed, id)
+		}
+	}
+	for hash, id := range entry.Hashes {
+		if removed[id] {
+			delete(entry.Hashes, hash)
+		}
+	}
+}
+
+// ID векторів, на які посилаються інші файли namespace колекції, крім fileName.
+// Частина з текстом, що вже є в namespace, не додається вдруге, а посилається на наявний вектор.
+func registryOtherFilesVectorIDs(collection, namespace, fileName string) map[string]bool {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	others := make(map[string]bool)
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return others
+	}
+	for file, ids := range entry.Files {
+		if file == fileName {
+			continue
+		}
+		for _, id := range ids {
+			others[id] = true
+		}
+	}
+	return others
+}
+
+// Видаляємо файл з реєстру namespace колекції
+func unregisterFile(collection, namespace, fileName string) error {
+	documentRegistry.Lock()
+	defer documentRegistry.Unlock()
+
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return nil
+	}
+	ids, ok := entry.Files[fileName]
+	if !ok {
+		return nil
+	}
+	delete(entry.Files, fileName)
+
+	// Хеші векторів, на які більше ніхто не посилається, не вважаються завантаженими
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	removeHashesLocked(entry, removed)
+	pruneRegistryLocked(collection, namespace)
+	return saveRegistryLocked()
+}
+
+// Хеші вмісту частин файлу з реєстру namespace колекції
+func registryFileHashes(collection, namespace, fileName string) map[string]bool {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	hashes := make(map[string]bool)
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return hashes
+	}
+	ids := make(map[string]bool)
+	for _, id := range entry.Files[fileName] {
+		ids[id] = true
+	}
+	for hash, id := range entry.Hashes {
+		if ids[id] {
+			hashes[hash] = true
+		}
+	}
+	return hashes
+}
+
+// Видаляємо з реєстру namespace колекції окремі вектори файлу та хеші, що вказують на них
+func unregisterVectors(collection, namespace, fileName string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	documentRegistry.Lock()
+	defer documentRegistry.Unlock()
+
+	entry := registryNamespaceLocked(collection, namespace, false)
+	if entry == nil {
+		return nil
+	}
+
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	if remaining := slices.DeleteFunc(entry.Files[fileName], func(id string) bool { return removed[id] }); len(remaining) > 0 {
+		entry.Files[fileName] = remaining
+	} else {
+		delete(entry.Files, fileName)
+	}
+	removeHashesLocked(entry, removed)
+	pruneRegistryLocked(collection, namespace)
+	return saveRegistryLocked()
+}
+
+// Документ з реєстру та кількість його частин
+type registryEntry struct {
+	File   string
+	Chunks int
+}
+
+// Перелік документів namespace з реєстру всіх колекцій, відсортований за назвою
+func listRegistry(namespace string) []registryEntry {
+	documentRegistry.RLock()
+	defer documentRegistry.RUnlock()
+
+	chunks := make(map[string]int)
+	for _, namespaces := range documentRegistry.collections {
+		if entry, ok := namespaces[namespace]; ok {
+			for file, ids := range entry.Files {
+				chunks[file] += len(ids)
+			}
+		}
+	}
+	entries := make([]registryEntry, 0, len(chunks))
+	for file, count := range chunks {
+		entries = append(entries, registryEntry{File: file, Chunks: count})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].File < entries[j].File })
+
+	return entries
+}
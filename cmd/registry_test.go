@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Порожній реєстр у тимчасовому файлі на час тесту
+func useTestRegistry(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "registry.json")
+	if content != "" {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	documentRegistry.Lock()
+	documentRegistry.collections = make(map[string]map[string]*registryNamespace)
+	documentRegistry.model = ""
+	documentRegistry.Unlock()
+	t.Cleanup(func() {
+		documentRegistry.Lock()
+		documentRegistry.path = ""
+		documentRegistry.collections = make(map[string]map[string]*registryNamespace)
+		documentRegistry.model = ""
+		documentRegistry.Unlock()
+	})
+
+	if err := loadRegistry(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRegistryLegacyFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		hash    bool
+	}{
+		{"without hashes", `{"user-1": {"a.txt": ["doc-0", "doc-1"]}}`, false},
+		{"without collections", `{"files": {"user-1": {"a.txt": ["doc-0", "doc-1"]}}, "hashes": {"user-1": {"h0": "doc-0"}}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t, tt.content)
+
+			// Записи без колекції належать типовій колекції
+			if ids := registryVectorIDs(defaultCollection, "user-1", "a.txt"); !slices.Equal(ids, []string{"doc-0", "doc-1"}) {
+				t.Errorf("registryVectorIDs() = %v", ids)
+			}
+			if _, ok := registryVectorByHash(defaultCollection, "user-1", "h0"); ok != tt.hash {
+				t.Errorf("хеш h0 знайдено: %v, очікувалося %v", ok, tt.hash)
+			}
+			if entries := listRegistry("user-1"); len(entries) != 1 || entries[0].Chunks != 2 {
+				t.Errorf("listRegistry() = %v", entries)
+			}
+		})
+	}
+}
+
+func TestRegistryHashesPerCollection(t *testing.T) {
+	path := useTestRegistry(t, "")
+
+	if err := registerVectors("resumes", "user-1", "cv.pdf", registeredVector{ID: "doc-0", Hash: "h0"}); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := registryVectorByHash("resumes", "user-1", "h0"); !ok || id != "doc-0" {
+		t.Errorf("registryVectorByHash(resumes) = %q, %v", id, ok)
+	}
+	// Той самий текст в іншій колекції чи namespace ще не завантажений
+	if _, ok := registryVectorByHash("projects", "user-1", "h0"); ok {
+		t.Error("хеш з колекції resumes знайдено в колекції projects")
+	}
+	if _, ok := registryVectorByHash("resumes", "user-2", "h0"); ok {
+		t.Error("хеш з namespace user-1 знайдено в namespace user-2")
+	}
+
+	// Колекції переживають перезапуск
+	if err := loadRegistry(path); err != nil {
+		t.Fatal(err)
+	}
+	if vectors := registryFileVectors("user-1", "cv.pdf"); !slices.Equal(vectors["resumes"], []string{"doc-0"}) || len(vectors) != 1 {
+		t.Errorf("registryFileVectors() = %v", vectors)
+	}
+
+	if err := unregisterFile("resumes", "user-1", "cv.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registryVectorByHash("resumes", "user-1", "h0"); ok {
+		t.Error("хеш видаленого файлу залишився в реєстрі")
+	}
+	if registryHasVectors() {
+		t.Error("після видалення єдиного файлу реєстр не порожній")
+	}
+}
+
+func TestRegistrySharedVectors(t *testing.T) {
+	useTestRegistry(t, "")
+
+	// Файл b.txt має частину з тим самим текстом, що й a.txt, і посилається на її вектор
+	if err := registerVectors(defaultCollection, "user-1", "a.txt", registeredVector{ID: "a-0", Hash: "h0"}, registeredVector{ID: "a-1", Hash: "h1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registerVectors(defaultCollection, "user-1", "b.txt", registeredVector{ID: "a-0", Hash: "h0"}, registeredVector{ID: "b-1", Hash: "h2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	others := registryOtherFilesVectorIDs(defaultCollection, "user-1", "a.txt")
+	if !others["a-0"] || !others["b-1"] || others["a-1"] {
+		t.Errorf("registryOtherFilesVectorIDs(a.txt) = %v", others)
+	}
+
+	// Після видалення a.txt спільна частина залишається завантаженою для b.txt
+	if err := unregisterFile(defaultCollection, "user-1", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := registryVectorByHash(defaultCollection, "user-1", "h0"); !ok || id != "a-0" {
+		t.Errorf("хеш спільної частини видалено разом з a.txt: %q, %v", id, ok)
+	}
+	if _, ok := registryVectorByHash(defaultCollection, "user-1", "h1"); ok {
+		t.Error("хеш частини лише a.txt залишився в реєстрі")
+	}
+
+	if err := unregisterVectors(defaultCollection, "user-1", "b.txt", []string{"a-0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registryVectorByHash(defaultCollection, "user-1", "h0"); ok {
+		t.Error("хеш частини, на яку більше ніхто не посилається, залишився в реєстрі")
+	}
+	if ids := registryVectorIDs(defaultCollection, "user-1", "b.txt"); !slices.Equal(ids, []string{"b-1"}) {
+		t.Errorf("registryVectorIDs(b.txt) = %v", ids)
+	}
+}
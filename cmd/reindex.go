@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	"github.com/spf13/cobra"
+)
+
+// Стан міграції у файлі прогресу: після перезапуску продовжуємо з останнього записаного пакета
+type reindexProgress struct {
+	Source    string   `json:"source"`              // index:<назва> або export:<файл>
+	Target    string   `json:"target"`              // Назва нового індексу
+	Completed []string `json:"completed,omitempty"` // Повністю перенесені namespace старого індексу
+	Namespace string   `json:"namespace,omitempty"` // Namespace, який переноситься зараз
+	Token     string   `json:"token,omitempty"`     // Токен наступної сторінки ListVectors у поточному namespace
+	Line      int      `json:"line,omitempty"`      // Скільки рядків файлу експорту вже оброблено
+	Vectors   int      `json:"vectors"`             // Перенесено векторів
+	Skipped   int      `json:"skipped"`             // Пропущено векторів без тексту
+	path      string   `json:"-"`
+}
+
+// Новий індекс, у який переносяться вектори
+type reindexTarget struct {
+	client      *pinecone.Client
+	name        string
+	host        string
+	dimension   int
+	metric      pinecone.IndexMetric
+	connections map[string]*pinecone.IndexConnection
+}
+
+// Підкоманда aibot reindex: переносить документи в новий індекс, векторизуючи їх заново поточною моделлю
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Перенести документи в новий індекс, векторизувавши їх заново поточною моделлю ембеддингів.",
+	Long: `Читає текст частин зі старого індексу (або з файлу aibot export), векторизує його моделлю
+OPENAI_EMBEDDING_MODEL і додає в новий індекс з тими самими ID, namespace та метаданими.
+Прогрес зберігається після кожного пакета, тож перервану міграцію можна продовжити тією самою командою.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := setupLogging(); err != nil {
+			fatal("Некоректні налаштування логування", "error", err)
+		}
+		if PineconeAPIKey == "" {
+			fatal("Відсутня змінна середовища PINECONE_API_KEY.")
+		}
+		if err := loadConfig(); err != nil {
+			fatal("Некоректні налаштування", "error", err)
+		}
+
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		exportPath, _ := cmd.Flags().GetString("from-export")
+		collection, _ := cmd.Flags().GetString("collection")
+		progressPath, _ := cmd.Flags().GetString("progress")
+		create, _ := cmd.Flags().GetBool("create")
+		cloud, _ := cmd.Flags().GetString("cloud")
+
+		if to == "" {
+			fatal("Вкажіть новий індекс через --to")
+		}
+		source := "index:" + from
+		if exportPath != "" {
+			source = "export:" + exportPath
+		} else if from == to {
+			fatal("Старий і новий індекси мають відрізнятися", "index", to)
+		}
+
+		if create {
+			if _, _, err := ensurePineconeIndex(to, 0, pinecone.Cloud(cloud)); err != nil {
+				fatal("Не вдалося створити новий індекс", "index", to, "error", err)
+			}
+		}
+
+		client, err := pinecone.NewClient(pinecone.NewClientParams{ApiKey: PineconeAPIKey})
+		if err != nil {
+			fatal("Помилка створення клієнта Pinecone", "error", err)
+		}
+		target, err := newReindexTarget(client, to)
+		if err != nil {
+			fatal("Не вдалося підключитися до нового індексу", "index", to, "error", err)
+		}
+
+		progress, err := loadReindexProgress(progressPath, source, to)
+		if err != nil {
+			fatal("Некоректний файл прогресу", "file", progressPath, "error", err)
+		}
+		if progress.Vectors > 0 {
+			slog.Info("Продовжуємо перервану міграцію", "source", source, "target", to, "vectors", progress.Vectors)
+		}
+
+		if exportPath != "" {
+			err = reindexFromExport(target, exportPath, collection, progress)
+		} else {
+			err = reindexFromIndex(client, target, from, progress)
+		}
+		if err != nil {
+			fatal("Міграцію перервано, повторіть команду, щоб продовжити", "vectors", progress.Vectors, "progress", progressPath, "error", err)
+		}
+
+		// Міграцію завершено, тож наступний запуск має починати з початку
+		if err := os.Remove(progressPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Не вдалося видалити файл прогресу", "file", progressPath, "error", err)
+		}
+		// Новий індекс векторизовано поточною моделлю: бот не має відмовлятися запускатися з ним
+		if err := loadRegistry(RegistryPath); err != nil {
+			slog.Warn("Не вдалося завантажити реєстр документів", "error", err)
+		} else if err := setRegistryEmbeddingModel(embeddingModelTag()); err != nil {
+			slog.Warn("Не вдалося записати модель ембеддингів у реєстр", "error", err)
+		}
+		fmt.Printf("Перенесено векторів: %d у індекс %s, пропущено без тексту: %d\n", progress.Vectors, to, progress.Skipped)
+		fmt.Printf("Щоб бот використовував новий індекс, встановіть PINECONE_INDEX=%s (або вкажіть його для колекції в PINECONE_COLLECTIONS).\n", to)
+	},
+}
+
+// Читаємо файл прогресу; файл іншої міграції не використовуємо, щоб не пропустити вектори
+func loadReindexProgress(path, source, target string) (*reindexProgress, error) {
+	progress := &reindexProgress{Source: source, Target: target, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("Помилка розбору файлу прогресу: %v", err)
+	}
+	if progress.Source != source || progress.Target != target {
+		return nil, fmt.Errorf("Файл належить іншій міграції (%s -> %s); видаліть його або вкажіть інший через --progress", progress.Source, progress.Target)
+	}
+	return progress, nil
+}
+
+// Записуємо прогрес через тимчасовий файл, щоб переривання не пошкодило його
+func (p *reindexProgress) save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Помилка серіалізації прогресу: %v", err)
+	}
+	tmpPath := p.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("Помилка запису прогресу: %v", err)
+	}
+	if err := os.Rename(tmpPath, p.path); err != nil {
+		return fmt.Errorf("Помилка збереження прогресу: %v", err)
+	}
+	return nil
+}
+
+// Описуємо новий індекс і перевіряємо, що його розмірність збігається з поточною моделлю
+func newReindexTarget(client *pinecone.Client, name string) (*reindexTarget, error) {
+	index, err := withTimeoutRetry("Pinecone DescribeIndex", PineconeTimeout, func(ctx context.Context) (*pinecone.Index, error) {
+		return client.DescribeIndex(ctx, name)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка опису індексу (створіть його з --create): %v", err)
+	}
+
+	if dimension, ok := embeddingDimension(); ok && dimension != int(index.Dimension) {
+		return nil, fmt.Errorf("Модель %s створює вектори розмірності %d, а індекс %s має розмірність %d",
+			embeddingModel(), dimension, name, index.Dimension)
+	}
+
+	return &reindexTarget{
+		client:      client,
+		name:        name,
+		host:        index.Host,
+		dimension:   int(index.Dimension),
+		metric:      index.Metric,
+		connections: make(map[string]*pinecone.IndexConnection),
+	}, nil
+}
+
+// Підключення до namespace нового індексу
+func (t *reindexTarget) index(namespace string) (*pinecone.IndexConnection, error) {
+	if conn, ok := t.connections[namespace]; ok {
+		return conn, nil
+	}
+	conn, err := t.client.Index(pinecone.NewIndexConnParams{Host: t.host, Namespace: namespace})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка підключення до індексу %s: %v", t.name, err)
+	}
+	t.connections[namespace] = conn
+	return conn, nil
+}
+
+// Векторизуємо текст записів однієї namespace заново та додаємо їх у новий індекс
+func (t *reindexTarget) upsert(namespace string, records []exportRecord, progress *reindexProgress) error {
+	var texts []string
+	var ready []exportRecord
+	for _, record := range records {
+		text, _ := record.Metadata["text"].(string)
+		if text == "" {
+			slog.Warn("Пропущено вектор без тексту в метаданих", "namespace", namespace, "id", record.ID)
+			progress.Skipped++
+			continue
+		}
+		texts = append(texts, text)
+		ready = append(ready, record)
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+
+	embeddings, failed, err := embedChunks(context.Background(), texts, nil)
+	if err != nil {
+		return fmt.Errorf("Помилка векторизації вектора %s: %v", ready[failed].ID, err)
+	}
+
+	vectors := make([]*pinecone.Vector, 0, len(ready))
+	for i, record := range ready {
+		if len(embeddings[i]) != t.dimension {
+			return fmt.Errorf("Розмірність вектора (%d) не збігається з розмірністю індексу %s (%d)", len(embeddings[i]), t.name, t.dimension)
+		}
+		record.Metadata["embedding_model"] = embeddingModelTag()
+		metadata, err := metadataStruct(context.Background(), record.Metadata)
+		if err != nil {
+			return fmt.Errorf("Помилка перетворення метаданих вектора %s: %v", record.ID, err)
+		}
+		vector := &pinecone.Vector{Id: record.ID, Values: embeddings[i], Metadata: metadata}
+		if t.metric == pinecone.Dotproduct {
+			vector.SparseValues = sparseVector(texts[i], false)
+		}
+		vectors = append(vectors, vector)
+	}
+
+	index, err := t.index(namespace)
+	if err != nil {
+		return err
+	}
+	if _, err := withTimeoutRetry("Pinecone UpsertVectors", PineconeTimeout, func(ctx context.Context) (uint32, error) {
+		return index.UpsertVectors(ctx, vectors)
+	}); err != nil {
+		return fmt.Errorf("Запит UpsertVectors не вдався: %v", err)
+	}
+
+	progress.Vectors += len(vectors)
+	return nil
+}
+
+// Переносимо всі namespace старого індексу сторінками ListVectors; після кожної сторінки зберігаємо прогрес
+func reindexFromIndex(client *pinecone.Client, target *reindexTarget, name string, progress *reindexProgress) error {
+	source, err := withTimeoutRetry("Pinecone DescribeIndex", PineconeTimeout, func(ctx context.Context) (*pinecone.Index, error) {
+		return client.DescribeIndex(ctx, name)
+	})
+	if err != nil {
+		return fmt.Errorf("Помилка опису старого індексу %s: %v", name, err)
+	}
+	sourceConn, err := client.Index(pinecone.NewIndexConnParams{Host: source.Host})
+	if err != nil {
+		return fmt.Errorf("Помилка підключення до індексу %s: %v", name, err)
+	}
+	stats, err := withTimeoutRetry("Pinecone DescribeIndexStats", PineconeTimeout, func(ctx context.Context) (*pinecone.DescribeIndexStatsResponse, error) {
+		return sourceConn.DescribeIndexStats(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("Помилка отримання статистики індексу: %v", err)
+	}
+
+	namespaces := make([]string, 0, len(stats.Namespaces))
+	for namespace := range stats.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		if slices.Contains(progress.Completed, namespace) {
+			continue
+		}
+		index, err := client.Index(pinecone.NewIndexConnParams{Host: source.Host, Namespace: namespace})
+		if err != nil {
+			return fmt.Errorf("Помилка підключення до індексу %s: %v", name, err)
+		}
+
+		// Токен сторінки зберігається лише для namespace, який переносився під час переривання
+		var token *string
+		if progress.Namespace == namespace && progress.Token != "" {
+			token = &progress.Token
+		}
+		progress.Namespace = namespace
+
+		limit := uint32(exportPageSize)
+		for {
+			page, err := withTimeoutRetry("Pinecone ListVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.ListVectorsResponse, error) {
+				return index.ListVectors(ctx, &pinecone.ListVectorsRequest{Limit: &limit, PaginationToken: token})
+			})
+			if err != nil {
+				return fmt.Errorf("Помилка переліку векторів: %v", err)
+			}
+
+			ids := make([]string, 0, len(page.VectorIds))
+			for _, id := range page.VectorIds {
+				if id != nil {
+					ids = append(ids, *id)
+				}
+			}
+			if len(ids) > 0 {
+				fetched, err := withTimeoutRetry("Pinecone FetchVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+					return index.FetchVectors(ctx, ids)
+				})
+				if err != nil {
+					return fmt.Errorf("Помилка отримання векторів: %v", err)
+				}
+
+				records := make([]exportRecord, 0, len(ids))
+				for _, id := range ids {
+					vector, ok := fetched.Vectors[id]
+					if !ok {
+						continue
+					}
+					record := exportRecord{Namespace: namespace, ID: id}
+					if vector.Metadata != nil {
+						record.Metadata = vector.Metadata.AsMap()
+					}
+					records = append(records, record)
+				}
+				if err := target.upsert(namespace, records, progress); err != nil {
+					return err
+				}
+			}
+
+			if page.NextPaginationToken == nil || *page.NextPaginationToken == "" {
+				break
+			}
+			token = page.NextPaginationToken
+			progress.Token = *token
+			if err := progress.save(); err != nil {
+				return err
+			}
+			slog.Info("Прогрес міграції", "namespace", namespace, "vectors", progress.Vectors, "total", stats.TotalVectorCount)
+		}
+
+		progress.Completed = append(progress.Completed, namespace)
+		progress.Namespace, progress.Token = "", ""
+		if err := progress.save(); err != nil {
+			return err
+		}
+		slog.Info("Namespace перенесено", "namespace", namespace, "vectors", progress.Vectors, "total", stats.TotalVectorCount)
+	}
+	return nil
+}
+
+// Переносимо записи з файлу aibot export (лише колекції collection, якщо її вказано).
+// Після кожного пакета зберігаємо номер останнього обробленого рядка.
+func reindexFromExport(target *reindexTarget, path, collection string, progress *reindexProgress) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Помилка відкриття файлу експорту: %v", err)
+	}
+	defer file.Close()
+
+	var batch []exportRecord
+	batchEnd := progress.Line // Останній рядок, що входить у пакет
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := target.upsert(batch[0].Namespace, batch, progress); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		progress.Line = batchEnd
+		if err := progress.save(); err != nil {
+			return err
+		}
+		slog.Info("Прогрес міграції", "line", progress.Line, "vectors", progress.Vectors)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20) // Рядок зі значеннями вектора може бути великим
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line <= progress.Line {
+			continue
+		}
+
+		var record exportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("Рядок %d: некоректний JSON: %v", line, err)
+		}
+		if record.ID == "" {
+			return fmt.Errorf("Рядок %d: відсутній id вектора", line)
+		}
+		if collection != "" && record.Collection != collection {
+			batchEnd = line
+			continue
+		}
+
+		// Пакет містить вектори лише одного namespace
+		if len(batch) > 0 && (batch[0].Namespace != record.Namespace || len(batch) >= PineconeUpsertBatchSize) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, record)
+		batchEnd = line
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Помилка читання файлу експорту: %v", err)
+	}
+	return flush()
+}
+
+func init() {
+	aibotCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().String("from", PineconeIndex, "Старий індекс, з якого читаємо текст частин (типово - PINECONE_INDEX)")
+	reindexCmd.Flags().String("from-export", "", "Читати текст частин з файлу aibot export замість старого індексу")
+	reindexCmd.Flags().String("collection", "", "Переносити з файлу експорту лише цю колекцію (типово - усі записи)")
+	reindexCmd.Flags().String("to", "", "Новий індекс потрібної розмірності")
+	reindexCmd.Flags().Bool("create", false, "Створити новий індекс з розмірністю поточної моделі, якщо його немає")
+	reindexCmd.Flags().String("cloud", string(pinecone.Aws), "Хмара нового serverless індексу (для --create)")
+	reindexCmd.Flags().String("progress", "reindex-progress.json", "Файл прогресу для продовження перерваної міграції")
+}
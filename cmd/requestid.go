@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Ключ контексту запиту в telebot.Context
+const requestContextKey = "request_ctx"
+
+// Ключ ID запиту в context.Context
+type requestIDKey struct{}
+
+// Короткий випадковий ID запиту для пошуку всіх його рядків у лозі
+func newRequestID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Контекст із ID запиту
+func contextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// ID запиту з контексту або порожній рядок
+func requestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware: кожне оновлення Telegram отримує власний ID запиту в контексті
+func requestIDMiddleware(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		c.Set(requestContextKey, contextWithRequestID(context.Background(), newRequestID()))
+		return next(c)
+	}
+}
+
+// Контекст запиту, створений requestIDMiddleware
+func requestContext(c telebot.Context) context.Context {
+	if ctx, ok := c.Get(requestContextKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// Додаємо до повідомлення про помилку ID запиту, щоб користувач міг назвати його, звертаючись по допомогу
+func withRequestID(ctx context.Context, message string) string {
+	if id := requestIDFromContext(ctx); id != "" {
+		return message + "\n\nКод запиту: " + id
+	}
+	return message
+}
+
+// Обгортка slog.Handler, що додає request_id з контексту до кожного запису
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
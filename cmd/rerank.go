@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Скільки символів кожного фрагмента показуємо моделі для оцінки релевантності
+const rerankPassageLength = 1500
+
+// Інструкція для оцінки релевантності фрагментів
+const rerankSystemPrompt = `Ти оцінюєш, наскільки кожен фрагмент допомагає відповісти на запит користувача.
+Постав кожному фрагменту оцінку від 0 (не стосується запиту) до 10 (прямо відповідає на запит).
+Відповідай лише JSON-об'єктом {"scores": [...]} з оцінками в тому самому порядку, що й фрагменти.`
+
+// Переранжовуємо збіги Pinecone за оцінками моделі RERANK_MODEL і залишаємо RERANK_TOP_N найкращих.
+// Якщо переранжування вимкнене або не вдалося, повертаємо збіги без змін.
+func rerankMatches(ctx context.Context, query string, matches *pinecone.QueryVectorsResponse) *pinecone.QueryVectorsResponse {
+	if !RerankEnabled || len(matches.Matches) == 0 {
+		return matches
+	}
+
+	started := time.Now()
+	scores, err := rerankScores(ctx, query, matches.Matches)
+	if err != nil {
+		slog.WarnContext(ctx, "Переранжування не вдалося, використовуємо порядок Pinecone", "error", err)
+		return matches
+	}
+
+	order := make([]int, len(matches.Matches))
+	for i := range order {
+		order[i] = i
+	}
+	// За однакової оцінки зберігаємо порядок Pinecone
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	if len(order) > RerankTopN {
+		order = order[:RerankTopN]
+	}
+
+	reranked := *matches
+	reranked.Matches = make([]*pinecone.ScoredVector, len(order))
+	before := make([]string, len(matches.Matches))
+	after := make([]string, len(order))
+	for i, match := range matches.Matches {
+		before[i] = fmt.Sprintf("%s:%.3f", match.Vector.Id, match.Score)
+	}
+	for i, index := range order {
+		reranked.Matches[i] = matches.Matches[index]
+		after[i] = fmt.Sprintf("%s:%g", matches.Matches[index].Vector.Id, scores[index])
+	}
+
+	slog.InfoContext(ctx, "Збіги переранжовано", "before", len(matches.Matches), "after", len(order), "latency_ms", time.Since(started).Milliseconds())
+	slog.DebugContext(ctx, "Порядок збігів до та після переранжування", "before", before, "after", after)
+
+	return &reranked
+}
+
+// Оцінки релевантності кожного збігу від 0 до 10 одним запитом до OpenAI
+func rerankScores(ctx context.Context, query string, matches []*pinecone.ScoredVector) ([]float64, error) {
+	var passages strings.Builder
+	fmt.Fprintf(&passages, "Запит: %s\n\nФрагменти:\n", query)
+	for i, match := range matches {
+		text := ""
+		if match.Vector.Metadata != nil {
+			text, _ = match.Vector.Metadata.AsMap()["text"].(string)
+		}
+		fmt.Fprintf(&passages, "\n[%d] %s\n", i+1, truncateRunes(text, rerankPassageLength))
+	}
+
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model: RerankModel,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: rerankSystemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: passages.String()},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (rerank)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Помилка запиту на переранжування: %w", err)
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("OpenAI не повернув оцінок релевантності")
+	}
+
+	var result struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return nil, fmt.Errorf("Некоректна відповідь з оцінками релевантності: %v", err)
+	}
+	if len(result.Scores) != len(matches) {
+		return nil, fmt.Errorf("Отримано %d оцінок для %d фрагментів", len(result.Scores), len(matches))
+	}
+
+	return result.Scores, nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Виконуємо операцію з повторними спробами на тимчасових помилках OpenAI та Pinecone.
+// Затримка зростає експоненційно від RetryBaseDelay з випадковим розкидом.
+func withRetry[T any](ctx context.Context, operation string, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= RetryCount || !isRetryableError(err) {
+			return result, err
+		}
+
+		delay := retryDelay(attempt)
+		slog.WarnContext(ctx, "Спроба не вдалася, повторюємо", "operation", operation, "attempt", attempt+1, "max_attempts", RetryCount+1, "delay", delay, "error", err)
+		time.Sleep(delay)
+	}
+}
+
+// Повторні спроби, де кожна спроба отримує власний контекст з таймаутом
+func withTimeoutRetry[T any](operation string, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	return withContextTimeoutRetry(context.Background(), operation, timeout, fn)
+}
+
+// Те саме, що withTimeoutRetry, але контексти спроб походять від parent: після його скасування повторів немає
+func withContextTimeoutRetry[T any](parent context.Context, operation string, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	return withRetry(parent, operation, func() (T, error) {
+		if err := parent.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		defer observeExternalCall(operation, time.Now())
+		return fn(ctx)
+	})
+}
+
+// Чи перевищено час очікування відповіді (контекст HTTP запиту або дедлайн gRPC)
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if st, ok := status.FromError(err); ok && st.Code() == codes.DeadlineExceeded {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Експоненційна затримка з розкидом до половини поточного значення
+func retryDelay(attempt int) time.Duration {
+	delay := RetryBaseDelay << attempt
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// Чи варто повторювати запит: 429 та 5xx від OpenAI, тимчасові коди gRPC від Pinecone, мережеві таймаути
+func isRetryableError(err error) bool {
+	// Вичерпана квота теж приходить з кодом 429, але повтор її не поверне
+	if classifyOpenAIError(err) == openAIErrorQuota {
+		return false
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return isRetryableStatus(reqErr.HTTPStatusCode)
+	}
+
+	var embeddingErr *embeddingHTTPError
+	if errors.As(err, &embeddingErr) {
+		return isRetryableStatus(embeddingErr.StatusCode)
+	}
+
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// HTTP статуси, на яких має сенс повторити запит
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
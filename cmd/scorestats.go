@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// Кількість інтервалів гістограми оцінок у /stats: [0, 0.1), [0.1, 0.2), ..., [0.9, 1]
+const scoreBuckets = 10
+
+// Накопичений розподіл оцінок збігів Pinecone з моменту запуску для звіту /stats
+var scoreStats struct {
+	buckets [scoreBuckets]atomic.Int64
+	matches atomic.Int64 // Усі збіги до відсікання за порогом
+	passed  atomic.Int64 // Збіги, що пройшли поріг
+}
+
+// Логуємо мінімальну, максимальну й середню оцінку збігів пошуку та скільки з них пройшли поріг minScore,
+// і додаємо оцінки до гістограми /stats і метрики tgbot_match_score
+func recordScoreDistribution(ctx context.Context, collection string, matches []*pinecone.ScoredVector, minScore float32) {
+	if len(matches) == 0 {
+		slog.InfoContext(ctx, "Пошук не повернув збігів", "collection", collection, "threshold", minScore)
+		return
+	}
+
+	lowest, highest, sum := matches[0].Score, matches[0].Score, 0.0
+	passed := 0
+	for _, match := range matches {
+		lowest, highest = min(lowest, match.Score), max(highest, match.Score)
+		sum += float64(match.Score)
+		if match.Score >= minScore {
+			passed++
+		}
+
+		bucket := min(max(int(match.Score*scoreBuckets), 0), scoreBuckets-1)
+		scoreStats.buckets[bucket].Add(1)
+		metricMatchScore.Observe(float64(match.Score))
+	}
+	scoreStats.matches.Add(int64(len(matches)))
+	scoreStats.passed.Add(int64(passed))
+
+	slog.InfoContext(ctx, "Розподіл оцінок збігів", "collection", collection, "matches", len(matches), "passed", passed, "threshold", minScore,
+		"score_min", lowest, "score_max", highest, "score_mean", sum/float64(len(matches)))
+}
+
+// Рядки звіту /stats з гістограмою оцінок збігів
+func scoreReport() string {
+	total := scoreStats.matches.Load()
+	if total == 0 {
+		return "Оцінки збігів: даних ще немає\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Оцінки збігів: %d, пройшли поріг: %d (%.0f%%)\n", total, scoreStats.passed.Load(),
+		float64(scoreStats.passed.Load())*100/float64(total))
+	for i := scoreBuckets - 1; i >= 0; i-- {
+		count := scoreStats.buckets[i].Load()
+		if count == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "  %.1f-%.1f: %d %s\n", float64(i)/scoreBuckets, float64(i+1)/scoreBuckets, count,
+			strings.Repeat("▇", int((count*20+total-1)/total)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Найбільша кількість збігів, яку користувач може задати через /set topk
+const userTopKMax = 50
+
+// Значення /set, що повертає налаштування до глобального
+const settingDefault = "default"
+
+// Ключ кількості збігів пошуку в context.Context
+type searchTopKKey struct{}
+
+// Контекст пошуку з кількістю збігів користувача замість PINECONE_TOP_K
+func contextWithTopK(ctx context.Context, topK int) context.Context {
+	return context.WithValue(ctx, searchTopKKey{}, topK)
+}
+
+// Скільки збігів залишати в пошуку: налаштування користувача з контексту або PINECONE_TOP_K
+func searchTopK(ctx context.Context) int {
+	if topK, ok := ctx.Value(searchTopKKey{}).(int); ok && topK > 0 {
+		return topK
+	}
+	return PineconeTopK
+}
+
+// Налаштування пошуку користувача з /set або глобальні, якщо їх не задано
+func userSearchSettings(userID int64) (topK int, minScore float32) {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	topK, minScore = PineconeTopK, PineconeMinScore
+	if session, ok := userSessions.sessions[userID]; ok {
+		if session.TopK > 0 {
+			topK = session.TopK
+		}
+		if session.MinScore != nil {
+			minScore = *session.MinScore
+		}
+	}
+	return topK, minScore
+}
+
+// Зберігаємо кількість збігів пошуку користувача (0 - глобальне значення)
+func setSessionTopK(userID int64, topK int) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	session.TopK = topK
+	markSessionsDirty()
+}
+
+// Зберігаємо поріг оцінки збігів користувача (nil - глобальне значення)
+func setSessionMinScore(userID int64, minScore *float32) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	session.MinScore = minScore
+	markSessionsDirty()
+}
+
+// Поточні налаштування пошуку мовою lang для відповіді на /set без аргументів
+func describeSearchSettings(lang string, userID int64) string {
+	topK, minScore := userSearchSettings(userID)
+	return translate(lang, "settings_current", topK, PineconeTopK, minScore, PineconeMinScore, userTopKMax, settingDefault)
+}
+
+// Обробка /set <налаштування> <значення>: кількість збігів і поріг оцінки пошуку для цього користувача
+func handleSet(m telebot.Context) error {
+	ctx := requestContext(m)
+	lang := userLanguage(m)
+	userID := m.Sender().ID
+	args := strings.Fields(m.Message().Payload)
+	if len(args) == 0 {
+		return m.Send(describeSearchSettings(lang, userID))
+	}
+	if len(args) != 2 {
+		return m.Send(translate(lang, "settings_usage", userTopKMax))
+	}
+
+	setting, value := strings.ToLower(args[0]), strings.ToLower(args[1])
+	switch setting {
+	case "topk":
+		if value == settingDefault {
+			setSessionTopK(userID, 0)
+			slog.InfoContext(ctx, "Користувач повернув типову кількість збігів", "user_id", userID)
+			return m.Send(translate(lang, "settings_topk_default", PineconeTopK))
+		}
+		topK, err := strconv.Atoi(value)
+		if err != nil || topK < 1 || topK > userTopKMax {
+			return m.Send(translate(lang, "settings_topk_invalid", userTopKMax))
+		}
+		setSessionTopK(userID, topK)
+		slog.InfoContext(ctx, "Користувач змінив кількість збігів", "user_id", userID, "top_k", topK)
+		return m.Send(translate(lang, "settings_topk_set", topK, topK))
+
+	case "threshold":
+		if value == settingDefault {
+			setSessionMinScore(userID, nil)
+			slog.InfoContext(ctx, "Користувач повернув типовий поріг оцінки", "user_id", userID)
+			return m.Send(translate(lang, "settings_threshold_default", PineconeMinScore))
+		}
+		parsed, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 32)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return m.Send(translate(lang, "settings_threshold_invalid"))
+		}
+		minScore := float32(parsed)
+		setSessionMinScore(userID, &minScore)
+		slog.InfoContext(ctx, "Користувач змінив поріг оцінки", "user_id", userID, "min_score", minScore)
+		return m.Send(translate(lang, "settings_threshold_set", minScore))
+	}
+	return m.Send(translate(lang, "settings_unknown", args[0]))
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Повертаємо сесію користувача, створюючи її за потреби (викликати під userSessions.Lock)
+func getOrCreateSessionLocked(userID int64) *UserSession {
+	session, ok := userSessions.sessions[userID]
+	if !ok {
+		session = &UserSession{}
+		userSessions.sessions[userID] = session
+	}
+	return session
+}
+
+// Копія історії розмови користувача для побудови запиту до GPT
+func getSessionHistory(userID int64) []openai.ChatCompletionMessage {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	session, ok := userSessions.sessions[userID]
+	if !ok {
+		return nil
+	}
+
+	history := make([]openai.ChatCompletionMessage, len(session.History))
+	copy(history, session.History)
+	return history
+}
+
+// Додаємо пару запит-відповідь до історії та обрізаємо старі репліки
+func appendSessionHistory(userID int64, query, answer string) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	session.History = append(session.History,
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: query},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: answer},
+	)
+	session.History = trimHistory(session.History, HistoryMaxTurns, HistoryMaxTokens)
+	markSessionsDirty()
+}
+
+// Позначаємо, що бот чекає від користувача документ; скасування очікування скидає й режим оновлення
+func setAwaitingDocument(userID int64, awaiting bool) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	if session.AwaitingDocument == awaiting && (awaiting || !session.AwaitingUpdate) {
+		return
+	}
+	session.AwaitingDocument = awaiting
+	if !awaiting {
+		session.AwaitingUpdate = false
+	}
+	markSessionsDirty()
+}
+
+// Позначаємо, що бот чекає нову версію вже завантаженого документа
+func setAwaitingUpdate(userID int64) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	session.AwaitingDocument = true
+	session.AwaitingUpdate = true
+	markSessionsDirty()
+}
+
+// Чи очікуваний документ має замінити попередню версію файлу
+func isAwaitingUpdate(userID int64) bool {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	session, ok := userSessions.sessions[userID]
+	return ok && session.AwaitingDocument && session.AwaitingUpdate
+}
+
+// Чи чекає бот від користувача документ
+func isAwaitingDocument(userID int64) bool {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	session, ok := userSessions.sessions[userID]
+	return ok && session.AwaitingDocument
+}
+
+// Обираємо колекцію для пошуку та завантажень; порожній рядок означає всі колекції
+func setSessionCollection(userID int64, collection string) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	if session.Collection == collection {
+		return
+	}
+	session.Collection = collection
+	markSessionsDirty()
+}
+
+// Колекція, обрана користувачем через /collection (порожній рядок - всі)
+func sessionCollection(userID int64) string {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	if session, ok := userSessions.sessions[userID]; ok {
+		return session.Collection
+	}
+	return ""
+}
+
+// Очищаємо історію та очікування документа лише в сесії цього користувача
+func resetSession(userID int64) {
+	userSessions.Lock()
+	defer userSessions.Unlock()
+
+	session := getOrCreateSessionLocked(userID)
+	session.History = nil
+	session.AwaitingDocument = false
+	session.AwaitingUpdate = false
+	markSessionsDirty()
+}
+
+// Залишаємо не більше maxTurns останніх пар і вкладаємося в бюджет токенів
+func trimHistory(history []openai.ChatCompletionMessage, maxTurns, maxTokens int) []openai.ChatCompletionMessage {
+	if len(history) > maxTurns*2 {
+		history = history[len(history)-maxTurns*2:]
+	}
+
+	tokens := 0
+	for _, message := range history {
+		tokens += estimateTokens(message.Content)
+	}
+
+	// Видаляємо найстаріші пари, доки історія не вміститься в бюджет
+	for tokens > maxTokens && len(history) >= 2 {
+		tokens -= estimateTokens(history[0].Content) + estimateTokens(history[1].Content)
+		history = history[2:]
+	}
+
+	// Нова частина зрізу, щоб старий масив не утримувався в пам'яті
+	return append([]openai.ChatCompletionMessage(nil), history...)
+}
+
+// Звіт /whoami мовою lang: що бот знає про користувача та куди спрямовані його запити
+func whoamiReport(lang string, userID int64) string {
+	userSessions.RLock()
+	var session UserSession
+	if stored, ok := userSessions.sessions[userID]; ok {
+		session = *stored
+	}
+	userSessions.RUnlock()
+
+	yesNo := func(value bool) string {
+		if value {
+			return translate(lang, "yes")
+		}
+		return translate(lang, "no")
+	}
+
+	collections := translate(lang, "whoami_all_collections", strings.Join(collectionNames(), ", "))
+	if isKnownCollection(session.Collection) {
+		collections = session.Collection
+	}
+	namespaces := make([]string, 0, len(PineconeSearchNamespaces)+1)
+	for _, namespace := range searchNamespaces(userID) {
+		namespaces = append(namespaces, namespaceLabel(namespace))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Telegram ID: %d\n", userID)
+	topK, minScore := userSearchSettings(userID)
+	sb.WriteString(translate(lang, "whoami_report", yesNo(isAdmin(userID)), len(session.History)/2, HistoryMaxTurns, yesNo(session.AwaitingDocument),
+		namespaceLabel(userNamespace(userID)), strings.Join(namespaces, ", "), collections, topK, minScore))
+	return sb.String()
+}
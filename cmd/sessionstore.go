@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Постійне сховище сесій; userSessions лишається основною копією в пам'яті
+type SessionStore interface {
+	Load() (map[int64]*UserSession, error)
+	Save(sessions map[int64]*UserSession) error
+}
+
+// Сховище сесій у JSON файлі
+type fileSessionStore struct {
+	path string
+}
+
+// Створюємо сховище сесій у файлі path
+func newFileSessionStore(path string) *fileSessionStore {
+	return &fileSessionStore{path: path}
+}
+
+// Зчитуємо сесії з файлу; відсутній файл означає відсутність сесій
+func (s *fileSessionStore) Load() (map[int64]*UserSession, error) {
+	sessions := make(map[int64]*UserSession)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Помилка читання сесій: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("Помилка розбору сесій: %v", err)
+	}
+	return sessions, nil
+}
+
+// Записуємо сесії у файл через тимчасовий файл
+func (s *fileSessionStore) Save(sessions map[int64]*UserSession) error {
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Помилка серіалізації сесій: %v", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("Помилка запису сесій: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("Помилка збереження сесій: %v", err)
+	}
+	return nil
+}
+
+// Відкладене збереження сесій: зміни за SessionSaveDelay записуються одним разом.
+// Порядок блокувань: userSessions -> sessionPersistence; saving береться без sessionPersistence.
+var sessionPersistence = struct {
+	sync.Mutex
+	store  SessionStore
+	timer  *time.Timer
+	saving sync.Mutex // Не даємо двом збереженням писати одночасно
+}{}
+
+// Підключаємо сховище та відновлюємо з нього сесії
+func initSessionStore(store SessionStore) error {
+	sessions, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	userSessions.Lock()
+	userSessions.sessions = sessions
+	userSessions.Unlock()
+
+	sessionPersistence.Lock()
+	sessionPersistence.store = store
+	sessionPersistence.Unlock()
+
+	slog.Info("Сесії користувачів відновлено", "sessions", len(sessions))
+	return nil
+}
+
+// Позначаємо сесії зміненими; збереження відбудеться через SessionSaveDelay
+func markSessionsDirty() {
+	sessionPersistence.Lock()
+	defer sessionPersistence.Unlock()
+
+	if sessionPersistence.store == nil || sessionPersistence.timer != nil {
+		return
+	}
+	sessionPersistence.timer = time.AfterFunc(SessionSaveDelay, saveSessions)
+}
+
+// Зберігаємо знімок усіх сесій у сховище
+func saveSessions() {
+	sessionPersistence.Lock()
+	sessionPersistence.timer = nil
+	store := sessionPersistence.store
+	sessionPersistence.Unlock()
+
+	if store == nil {
+		return
+	}
+
+	sessionPersistence.saving.Lock()
+	defer sessionPersistence.saving.Unlock()
+
+	if err := store.Save(snapshotSessions()); err != nil {
+		slog.Error("Помилка збереження сесій", "error", err)
+	}
+}
+
+// Негайно зберігаємо сесії під час зупинки бота
+func flushSessions() {
+	sessionPersistence.Lock()
+	if sessionPersistence.timer != nil {
+		sessionPersistence.timer.Stop()
+	}
+	sessionPersistence.Unlock()
+
+	saveSessions()
+}
+
+// Копія сесій, яку можна серіалізувати без утримання блокування
+func snapshotSessions() map[int64]*UserSession {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	snapshot := make(map[int64]*UserSession, len(userSessions.sessions))
+	for userID, session := range userSessions.sessions {
+		snapshot[userID] = &UserSession{
+			AwaitingDocument: session.AwaitingDocument,
+			AwaitingUpdate:   session.AwaitingUpdate,
+			History:          append([]openai.ChatCompletionMessage(nil), session.History...),
+			Collection:       session.Collection,
+			TopK:             session.TopK,
+			MinScore:         session.MinScore,
+		}
+	}
+	return snapshot
+}
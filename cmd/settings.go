@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Налаштування, які адміністратор змінює під час роботи і які переживають перезапуск
+type runtimeSettings struct {
+	Model string `json:"model,omitempty"` // Активна модель GPT, обрана через /model
+}
+
+// Поточні налаштування під м'ютексом: їх одночасно читають обробники запитів
+var settingsState = struct {
+	sync.RWMutex
+	path     string
+	settings runtimeSettings
+}{}
+
+// Завантажуємо налаштування з файлу; модель поза OPENAI_MODEL_ALLOWLIST ігноруємо
+func loadSettings(path string) error {
+	settingsState.Lock()
+	defer settingsState.Unlock()
+
+	settingsState.path = path
+	settingsState.settings = runtimeSettings{Model: OpenAIModel}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Помилка читання налаштувань: %v", err)
+	}
+
+	var saved runtimeSettings
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("Помилка розбору налаштувань: %v", err)
+	}
+
+	if saved.Model != "" {
+		if isAllowedModel(saved.Model) {
+			settingsState.settings.Model = saved.Model
+		} else {
+			slog.Warn("Збережена модель не входить до OPENAI_MODEL_ALLOWLIST, використовуємо типову", "model", saved.Model, "default", OpenAIModel)
+		}
+	}
+
+	return nil
+}
+
+// Записуємо налаштування на диск через тимчасовий файл (викликати під settingsState.Lock)
+func saveSettingsLocked() error {
+	if settingsState.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(settingsState.settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Помилка серіалізації налаштувань: %v", err)
+	}
+
+	tmpPath := settingsState.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("Помилка запису налаштувань: %v", err)
+	}
+	if err := os.Rename(tmpPath, settingsState.path); err != nil {
+		return fmt.Errorf("Помилка збереження налаштувань: %v", err)
+	}
+	return nil
+}
+
+// Чи дозволена модель для /model
+func isAllowedModel(model string) bool {
+	for _, allowed := range OpenAIModelAllowlist {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Активна модель GPT для відповідей
+func currentModel() string {
+	settingsState.RLock()
+	defer settingsState.RUnlock()
+
+	if settingsState.settings.Model == "" {
+		return OpenAIModel
+	}
+	return settingsState.settings.Model
+}
+
+// Змінюємо активну модель GPT і зберігаємо вибір
+func setModel(model string) error {
+	if !isAllowedModel(model) {
+		return fmt.Errorf("Модель %s не входить до списку дозволених", model)
+	}
+
+	settingsState.Lock()
+	defer settingsState.Unlock()
+
+	settingsState.settings.Model = model
+	return saveSettingsLocked()
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Ключ типу документа, визначеного за вмістом, у telebot.Context
+const documentTypeKey = "document_type"
+
+// Скільки байтів з початку файлу читаємо для сигнатур і перевірки тексту
+const sniffHeaderSize = 4096
+
+// Сигнатури форматів на початку файлу
+var (
+	pdfSignature = []byte("%PDF-")
+	zipSignature = []byte("PK\x03\x04")
+)
+
+// Назви типів документів для повідомлень користувачу
+var documentTypeLabels = map[string]string{
+	"pdf":      "PDF",
+	"docx":     "DOCX",
+	"json":     "JSON",
+	"csv":      "CSV",
+	"txt":      "TXT",
+	"md":       "Markdown",
+	"markdown": "Markdown",
+}
+
+// Визначаємо тип документа за вмістом, а розширення використовуємо лише як підказку:
+// сигнатура %PDF- - PDF, ZIP-архів з word/document.xml - DOCX, коректний JSON - JSON,
+// інший текст UTF-8 - CSV, Markdown (md або markdown) або TXT за розширенням. Порожній рядок - формат не підтримується.
+// Файл читається через ReadAt, тож його позиція не змінюється.
+func detectDocumentType(file *os.File, size int64, fileName string) (string, error) {
+	header := make([]byte, sniffHeaderSize)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, pdfSignature):
+		return "pdf", nil
+	case bytes.HasPrefix(header, zipSignature):
+		if isDocxArchive(file, size) {
+			return "docx", nil
+		}
+		return "", nil
+	case bytes.HasPrefix(header, oleSignature):
+		// Захищений паролем DOCX: обробник DOCX пояснить, чому текст недоступний
+		if isDocx(fileName) {
+			return "docx", nil
+		}
+		return "", nil
+	}
+
+	// Двійкові файли відкидаємо одразу, щоб не читати їх цілком
+	if bytes.IndexByte(header, 0) >= 0 || !utf8.Valid(trimIncompleteRune(header, n < sniffHeaderSize)) {
+		return "", nil
+	}
+
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(content) {
+		return "", nil
+	}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "json", nil
+	}
+
+	switch {
+	case isCSV(fileName):
+		return "csv", nil
+	case isMarkdown(fileName):
+		return documentType(fileName), nil
+	}
+	return "txt", nil
+}
+
+// Чи ZIP-архів є документом Word
+func isDocxArchive(r io.ReaderAt, size int64) bool {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return false
+	}
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// Відкидаємо символ UTF-8, обрізаний межею заголовка (complete - заголовок містить весь файл)
+func trimIncompleteRune(header []byte, complete bool) []byte {
+	if complete {
+		return header
+	}
+	for i := 0; i < utf8.UTFMax && i < len(header); i++ {
+		if utf8.RuneStart(header[len(header)-1-i]) {
+			if !utf8.FullRune(header[len(header)-1-i:]) {
+				return header[:len(header)-1-i]
+			}
+			break
+		}
+	}
+	return header
+}
+
+// Тип документа, що завантажується: визначений за вмістом або за розширенням файлу
+func uploadDocumentType(m telebot.Context, fileName string) string {
+	if docType, ok := m.Get(documentTypeKey).(string); ok && docType != "" {
+		return docType
+	}
+	return documentType(fileName)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	telebot "gopkg.in/telebot.v3"
+)
+
+const (
+	sourceCallbackPrefix = "source" // Дані кнопки джерела: source|<id>
+	sourceButtonsMax     = 10       // Скільки джерел показуємо кнопками під відповіддю
+	sourceButtonLength   = 30       // Довжина підпису кнопки в символах
+	sourcePendingSize    = 5000     // Скільки фрагментів джерел зберігаємо для кнопок
+	sourcePendingTTL     = 24 * time.Hour
+)
+
+// Фрагмент джерела, який показує кнопка під відповіддю
+type sourceChunk struct {
+	UserID int64
+	Name   string
+	Text   string
+}
+
+// Фрагменти джерел за коротким ID з даних кнопки (дані кнопки Telegram обмежені 64 байтами).
+// Застарілі записи витісняються за розміром кешу та sourcePendingTTL.
+var pendingSources = newLRUCache[sourceChunk](sourcePendingSize, sourcePendingTTL)
+
+// Додаємо над кнопками відгуку кнопки джерел "[1] назва файлу" в порядку нумерації джерел у контексті.
+// Повертаємо markup без змін, якщо кнопки джерел вимкнено (SOURCE_BUTTONS=false) або джерел немає.
+func withSourceButtons(lang string, markup *telebot.ReplyMarkup, userID int64, matches *pinecone.QueryVectorsResponse) *telebot.ReplyMarkup {
+	if !SourceButtons || len(matches.Matches) == 0 {
+		return markup
+	}
+
+	var buttons []telebot.Btn
+	for i, match := range matches.Matches {
+		if len(buttons) == sourceButtonsMax {
+			break
+		}
+		if match.Vector == nil || match.Vector.Metadata == nil {
+			continue
+		}
+		metadata := match.Vector.Metadata.AsMap()
+		text, _ := metadata["text"].(string)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		id, err := callbackID()
+		if err != nil {
+			slog.Warn("Не вдалося створити ID кнопки джерела", "error", err)
+			return markup
+		}
+		name := sourceName(metadata)
+		if chunk, ok := metadata["chunk"].(float64); ok {
+			name = translate(lang, "source_chunk", name, int(chunk)+1)
+		}
+		pendingSources.Add(id, sourceChunk{UserID: userID, Name: name, Text: strings.TrimSpace(text)})
+
+		// Номер кнопки збігається з номером джерела, на який посилається відповідь
+		label := fmt.Sprintf("[%d] %s", i+1, sourceName(metadata))
+		buttons = append(buttons, telebot.Btn{Text: truncateRunes(label, sourceButtonLength), Data: sourceCallbackPrefix + "|" + id})
+	}
+	if len(buttons) == 0 {
+		return markup
+	}
+
+	combined := &telebot.ReplyMarkup{}
+	combined.Inline(combined.Split(2, buttons)...)
+	if markup != nil {
+		combined.InlineKeyboard = append(combined.InlineKeyboard, markup.InlineKeyboard...)
+	}
+	return combined
+}
+
+// Обробка натискання кнопки джерела: надсилаємо текст фрагмента окремим повідомленням
+func handleSourceCallback(c telebot.Context, data string) error {
+	_, id, _ := strings.Cut(data, "|")
+	source, ok := pendingSources.Get(id)
+	if !ok || source.UserID != c.Sender().ID {
+		return c.Respond(&telebot.CallbackResponse{Text: translate(userLanguage(c), "source_expired")})
+	}
+	if err := c.Respond(); err != nil {
+		slog.WarnContext(requestContext(c), "Не вдалося відповісти на натискання кнопки", "error", err)
+	}
+
+	slog.InfoContext(requestContext(c), "Користувач переглянув джерело відповіді", "user_id", c.Sender().ID, "source", source.Name)
+	return sendLongMessage(c, fmt.Sprintf("📄 %s\n\n%s", source.Name, source.Text))
+}
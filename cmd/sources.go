@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// Інструкція, як посилатися на пронумеровані джерела контексту
+const sourceCitationInstruction = "Знайдені дані подано як пронумеровані джерела. Посилайся на них за номером у квадратних дужках, наприклад [1] або [2, 3], і не вигадуй номерів, яких немає."
+
+// Службові поля метаданих, які не несуть змісту для відповіді
+var internalMetadataKeys = map[string]bool{
+	"text": true, "file": true, "title": true, "chunk": true, "chunks": true, "content_hash": true,
+	"uploaded_at": true, "uploader_id": true, "content_type": true, "type": true, "images": true, "namespace": true,
+}
+
+// Назва джерела для посилань: назва документа з метаданих, інакше назва файлу
+func sourceName(metadata map[string]interface{}) string {
+	if title, _ := metadata["title"].(string); title != "" {
+		if file, _ := metadata["file"].(string); file != "" && file != title {
+			return fmt.Sprintf("%s (%s)", title, file)
+		}
+		return title
+	}
+	if file, _ := metadata["file"].(string); file != "" {
+		return file
+	}
+	return "невідоме джерело"
+}
+
+// Пронумерований блок контексту для моделі: заголовок із назвою та оцінкою, змістовні поля метаданих і текст
+func sourceBlock(number int, match *pinecone.ScoredVector) string {
+	var metadata map[string]interface{}
+	if match.Vector != nil && match.Vector.Metadata != nil {
+		metadata = match.Vector.Metadata.AsMap()
+	}
+
+	var sb strings.Builder
+	// Namespace є в метаданих, лише якщо пошук охоплював кілька namespace
+	name := sourceName(metadata)
+	if namespace, _ := metadata["namespace"].(string); namespace != "" {
+		name += ", namespace " + namespace
+	}
+	sb.WriteString(fmt.Sprintf("[Джерело %d — %s, оцінка %.2f]\n", number, name, match.Score))
+
+	// Додаткові поля (наприклад, колонки CSV чи автор PDF) у сталому порядку
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if !internalMetadataKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sb.WriteString(fmt.Sprintf("%s: %v\n", key, metadata[key]))
+	}
+
+	if text, _ := metadata["text"].(string); text != "" {
+		sb.WriteString(strings.TrimSpace(text))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// Лічильники використання з моменту старту процесу
+var (
+	startedAt      = time.Now()
+	queriesHandled atomic.Int64 // Оброблені запити (текстові та голосові)
+	uploadsHandled atomic.Int64 // Оброблені завантаження документів
+)
+
+// Чи є користувач адміністратором (ADMIN_IDS)
+func isAdmin(userID int64) bool {
+	return AdminIDs[userID]
+}
+
+// Кількість активних сесій користувачів
+func activeSessionCount() int {
+	userSessions.RLock()
+	defer userSessions.RUnlock()
+
+	return len(userSessions.sessions)
+}
+
+// Формуємо звіт /stats для адміністратора
+func buildStatsReport() string {
+	var sb strings.Builder
+	sb.WriteString("📊 Статистика бота\n\n")
+
+	// Статистика кожного індексу окремо, якщо колекцій кілька
+	for _, collection := range collectionNames() {
+		label := "Векторів в індексі"
+		if len(PineconeCollections) > 1 {
+			label = fmt.Sprintf("Векторів у колекції %s", collection)
+		}
+
+		index, err := pineconeIndex(collection, "")
+		if err == nil {
+			var stats *pinecone.DescribeIndexStatsResponse
+			stats, err = withTimeoutRetry("Pinecone DescribeIndexStats", PineconeTimeout, func(ctx context.Context) (*pinecone.DescribeIndexStatsResponse, error) {
+				return index.DescribeIndexStats(ctx)
+			})
+			if err == nil {
+				sb.WriteString(fmt.Sprintf("%s: %d (namespace: %d)\n", label, stats.TotalVectorCount, len(stats.Namespaces)))
+			}
+		}
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("%s: невідомо (%v)\n", label, err))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("Модель: %s\n", currentModel()))
+	sb.WriteString(fmt.Sprintf("Активних сесій: %d\n", activeSessionCount()))
+	sb.WriteString(fmt.Sprintf("Час роботи: %s\n", time.Since(startedAt).Round(time.Second)))
+	sb.WriteString(fmt.Sprintf("Запитів оброблено: %d\n", queriesHandled.Load()))
+	sb.WriteString(fmt.Sprintf("Документів завантажено: %d\n", uploadsHandled.Load()))
+	sb.WriteString(fmt.Sprintf("Влучань у кеш: векторів %d, відповідей %d\n", embeddingCacheHits.Load(), answerCacheHits.Load()))
+	sb.WriteString(pineconeBreaker.report())
+	sb.WriteString(scoreReport())
+	sb.WriteString(feedbackReport())
+	sb.WriteString(usageReport())
+
+	return sb.String()
+}
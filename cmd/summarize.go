@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Найбільший обсяг тексту в токенах для одного запиту підсумку; довші документи підсумовуються частинами
+const summarizeGroupTokens = 12000
+
+// Інструкції для підсумку документа (%s - назва файлу) та для об'єднання підсумків його частин
+const (
+	summarizePrompt       = "Стисло підсумуй документ «%s»: головні теми, ключові факти та висновки. Не додавай нічого, чого немає в тексті."
+	summarizeMapPrompt    = "Це частина документа «%s». Стисло випиши її головні думки та ключові факти, щоб потім об'єднати їх із підсумками інших частин."
+	summarizeReducePrompt = "Нижче - підсумки послідовних частин документа «%s». Об'єднай їх в один стислий підсумок усього документа: головні теми, ключові факти та висновки."
+)
+
+// Обробка /summarize <файл>: збираємо частини файлу з Pinecone за реєстром і підсумовуємо їх
+func handleSummarize(m telebot.Context) error {
+	lang := userLanguage(m)
+	fileName := strings.TrimSpace(m.Message().Payload)
+	if fileName == "" {
+		return m.Send(translate(lang, "summarize_usage"))
+	}
+
+	namespace := userNamespace(m.Sender().ID)
+	// Однаковий документ у двох колекціях має однакові ID, тож частину беремо один раз
+	var ids []string
+	for _, collectionIDs := range registryFileVectors(namespace, fileName) {
+		ids = append(ids, collectionIDs...)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) == 0 {
+		return m.Send(summarizeNotFoundMessage(lang, namespace, fileName))
+	}
+	if !allowRequest(m.Sender().ID) {
+		return m.Send(translate(lang, "rate_limited"))
+	}
+
+	// Підсумок довгого документа можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(m), m.Sender().ID)
+	defer finish()
+
+	stopTyping := startTyping(m)
+	defer stopTyping()
+
+	started := time.Now()
+	slog.InfoContext(ctx, "Користувач запросив підсумок документа", "user_id", m.Sender().ID, "file", fileName, "chunks", len(ids))
+
+	chunks, err := fetchFileChunks(ctx, namespace, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка отримання частин документа", "user_id", m.Sender().ID, "file", fileName, "error", err)
+		return m.Send(withRequestID(ctx, translate(lang, "summarize_fetch_error", err)))
+	}
+	if len(chunks) == 0 {
+		return m.Send(translate(lang, "summarize_no_text", fileName))
+	}
+
+	summary, err := summarizeChunks(ctx, fileName, chunks, languageNames[lang])
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return m.Send(translate(lang, "summarize_cancelled"))
+		}
+		slog.ErrorContext(ctx, "Помилка підсумку документа", "user_id", m.Sender().ID, "file", fileName, "stage", "summarize", "error", err)
+		metricErrors.WithLabelValues("generation").Inc()
+		if isTimeoutError(err) {
+			return m.Send(withRequestID(ctx, translate(lang, "timeout")))
+		}
+		return m.Send(withRequestID(ctx, translate(lang, "summarize_error", userFacingError(err))))
+	}
+
+	slog.InfoContext(ctx, "Підсумок документа готовий", "user_id", m.Sender().ID, "file", fileName, "summary_len", len([]rune(summary)),
+		"latency_ms", time.Since(started).Milliseconds())
+	return newStreamingMessage(m).FinishMarkdown(fmt.Sprintf("📝 %s\n\n%s", fileName, summary), nil)
+}
+
+// Підказка мовою lang, коли файлу немає в реєстрі: схожі назви або перелік документів
+func summarizeNotFoundMessage(lang, namespace, fileName string) string {
+	var similar []string
+	needle := strings.ToLower(fileName)
+	for _, entry := range listRegistry(namespace) {
+		name := strings.ToLower(entry.File)
+		if strings.Contains(name, needle) || strings.Contains(needle, name) {
+			similar = append(similar, entry.File)
+		}
+	}
+	if len(similar) > 0 {
+		return translate(lang, "summarize_similar", fileName, strings.Join(similar, ", "))
+	}
+	return translate(lang, "summarize_not_found", fileName)
+}
+
+// Тексти частин файлу з усіх колекцій у порядку частин документа
+func fetchFileChunks(ctx context.Context, namespace string, ids []string) ([]string, error) {
+	type chunk struct {
+		index int
+		text  string
+	}
+	var chunks []chunk
+	found := make(map[string]bool, len(ids))
+
+	// Записи реєстру до появи в ньому колекцій віднесено до типової колекції, тож шукаємо вектори в кожній, доки не знайдемо всі
+	for _, collection := range collectionNames() {
+		if len(found) == len(ids) {
+			break
+		}
+		index, err := pineconeIndex(collection, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		for start := 0; start < len(ids); start += exportPageSize {
+			var page []string
+			for _, id := range ids[start:min(start+exportPageSize, len(ids))] {
+				if !found[id] {
+					page = append(page, id)
+				}
+			}
+			if len(page) == 0 {
+				continue
+			}
+
+			fetched, err := withContextTimeoutRetry(ctx, "Pinecone FetchVectors", PineconeTimeout, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+				return index.FetchVectors(ctx, page)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Помилка отримання векторів: %v", err)
+			}
+			for id, vector := range fetched.Vectors {
+				found[id] = true
+				if vector.Metadata == nil {
+					continue
+				}
+				metadata := vector.Metadata.AsMap()
+				text, _ := metadata["text"].(string)
+				if strings.TrimSpace(text) == "" {
+					continue
+				}
+				position, _ := metadata["chunk"].(float64)
+				chunks = append(chunks, chunk{index: int(position), text: text})
+			}
+		}
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].index < chunks[j].index })
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.text
+	}
+	return texts, nil
+}
+
+// Підсумовуємо текст частин: одним запитом, якщо він вміщується в summarizeGroupTokens,
+// інакше спершу підсумовуємо групи частин (map), а потім об'єднуємо їхні підсумки (reduce)
+func summarizeChunks(ctx context.Context, fileName string, chunks []string, language string) (string, error) {
+	model := currentModel()
+	instruction := "Відповідай тією мовою, якою написано документ."
+	if language != "" {
+		instruction = fmt.Sprintf("Відповідай %s мовою.", language)
+	}
+
+	groups := groupByTokens(model, chunks, summarizeGroupTokens)
+	if len(groups) == 1 {
+		return summarizeText(ctx, model, fmt.Sprintf(summarizePrompt, fileName)+" "+instruction, groups[0])
+	}
+
+	// Підсумки груп самі можуть не вміститися в один запит, тож повторюємо, доки не залишиться одна група
+	for round := 1; len(groups) > 1; round++ {
+		slog.InfoContext(ctx, "Підсумовуємо документ частинами", "file", fileName, "round", round, "groups", len(groups))
+		summaries := make([]string, len(groups))
+		for i, group := range groups {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			summary, err := summarizeText(ctx, model, fmt.Sprintf(summarizeMapPrompt, fileName)+" "+instruction, group)
+			if err != nil {
+				return "", err
+			}
+			summaries[i] = summary
+		}
+		groups = groupByTokens(model, summaries, summarizeGroupTokens)
+	}
+	return summarizeText(ctx, model, fmt.Sprintf(summarizeReducePrompt, fileName)+" "+instruction, groups[0])
+}
+
+// Об'єднуємо тексти в групи, кожна з яких не перевищує limit токенів (окремий довший текст стає власною групою)
+func groupByTokens(model string, texts []string, limit int) []string {
+	var groups []string
+	var current strings.Builder
+	currentTokens := 0
+	for _, text := range texts {
+		tokens := countTokens(model, text)
+		if current.Len() > 0 && currentTokens+tokens > limit {
+			groups = append(groups, current.String())
+			current.Reset()
+			currentTokens = 0
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(text)
+		currentTokens += tokens
+	}
+	if current.Len() > 0 {
+		groups = append(groups, current.String())
+	}
+	return groups
+}
+
+// Один запит підсумку до моделі OpenAI
+func summarizeText(ctx context.Context, model, instruction, text string) (string, error) {
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model:       model,
+		Temperature: OpenAITemperature,
+		MaxTokens:   OpenAIMaxTokens,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: instruction},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (summarize)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Помилка запиту підсумку: %w", err)
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("Модель повернула порожній підсумок")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
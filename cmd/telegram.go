@@ -0,0 +1,461 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Максимальна довжина одного повідомлення Telegram в одиницях UTF-16 (так рахує сам Telegram)
+const telegramMessageLimit = 4096
+
+// Як часто оновлюємо статус "друкує…" (Telegram показує його близько 5 секунд)
+const typingRefreshInterval = 4 * time.Second
+
+// Скільки разів повторюємо запит після відповіді Telegram 429 і найдовша пауза, яку чекаємо
+const (
+	telegramFloodRetries  = 3
+	telegramFloodMaxDelay = time.Minute
+)
+
+// Виконуємо запит до Telegram, а після 429 (FloodError) чекаємо retry_after секунд і повторюємо
+func withFloodRetry[T any](fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := fn()
+		var flood telebot.FloodError
+		if err == nil || !errors.As(err, &flood) || attempt == telegramFloodRetries {
+			return result, err
+		}
+
+		delay := time.Duration(flood.RetryAfter) * time.Second
+		if delay > telegramFloodMaxDelay {
+			return result, err
+		}
+		if delay <= 0 {
+			delay = time.Second
+		}
+		slog.Warn("Telegram обмежив частоту запитів, чекаємо", "retry_after", delay, "attempt", attempt+1)
+		time.Sleep(delay)
+	}
+}
+
+// Надсилаємо повідомлення у чат контексту з урахуванням обмеження частоти Telegram
+func sendWithRetry(m telebot.Context, what interface{}, opts ...interface{}) error {
+	_, err := withFloodRetry(func() (struct{}, error) {
+		return struct{}{}, m.Send(what, opts...)
+	})
+	return err
+}
+
+// Редагуємо повідомлення з урахуванням обмеження частоти Telegram
+func editWithRetry(bot *telebot.Bot, message telebot.Editable, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	return withFloodRetry(func() (*telebot.Message, error) {
+		return bot.Edit(message, what, opts...)
+	})
+}
+
+// Показуємо статус "друкує…", доки не буде викликана повернена функція зупинки
+func startTyping(m telebot.Context) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(typingRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := m.Notify(telebot.Typing); err != nil {
+				slog.Warn("Не вдалося надіслати статус друку", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Чекаємо завершення горутини, щоб не залишати її після відповіді
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Надсилаємо довгий текст кількома повідомленнями, не розриваючи блоки коду
+func sendLongMessage(m telebot.Context, text string, opts ...interface{}) error {
+	for _, part := range splitMessage(text, telegramMessageLimit) {
+		if err := sendWithRetry(m, part, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ділимо текст на частини до limit одиниць UTF-16 по межах абзаців і рядків.
+// Блок коду переноситься цілком, а якщо він сам довший за ліміт - ділиться по рядках
+// з повторним відкриттям і закриттям ``` у кожній частині. Символ UTF-8 ніколи не розрізається.
+func splitMessage(text string, limit int) []string {
+	if telegramLength(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, block := range splitBlocks(text) {
+		blockLen := telegramLength(block)
+		if blockLen > limit {
+			flush()
+			parts = append(parts, splitLongBlock(block, limit)...)
+			continue
+		}
+
+		if current.Len() > 0 && telegramLength(current.String())+2+blockLen > limit {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(block)
+	}
+	flush()
+
+	return parts
+}
+
+// Ділимо текст на абзаци, розділені порожніми рядками; блок коду завжди один абзац
+func splitBlocks(text string) []string {
+	var blocks []string
+	var current []string
+	inCode := false
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !inCode {
+				flush()
+			}
+			current = append(current, line)
+			if inCode {
+				flush()
+			}
+			inCode = !inCode
+			continue
+		}
+
+		if !inCode && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return blocks
+}
+
+// Ділимо завеликий абзац або блок коду по рядках
+func splitLongBlock(block string, limit int) []string {
+	lines := strings.Split(block, "\n")
+
+	// Для блоку коду кожна частина має власні огорожі ```
+	opening, closing := "", ""
+	if len(lines) > 1 && strings.HasPrefix(strings.TrimSpace(lines[0]), "```") {
+		opening, closing = lines[0]+"\n", "\n```"
+		lines = lines[1:]
+		if strings.TrimSpace(lines[len(lines)-1]) == "```" {
+			lines = lines[:len(lines)-1]
+		}
+	}
+	budget := limit - telegramLength(opening) - telegramLength(closing)
+
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, opening+current.String()+closing)
+			current.Reset()
+		}
+	}
+
+	for _, line := range lines {
+		// Рядок довший за ліміт ріжемо по реченнях, словах або, в крайньому разі, по символах
+		for telegramLength(line) > budget {
+			flush()
+			head, tail := cutLine(line, budget)
+			parts = append(parts, opening+head+closing)
+			line = tail
+		}
+
+		if current.Len() > 0 && telegramLength(current.String())+1+telegramLength(line) > budget {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(line)
+	}
+	flush()
+
+	return parts
+}
+
+// Довжина тексту так, як її рахує Telegram: в одиницях UTF-16, тож емодзі поза BMP займають дві
+func telegramLength(text string) int {
+	length := 0
+	for _, r := range text {
+		if n := utf16.RuneLen(r); n > 0 {
+			length += n
+		} else {
+			length++ // Некоректний UTF-8 Telegram отримає як U+FFFD
+		}
+	}
+	return length
+}
+
+// Найдовший префікс тексту до limit одиниць UTF-16, що закінчується на межі символу
+// і не відриває від символу модифікатори (комбіновані знаки, селектори варіантів, ZWJ в емодзі)
+func telegramPrefix(text string, limit int) string {
+	end, length := 0, 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		n := utf16.RuneLen(r)
+		if n < 0 {
+			n = 1
+		}
+		if length+n > limit {
+			break
+		}
+		length += n
+		end += size
+	}
+	// Відступаємо назад, доки розріз припадає всередину послідовності символу
+	for end > 0 && end < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		prev, _ := utf8.DecodeLastRuneInString(text[:end])
+		if !isRuneExtension(next) && prev != zeroWidthJoiner {
+			break
+		}
+		_, size := utf8.DecodeLastRuneInString(text[:end])
+		end -= size
+	}
+	return text[:end]
+}
+
+// Нульової ширини з'єднувач, що склеює емодзі в один символ
+const zeroWidthJoiner = '\u200d'
+
+// Символи, що змінюють попередній і не можуть починати частину повідомлення
+func isRuneExtension(r rune) bool {
+	return r == zeroWidthJoiner || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) ||
+		unicode.Is(unicode.Variation_Selector, r) || (r >= 0x1F3FB && r <= 0x1F3FF) // Відтінки шкіри емодзі
+}
+
+// Ріжемо рядок, довший за limit: спершу шукаємо кінець речення, потім пробіл у другій половині частини,
+// а якщо їх немає - ріжемо по межі символу. Повертаємо першу частину та решту рядка.
+func cutLine(line string, limit int) (head, tail string) {
+	prefix := telegramPrefix(line, limit)
+	if prefix == "" {
+		// Один символ довший за ліміт: рівно такий ліміт на практиці неможливий, але не зациклюємося
+		_, size := utf8.DecodeRuneInString(line)
+		return line[:size], line[size:]
+	}
+
+	minCut := len(prefix) / 2
+	sentenceCut, spaceCut := -1, -1
+	for i, r := range prefix {
+		if !unicode.IsSpace(r) || i < minCut {
+			continue
+		}
+		spaceCut = i
+		if before, _ := utf8.DecodeLastRuneInString(prefix[:i]); strings.ContainsRune(".!?…", before) {
+			sentenceCut = i
+		}
+	}
+
+	switch {
+	case sentenceCut > 0:
+		spaceCut = sentenceCut
+	case spaceCut < 0:
+		return prefix, line[len(prefix):]
+	}
+	// Пробіл, по якому різали, не переносимо в наступну частину
+	_, size := utf8.DecodeRuneInString(line[spaceCut:])
+	return line[:spaceCut], line[spaceCut+size:]
+}
+
+// Обрізаємо текст до limit одиниць UTF-16 по межі символу, додаючи "…", якщо його скорочено
+func truncateTelegram(text string, limit int) string {
+	if telegramLength(text) <= limit {
+		return text
+	}
+	return telegramPrefix(text, limit-1) + "…"
+}
+
+// Повідомлення, яке поступово редагується під час стрімінгу відповіді
+type streamingMessage struct {
+	ctx      telebot.Context
+	message  *telebot.Message
+	lastText string    // Останній надісланий текст, щоб не редагувати без змін
+	lastEdit time.Time // Час останнього редагування для обмеження частоти
+}
+
+// Створюємо повідомлення для стрімінгу; саме повідомлення надсилається з першим фрагментом
+func newStreamingMessage(m telebot.Context) *streamingMessage {
+	return &streamingMessage{ctx: m}
+}
+
+// Оновлюємо повідомлення частковою відповіддю не частіше за StreamEditInterval
+func (s *streamingMessage) Update(text string) {
+	if time.Since(s.lastEdit) < StreamEditInterval {
+		return
+	}
+	s.show(text)
+}
+
+// Показуємо текст (під час стрімінгу лише те, що вміщується в одне повідомлення)
+func (s *streamingMessage) show(text string) {
+	text = telegramPrefix(text, telegramMessageLimit)
+	if strings.TrimSpace(text) == "" || text == s.lastText {
+		return
+	}
+
+	var err error
+	if s.message == nil {
+		s.message, err = withFloodRetry(func() (*telebot.Message, error) {
+			return s.ctx.Bot().Send(s.ctx.Recipient(), text)
+		})
+	} else {
+		_, err = editWithRetry(s.ctx.Bot(), s.message, text)
+	}
+	if err != nil {
+		slog.Warn("Не вдалося оновити повідомлення під час стрімінгу", "error", err)
+		return
+	}
+
+	s.lastText = text
+	s.lastEdit = time.Now()
+}
+
+// Замінюємо часткову відповідь повною; те, що не вмістилося, надсилаємо окремими повідомленнями
+func (s *streamingMessage) Finish(answer string) error {
+	if s.message == nil {
+		return sendLongMessage(s.ctx, answer)
+	}
+
+	parts := splitMessage(answer, telegramMessageLimit)
+	if parts[0] != s.lastText {
+		if _, err := editWithRetry(s.ctx.Bot(), s.message, parts[0]); err != nil {
+			return err
+		}
+	}
+	for _, part := range parts[1:] {
+		if err := sendWithRetry(s.ctx, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Замінюємо часткову відповідь повною, відформатованою з Markdown у Telegram HTML.
+// Текст ділиться до перетворення, бо ліміт Telegram рахується без HTML тегів.
+// Якщо Telegram не прийняв розмітку, частина надсилається звичайним текстом.
+// markup (може бути nil) додається до останньої частини відповіді.
+func (s *streamingMessage) FinishMarkdown(answer string, markup *telebot.ReplyMarkup) error {
+	parts := splitMessage(answer, telegramMessageLimit)
+	for i, part := range parts {
+		formatted := markdownToTelegramHTML(part)
+
+		// Кнопки показуємо лише під останньою частиною
+		var partMarkup *telebot.ReplyMarkup
+		if i == len(parts)-1 {
+			partMarkup = markup
+		}
+
+		var err error
+		if i == 0 && s.message != nil {
+			_, err = editWithRetry(s.ctx.Bot(), s.message, formatted, telebot.ModeHTML, partMarkup)
+			if err != nil {
+				slog.Warn("Telegram не прийняв HTML відповіді, надсилаємо звичайний текст", "error", err)
+				if part != s.lastText || partMarkup != nil {
+					_, err = editWithRetry(s.ctx.Bot(), s.message, part, partMarkup)
+				} else {
+					err = nil
+				}
+			}
+		} else {
+			err = sendWithRetry(s.ctx, formatted, telebot.ModeHTML, partMarkup)
+			if err != nil {
+				slog.Warn("Telegram не прийняв HTML відповіді, надсилаємо звичайний текст", "error", err)
+				err = sendWithRetry(s.ctx, part, partMarkup)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Повідомлення про хід індексації документа, яке редагується на кожному етапі
+// і наприкінці замінюється підсумком. Stage і Progress безпечні для nil.
+type uploadStatus struct {
+	message *streamingMessage
+	lang    string // Мова повідомлень користувача
+}
+
+// Створюємо статус індексації; саме повідомлення надсилається з першим етапом
+func newUploadStatus(m telebot.Context) *uploadStatus {
+	return &uploadStatus{message: newStreamingMessage(m), lang: userLanguage(m)}
+}
+
+// Мова повідомлень індексації; без статусу - мова за замовчуванням
+func (s *uploadStatus) language() string {
+	if s == nil {
+		return defaultLanguage
+	}
+	return s.lang
+}
+
+// Показуємо новий етап (ключ каталогу повідомлень) одразу, без обмеження частоти
+func (s *uploadStatus) Stage(key string, args ...any) {
+	if s == nil {
+		return
+	}
+	s.message.show("⏳ " + translate(s.lang, key, args...))
+}
+
+// Показуємо лічильник прогресу (ключ каталогу повідомлень) не частіше за StreamEditInterval
+func (s *uploadStatus) Progress(key string, done, total int) {
+	if s == nil {
+		return
+	}
+	s.message.Update("⏳ " + translate(s.lang, "upload_progress", translate(s.lang, key), done, total))
+}
+
+// Замінюємо повідомлення про хід індексації підсумком
+func (s *uploadStatus) Finish(text string) error {
+	return s.message.Finish(text)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Перевіряємо спільні властивості частин: ліміт UTF-16, цілі символи та відсутність модифікатора на початку
+func checkMessageParts(t *testing.T, parts []string, limit int) {
+	t.Helper()
+	if len(parts) < 2 {
+		t.Fatalf("очікувалося кілька частин, отримано %d", len(parts))
+	}
+	for i, part := range parts {
+		if length := telegramLength(part); length > limit {
+			t.Errorf("частина %d має %d одиниць UTF-16, ліміт %d", i, length, limit)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("частина %d містить розрізаний символ", i)
+		}
+		if first, _ := utf8.DecodeRuneInString(part); isRuneExtension(first) {
+			t.Errorf("частина %d починається з модифікатора символу %U", i, first)
+		}
+	}
+}
+
+func TestTelegramLength(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"abc", 3},
+		{"Привіт", 6},
+		{"😀", 2},
+		{"👍🏽", 4},
+		{"a😀б", 4},
+	}
+	for _, tt := range tests {
+		if got := telegramLength(tt.text); got != tt.want {
+			t.Errorf("telegramLength(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMessageCyrillic(t *testing.T) {
+	text := strings.Repeat("Це речення українською мовою. ", 400)
+	parts := splitMessage(text, telegramMessageLimit)
+	checkMessageParts(t, parts, telegramMessageLimit)
+}
+
+func TestSplitMessageEmoji(t *testing.T) {
+	// Без пробілів розріз припадає на межу символу, а не між половинами сурогатної пари
+	text := strings.Repeat("😀", 3000) + strings.Repeat("👍🏽", 1000)
+	parts := splitMessage(text, telegramMessageLimit)
+	checkMessageParts(t, parts, telegramMessageLimit)
+	if got := strings.Join(parts, ""); got != text {
+		t.Error("після розбиття втрачено або пошкоджено символи")
+	}
+
+	// Непарний ліміт: емодзі з двох одиниць UTF-16 не може вміститися наполовину
+	parts = splitMessage(strings.Repeat("😀", 10), 5)
+	checkMessageParts(t, parts, 5)
+	for i, part := range parts {
+		if part != "😀😀" && i < len(parts)-1 {
+			t.Errorf("частина %d = %q, очікувалося два цілих емодзі", i, part)
+		}
+	}
+}
+
+func TestSplitMessageCodeBlock(t *testing.T) {
+	var code strings.Builder
+	code.WriteString("Вступ.\n\n```go\n")
+	for range 300 {
+		code.WriteString("fmt.Println(\"рядок коду з кирилицею 😀\")\n")
+	}
+	code.WriteString("```\n\nВисновок.")
+
+	parts := splitMessage(code.String(), telegramMessageLimit)
+	checkMessageParts(t, parts, telegramMessageLimit)
+
+	codeParts := 0
+	for i, part := range parts {
+		if !strings.Contains(part, "fmt.Println") {
+			continue
+		}
+		codeParts++
+		// Кожна частина блоку коду має власні огорожі, тож після перетворення блок відкривається знову
+		formatted := markdownToTelegramHTML(part)
+		if !strings.Contains(formatted, `<pre><code class="language-go">`) || !strings.HasSuffix(formatted, "</code></pre>") {
+			t.Errorf("частина %d не є окремим блоком коду: %.80q…", i, formatted)
+		}
+		if strings.Count(formatted, "<pre>") != strings.Count(formatted, "</pre>") {
+			t.Errorf("частина %d має незбалансовані теги <pre>", i)
+		}
+	}
+	if codeParts < 2 {
+		t.Fatalf("блок коду мав розбитися на кілька частин, отримано %d", codeParts)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Додаткові токени, які OpenAI враховує на кожне повідомлення чату (роль, розділювачі)
+const tokensPerMessage = 4
+
+// Кеш токенайзерів за назвою моделі
+var tokenizers = struct {
+	sync.Mutex
+	cache map[string]*tiktoken.Tiktoken
+}{cache: make(map[string]*tiktoken.Tiktoken)}
+
+func init() {
+	// Словники BPE вбудовані в бінарник, тож токенайзер не ходить у мережу під час роботи
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// Токенайзер для моделі; для невідомих моделей використовуємо cl100k_base
+func tokenizerFor(model string) *tiktoken.Tiktoken {
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+
+	if tkm, ok := tokenizers.cache[model]; ok {
+		return tkm
+	}
+
+	tkm, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		tkm, err = tiktoken.GetEncoding("cl100k_base")
+	}
+	if err != nil {
+		return nil
+	}
+	tokenizers.cache[model] = tkm
+
+	return tkm
+}
+
+// Кількість токенів тексту для моделі; без токенайзера повертаємо приблизну оцінку
+func countTokens(model, text string) int {
+	tkm := tokenizerFor(model)
+	if tkm == nil {
+		return estimateTokens(text)
+	}
+	return len(tkm.EncodeOrdinary(text))
+}
+
+// Ділимо текст на шматки не довші за limit токенів моделі, не розрізаючи символи UTF-8.
+// Без токенайзера ділимо за приблизною оцінкою (4 символи на токен).
+func splitByTokens(model, text string, limit int) []string {
+	tkm := tokenizerFor(model)
+	if tkm == nil {
+		runes := []rune(text)
+		var pieces []string
+		for start := 0; start < len(runes); start += limit * 4 {
+			pieces = append(pieces, string(runes[start:min(start+limit*4, len(runes))]))
+		}
+		return pieces
+	}
+
+	tokens := tkm.EncodeOrdinary(text)
+	if len(tokens) <= limit {
+		return []string{text}
+	}
+
+	// Токени разом дають байти тексту, тож межу шматка знаходимо за довжиною їхніх байтів
+	var pieces []string
+	start, offset := 0, 0
+	for i := 0; i < len(tokens); i += limit {
+		for _, token := range tokens[i:min(i+limit, len(tokens))] {
+			offset += len(tkm.Decode([]int{token}))
+		}
+		cut := min(offset, len(text))
+		for cut > start && cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if cut > start {
+			pieces = append(pieces, text[start:cut])
+			start = cut
+		}
+	}
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+	return pieces
+}
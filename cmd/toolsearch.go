@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	pinecone "github.com/pinecone-io/go-pinecone/pinecone"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Назва інструмента пошуку, який може викликати модель
+const searchToolName = "search_knowledge_base"
+
+// Інструкція моделі, коли їй доступний пошук
+const toolSearchInstruction = "Якщо наданих джерел недостатньо для відповіді, виклич " + searchToolName +
+	" з уточненим запитом, щоб знайти більше даних у базі знань. Нові джерела мають наступні номери, посилайся на них так само."
+
+// Пошук у базі знань за текстом запиту в межах поточного запиту користувача
+type knowledgeSearch func(ctx context.Context, query string) (*pinecone.QueryVectorsResponse, error)
+
+// Опис інструмента пошуку для OpenAI
+var searchKnowledgeBaseTool = openai.Tool{
+	Type: openai.ToolTypeFunction,
+	Function: &openai.FunctionDefinition{
+		Name:        searchToolName,
+		Description: "Шукає у векторній базі знань користувача фрагменти документів, релевантні запиту.",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"query":{"type":"string",` +
+			`"description":"Уточнений пошуковий запит мовою документів"}},"required":["query"]}`),
+	},
+}
+
+// Запит до моделі з інструментом пошуку: виконуємо її виклики search_knowledge_base і повертаємо результати в розмову,
+// доки модель не відповість або не буде вичерпано TOOL_SEARCH_MAX_CALLS. Нові збіги додаються до matches,
+// а їхній текст - у межах budget токенів контексту.
+func completeWithToolSearch(ctx context.Context, client *openai.Client, request openai.ChatCompletionRequest, matches *pinecone.QueryVectorsResponse,
+	search knowledgeSearch, budget int) (openai.ChatCompletionResponse, error) {
+	request.Tools = []openai.Tool{searchKnowledgeBaseTool}
+	request.Messages = append([]openai.ChatCompletionMessage(nil), request.Messages...)
+
+	calls := 0
+	for {
+		// Після вичерпання ліміту модель має відповісти з тим, що вже знайдено
+		if calls >= ToolSearchMaxCalls {
+			request.ToolChoice = "none"
+		}
+
+		resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (tools)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+			return client.CreateChatCompletion(ctx, request)
+		})
+		if err != nil {
+			return resp, err
+		}
+		recordChatUsage(resp.Usage)
+		if len(resp.Choices) == 0 {
+			return resp, fmt.Errorf("OpenAI не повернув відповіді")
+		}
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			slog.InfoContext(ctx, "Відповідь після пошуку моделлю", "tool_calls", calls, "match_count", len(matches.Matches))
+			return resp, nil
+		}
+
+		// Кожен виклик інструмента потребує відповіді, навіть якщо ліміт уже вичерпано
+		request.Messages = append(request.Messages, message)
+		for _, call := range message.ToolCalls {
+			var content string
+			if calls >= ToolSearchMaxCalls {
+				content = "Ліміт пошуків вичерпано, відповідай з уже знайденими джерелами."
+			} else {
+				calls++
+				content = runSearchTool(ctx, calls, call, request.Model, matches, search, &budget)
+			}
+			request.Messages = append(request.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    content,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// Виконуємо один виклик пошуку моделлю; результат - нові пронумеровані джерела або пояснення для моделі
+func runSearchTool(ctx context.Context, number int, call openai.ToolCall, model string, matches *pinecone.QueryVectorsResponse, search knowledgeSearch, budget *int) string {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if call.Function.Name != searchToolName {
+		return fmt.Sprintf("Невідомий інструмент %s.", call.Function.Name)
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || strings.TrimSpace(args.Query) == "" {
+		slog.WarnContext(ctx, "Некоректні аргументи виклику пошуку", "call", number, "arguments", call.Function.Arguments)
+		return "Некоректні аргументи: потрібен непорожній query."
+	}
+
+	found, err := search(ctx, args.Query)
+	if err != nil {
+		slog.WarnContext(ctx, "Пошук на вимогу моделі не вдався", "call", number, "query", args.Query, "error", err)
+		return "Пошук не вдався, відповідай з уже знайденими джерелами."
+	}
+
+	// Джерела, які модель уже бачила, не повторюємо; нові нумеруємо після наявних
+	seen := make(map[string]bool, len(matches.Matches))
+	for _, match := range matches.Matches {
+		seen[matchKey(match)] = true
+	}
+	var sb strings.Builder
+	added := 0
+	for _, match := range found.Matches {
+		if seen[matchKey(match)] {
+			continue
+		}
+		block := sourceBlock(len(matches.Matches)+1, match)
+		tokens := countTokens(model, block)
+		if tokens > *budget {
+			break
+		}
+		*budget -= tokens
+		seen[matchKey(match)] = true
+		matches.Matches = append(matches.Matches, match)
+		sb.WriteString(block)
+		added++
+	}
+
+	slog.InfoContext(ctx, "Модель шукає в базі знань", "call", number, "query", args.Query, "match_count", len(found.Matches), "added", added)
+	if added == 0 {
+		return "Нових релевантних джерел не знайдено."
+	}
+	return sb.String()
+}
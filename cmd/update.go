@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	telebot "gopkg.in/telebot.v3"
+)
+
+// Ключ режиму оновлення документа в context.Context
+type updateUploadKey struct{}
+
+// Підпис до документа, що замінює попередню версію файлу з такою самою назвою
+func isUpdateCaption(caption string) bool {
+	caption = strings.TrimSpace(caption)
+	return strings.EqualFold(caption, "update") || strings.EqualFold(caption, "оновити")
+}
+
+// Контекст завантаження, у якому нова версія файлу замінює його попередні вектори
+func contextWithUpdate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, updateUploadKey{}, true)
+}
+
+// Чи завантаження оновлює вже завантажений документ
+func isUpdateUpload(ctx context.Context) bool {
+	update, _ := ctx.Value(updateUploadKey{}).(bool)
+	return update
+}
+
+// Зміни в документі після оновлення: частини нової версії порівнюються зі старою за хешем вмісту
+type updateDelta struct {
+	Added     int // Нові частини, яких не було в старій версії
+	Removed   int // Частини старої версії, яких немає в новій
+	Unchanged int // Частини, що є в обох версіях
+	Deleted   int // Видалено векторів старої версії з Pinecone
+}
+
+// Порівнюємо частини нової версії з хешами частин старої версії файлу
+func newUpdateDelta(previous map[string]bool, chunks []documentChunk) *updateDelta {
+	delta := &updateDelta{}
+	current := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		hash := contentHash(chunk.Text)
+		if current[hash] {
+			continue
+		}
+		current[hash] = true
+		if previous[hash] {
+			delta.Unchanged++
+		} else {
+			delta.Added++
+		}
+	}
+	for hash := range previous {
+		if !current[hash] {
+			delta.Removed++
+		}
+	}
+	return delta
+}
+
+// Повідомлення користувачу мовою lang про оновлення документа kind
+func (d *updateDelta) message(lang, kind string) string {
+	if d.Added == 0 && d.Removed == 0 && d.Deleted == 0 {
+		return translate(lang, "update_unchanged", kind)
+	}
+	return translate(lang, "update_done", kind, d.Added, d.Removed, d.Unchanged)
+}
+
+// Видаляємо з усіх колекцій вектори файлу, що не належать його новій версії з префіксом ID docID,
+// разом із векторами поза реєстром, знайденими за фільтром. Повертаємо кількість видалених векторів.
+func removeStaleVectors(ctx context.Context, namespace, fileName, docID string) (int, error) {
+	filter, probe, err := fileVectorsQuery(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, collection := range collectionNames() {
+		// Спільні з іншими файлами вектори залишаються в індексі, а з реєстру файлу прибираються
+		others := registryOtherFilesVectorIDs(collection, namespace, fileName)
+		keep := slices.Collect(maps.Keys(others))
+		var stale, owned []string
+		for _, id := range registryVectorIDs(collection, namespace, fileName) {
+			switch {
+			case strings.HasPrefix(id, docID+"-"):
+				keep = append(keep, id)
+			case others[id]:
+				stale = append(stale, id)
+			default:
+				stale = append(stale, id)
+				owned = append(owned, id)
+			}
+		}
+
+		// Рахуємо лише застарілі вектори, які справді є в індексі колекції
+		existing, err := existingVectorIDs(collection, namespace, owned)
+		if err != nil {
+			return deleted, err
+		}
+		found, err := deleteFileFromCollection(collection, namespace, existing, keep, filter, probe)
+		deleted += found + len(existing)
+		if err != nil {
+			return deleted, err
+		}
+		if err := unregisterVectors(collection, namespace, fileName, stale); err != nil {
+			slog.ErrorContext(ctx, "Помилка оновлення реєстру документів", "collection", collection, "file", fileName, "error", err)
+		}
+	}
+	return deleted, nil
+}
+
+// Обробка /update: наступний документ замінить попередню версію файлу з такою самою назвою
+func handleUpdate(m telebot.Context) error {
+	setAwaitingUpdate(m.Sender().ID)
+	slog.InfoContext(requestContext(m), "Користувач оновлює документ", "user_id", m.Sender().ID)
+	return m.Send(translate(userLanguage(m), "update_prompt"))
+}
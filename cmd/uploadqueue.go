@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Скільки під час зупинки чекаємо на завантаження, що вже обробляються
+const uploadDrainTimeout = time.Minute
+
+// Черга більше не приймає завантажень, бо бот зупиняється
+var errUploadsStopped = errors.New("бот зупиняється")
+
+// Завантаження, що обробляються та чекають у черзі, для кожного користувача
+var uploadQueues = struct {
+	sync.Mutex
+	users   map[int64]*userUploadQueue
+	active  sync.WaitGroup // Завантаження, що обробляються зараз
+	stopped chan struct{}  // Закривається в drainUploads
+}{users: make(map[int64]*userUploadQueue), stopped: make(chan struct{})}
+
+// Черга завантажень одного користувача
+type userUploadQueue struct {
+	active  int             // Скільки завантажень обробляється
+	waiting []chan struct{} // Завантаження в черзі; закриття каналу передає їм місце
+}
+
+// Займаємо місце для обробки документа користувача, не більше USER_MAX_CONCURRENT_UPLOADS одночасно.
+// Якщо місць немає, викликаємо onQueued з кількістю завантажень попереду і чекаємо своєї черги.
+// Очікування переривається /cancel (як операція користувача) або зупинкою бота; release треба викликати після обробки.
+func acquireUploadSlot(ctx context.Context, userID int64, onQueued func(ahead int)) (release func(), err error) {
+	uploadQueues.Lock()
+	select {
+	case <-uploadQueues.stopped:
+		uploadQueues.Unlock()
+		return nil, errUploadsStopped
+	default:
+	}
+
+	queue := uploadQueues.users[userID]
+	if queue == nil {
+		queue = &userUploadQueue{}
+		uploadQueues.users[userID] = queue
+	}
+	if queue.active < UserMaxConcurrentUploads && len(queue.waiting) == 0 {
+		queue.active++
+		uploadQueues.active.Add(1)
+		uploadQueues.Unlock()
+		return func() { releaseUploadSlot(userID) }, nil
+	}
+
+	ready := make(chan struct{})
+	queue.waiting = append(queue.waiting, ready)
+	ahead := queue.active + len(queue.waiting) - 1
+	uploadQueues.Unlock()
+
+	onQueued(ahead)
+
+	// Очікування в черзі можна скасувати через /cancel, як і саму обробку
+	waitCtx, finish := startOperation(ctx, userID)
+	defer finish()
+
+	select {
+	case <-ready:
+		return func() { releaseUploadSlot(userID) }, nil
+	case <-waitCtx.Done():
+		err = waitCtx.Err()
+	case <-uploadQueues.stopped:
+		err = errUploadsStopped
+	}
+
+	// Виходимо з черги; якщо місце вже передали нам, віддаємо його наступному
+	uploadQueues.Lock()
+	for i, waiting := range queue.waiting {
+		if waiting == ready {
+			queue.waiting = append(queue.waiting[:i], queue.waiting[i+1:]...)
+			uploadQueues.Unlock()
+			return nil, err
+		}
+	}
+	uploadQueues.Unlock()
+	releaseUploadSlot(userID)
+	return nil, err
+}
+
+// Звільняємо місце: передаємо його першому в черзі користувача або зменшуємо кількість активних
+func releaseUploadSlot(userID int64) {
+	uploadQueues.Lock()
+	defer uploadQueues.Unlock()
+
+	queue := uploadQueues.users[userID]
+	if len(queue.waiting) > 0 {
+		// Місце переходить до наступного завантаження, тож лічильник активних не змінюється
+		close(queue.waiting[0])
+		queue.waiting = queue.waiting[1:]
+		return
+	}
+
+	queue.active--
+	uploadQueues.active.Done()
+	if queue.active == 0 {
+		delete(uploadQueues.users, userID)
+	}
+}
+
+// Зупиняємо чергу: завантаження, що чекають, отримують errUploadsStopped,
+// а на ті, що вже обробляються, чекаємо не довше за timeout
+func drainUploads(timeout time.Duration) {
+	uploadQueues.Lock()
+	close(uploadQueues.stopped)
+	uploadQueues.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		uploadQueues.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Усі завантаження завершено")
+	case <-time.After(timeout):
+		slog.Warn("Не всі завантаження завершилися до зупинки", "timeout", timeout)
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Накопичене використання токенів OpenAI з моменту старту
+var tokenUsage struct {
+	embeddingTokens  atomic.Int64
+	promptTokens     atomic.Int64
+	completionTokens atomic.Int64
+}
+
+// Враховуємо токени запиту на векторизацію
+func recordEmbeddingUsage(usage openai.Usage) {
+	tokenUsage.embeddingTokens.Add(int64(usage.PromptTokens))
+	slog.Debug("Використання OpenAI (ембеддинги)", "prompt_tokens", usage.PromptTokens)
+}
+
+// Враховуємо токени запиту на генерацію відповіді
+func recordChatUsage(usage openai.Usage) {
+	tokenUsage.promptTokens.Add(int64(usage.PromptTokens))
+	tokenUsage.completionTokens.Add(int64(usage.CompletionTokens))
+	slog.Info("Використання OpenAI (відповідь)", "prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens)
+}
+
+// Орієнтовна вартість використаних токенів за тарифами з налаштувань (долари)
+func estimatedCost() float64 {
+	return float64(tokenUsage.embeddingTokens.Load())/1000*OpenAIEmbeddingCostPer1K +
+		float64(tokenUsage.promptTokens.Load())/1000*OpenAIPromptCostPer1K +
+		float64(tokenUsage.completionTokens.Load())/1000*OpenAICompletionCostPer1K
+}
+
+// Рядки про використання токенів для звіту /stats
+func usageReport() string {
+	return fmt.Sprintf("Токени OpenAI: ембеддинги %d, запити %d, відповіді %d\nОрієнтовна вартість: $%.4f\n",
+		tokenUsage.embeddingTokens.Load(), tokenUsage.promptTokens.Load(), tokenUsage.completionTokens.Load(), estimatedCost())
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	telebot "gopkg.in/telebot.v3"
+)
+
+const (
+	mediaGroupDelay          = 1500 * time.Millisecond // Скільки чекаємо на решту фото альбому після останнього
+	maxVisionImages          = 10                      // Telegram надсилає в альбомі до 10 фото
+	photoIndexCallbackPrefix = "photoindex"            // Дані кнопки індексації зображення: photoindex|<id>
+	photoIndexPendingTTL     = 24 * time.Hour
+)
+
+// Питання до зображення без підпису
+const defaultPhotoQuestion = "Що зображено на цьому зображенні? Якщо на ньому є текст, коротко перекажи його зміст."
+
+// Інструкція для витягування вмісту зображення перед індексацією
+const photoExtractionPrompt = `Перепиши дослівно весь текст, який є на зображеннях, зберігаючи структуру (заголовки, списки, таблиці).
+Після тексту коротко опиши, що зображено. Не додавай нічого, чого немає на зображеннях.`
+
+// Фото альбому (media group), які збираються в один запит
+type photoAlbum struct {
+	ctx       telebot.Context
+	fileIDs   []string
+	caption   string
+	addressed bool // Хоча б одне фото альбому адресоване боту (у групі - згадка в підписі)
+	timer     *time.Timer
+}
+
+// Зображення, які користувач може додати до бази знань кнопкою під відповіддю
+type photoIndexRequest struct {
+	UserID  int64
+	FileIDs []string
+}
+
+var (
+	// Альбоми, що ще збираються, за media_group_id
+	photoAlbums = struct {
+		sync.Mutex
+		albums map[string]*photoAlbum
+	}{albums: make(map[string]*photoAlbum)}
+
+	// Зображення, що чекають на натискання кнопки індексації
+	pendingPhotoIndex = newLRUCache[photoIndexRequest](feedbackPendingSize, photoIndexPendingTTL)
+)
+
+// Обробка фото: окреме фото відповідаємо одразу, фото альбому збираємо та відповідаємо на всі разом
+func handlePhoto(c telebot.Context) error {
+	message := c.Message()
+
+	// У групах відповідаємо лише на фото, адресовані боту (GROUP_TRIGGER): кожна відповідь - платний запит до моделі
+	caption, _ := groupCaption(c)
+	addressed := groupUploadAddressed(c)
+	if addressed && isAwaitingDocument(c.Sender().ID) {
+		return c.Send(translate(userLanguage(c), "awaiting_document"))
+	}
+
+	if message.AlbumID == "" {
+		if !addressed {
+			return nil
+		}
+		return answerPhotos(c, []string{message.Photo.FileID}, caption)
+	}
+
+	photoAlbums.Lock()
+	defer photoAlbums.Unlock()
+
+	album, ok := photoAlbums.albums[message.AlbumID]
+	if !ok {
+		album = &photoAlbum{ctx: c}
+		photoAlbums.albums[message.AlbumID] = album
+		albumID := message.AlbumID
+		album.timer = time.AfterFunc(mediaGroupDelay, func() {
+			photoAlbums.Lock()
+			album := photoAlbums.albums[albumID]
+			delete(photoAlbums.albums, albumID)
+			photoAlbums.Unlock()
+
+			// Згадка буває лише в підписі одного фото, тож рішення приймаємо для всього альбому
+			if !album.addressed {
+				return
+			}
+
+			if err := answerPhotos(album.ctx, album.fileIDs, album.caption); err != nil {
+				slog.ErrorContext(requestContext(album.ctx), "Помилка відповіді на альбом фото", "user_id", album.ctx.Sender().ID, "error", err)
+			}
+		})
+	} else {
+		album.timer.Reset(mediaGroupDelay)
+	}
+
+	if len(album.fileIDs) < maxVisionImages {
+		album.fileIDs = append(album.fileIDs, message.Photo.FileID)
+	}
+	album.addressed = album.addressed || addressed
+	// Підпис зазвичай має лише одне фото альбому
+	if album.caption == "" {
+		album.caption = caption
+	}
+	return nil
+}
+
+// Відповідаємо на запитання (підпис) щодо зображень моделлю з підтримкою зображень
+func answerPhotos(c telebot.Context, fileIDs []string, caption string) error {
+	ctx := requestContext(c)
+	if !allowRequest(c.Sender().ID) {
+		return c.Send(translate(userLanguage(c), "rate_limited"))
+	}
+
+	queriesHandled.Add(1)
+	metricQueries.Inc()
+	started := time.Now()
+	slog.InfoContext(ctx, "Запит користувача щодо зображень", "user_id", c.Sender().ID, "images", len(fileIDs), "query_len", len([]rune(caption)))
+
+	stopTyping := startTyping(c)
+	defer stopTyping()
+
+	question := strings.TrimSpace(caption)
+	if question == "" {
+		question = defaultPhotoQuestion
+	}
+
+	images, err := downloadPhotos(c.Bot(), fileIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка завантаження фото", "user_id", c.Sender().ID, "error", err)
+		return c.Send(withRequestID(ctx, translate(userLanguage(c), "image_download_error", err)))
+	}
+
+	answer, err := askVisionModel(ctx, question, images)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка відповіді щодо зображення", "user_id", c.Sender().ID, "stage", "vision", "error", err)
+		metricErrors.WithLabelValues("vision").Inc()
+		if isTimeoutError(err) {
+			return c.Send(withRequestID(ctx, translate(replyLanguage(c, question), "timeout")))
+		}
+		if message, ok := openAIErrorMessage(replyLanguage(c, question), err); ok {
+			return c.Send(withRequestID(ctx, message))
+		}
+		return c.Send(withRequestID(ctx, translate(replyLanguage(c, question), "vision_error", err)))
+	}
+
+	slog.InfoContext(ctx, "Повернена відповідь щодо зображень", "user_id", c.Sender().ID, "answer_len", len([]rune(answer)),
+		"latency_ms", time.Since(started).Milliseconds())
+
+	appendSessionHistory(c.Sender().ID, "[зображення] "+question, answer)
+
+	return newStreamingMessage(c).FinishMarkdown(answer, photoIndexMarkup(userLanguage(c), c.Sender().ID, fileIDs))
+}
+
+// Завантажуємо фото з Telegram
+func downloadPhotos(bot *telebot.Bot, fileIDs []string) ([][]byte, error) {
+	images := make([][]byte, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		image, err := downloadTelegramFile(bot, fileID)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// Надсилаємо зображення разом із текстом prompt моделі OPENAI_VISION_MODEL
+func askVisionModel(ctx context.Context, prompt string, images [][]byte) (string, error) {
+	parts := []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: prompt}}
+	for _, image := range images {
+		dataURL := fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(image), base64.StdEncoding.EncodeToString(image))
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: dataURL, Detail: openai.ImageURLDetailAuto},
+		})
+	}
+
+	client := newOpenAIClient()
+	request := openai.ChatCompletionRequest{
+		Model:     VisionModel,
+		MaxTokens: OpenAIMaxTokens,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "Ти допомагаєш користувачу розібратися із зображеннями, зокрема знімками документів. " + languageInstruction(prompt)},
+			{Role: openai.ChatMessageRoleUser, MultiContent: parts},
+		},
+	}
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateChatCompletion (vision)", OpenAIChatTimeout, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Помилка запиту до моделі %s: %w", VisionModel, err)
+	}
+	recordChatUsage(resp.Usage)
+
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("Модель повернула порожню відповідь")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// Кнопка мовою lang під відповіддю, що пропонує додати вміст зображень до бази знань
+func photoIndexMarkup(lang string, userID int64, fileIDs []string) *telebot.ReplyMarkup {
+	id, err := callbackID()
+	if err != nil {
+		slog.Warn("Не вдалося створити ID кнопки індексації", "error", err)
+		return nil
+	}
+	pendingPhotoIndex.Add(id, photoIndexRequest{UserID: userID, FileIDs: fileIDs})
+
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(telebot.Btn{Text: translate(lang, "photo_index_button"), Data: photoIndexCallbackPrefix + "|" + id}))
+	return markup
+}
+
+// Обробка кнопки індексації: витягуємо текст і опис зображень та додаємо їх як документ
+func handlePhotoIndexCallback(c telebot.Context, data string) error {
+	lang := userLanguage(c)
+	_, id, _ := strings.Cut(data, "|")
+	request, ok := pendingPhotoIndex.Get(id)
+	if !ok || request.UserID != c.Sender().ID {
+		return c.Respond(&telebot.CallbackResponse{Text: translate(lang, "photo_index_expired")})
+	}
+	if message, ok := checkUploadQuota(lang, c.Sender().ID, ""); !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: message, ShowAlert: true})
+	}
+	pendingPhotoIndex.Remove(id)
+
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+		slog.WarnContext(requestContext(c), "Не вдалося прибрати кнопку індексації", "error", err)
+	}
+	if err := c.Respond(&telebot.CallbackResponse{Text: translate(lang, "photo_index_started")}); err != nil {
+		slog.WarnContext(requestContext(c), "Не вдалося відповісти на натискання кнопки", "error", err)
+	}
+
+	uploadsHandled.Add(1)
+	recordUploadMetric("image")
+
+	status := newUploadStatus(c)
+
+	// Операцію можна скасувати через /cancel
+	ctx, finish := startOperation(requestContext(c), c.Sender().ID)
+	defer finish()
+
+	status.Stage("stage_images")
+	images, err := downloadPhotos(c.Bot(), request.FileIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка завантаження фото", "user_id", c.Sender().ID, "error", err)
+		return status.Finish(withRequestID(ctx, translate(lang, "image_download_error", err)))
+	}
+	text, err := askVisionModel(ctx, photoExtractionPrompt, images)
+	if err != nil {
+		slog.ErrorContext(ctx, "Помилка розпізнавання зображення", "user_id", c.Sender().ID, "error", err)
+		return status.Finish(withRequestID(ctx, translate(lang, "image_extract_error", userFacingError(err))))
+	}
+
+	fileName := fmt.Sprintf("photo-%s.jpg", time.Now().Format("20060102-150405"))
+	metadata := uploadMetadata(c, http.DetectContentType(images[0]))
+	metadata["type"] = "image"
+	metadata["images"] = len(images)
+
+	result, err := chunkAndUpsert(ctx, uploadCollection(c.Sender().ID, "image"), userNamespace(c.Sender().ID), fileName, text, metadata, status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return status.Finish(result.cancelledMessage(lang))
+		}
+		slog.ErrorContext(ctx, "Помилка індексації зображення", "user_id", c.Sender().ID, "file", fileName, "error", err)
+		return status.Finish(withRequestID(ctx, translate(lang, "image_upload_error", userFacingError(err))))
+	}
+
+	return status.Finish(result.message(lang, translate(lang, "kind_image", fileName)))
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Розпізнаємо голосове повідомлення (.ogg) через OpenAI Whisper
+func transcribeVoice(ctx context.Context, audio []byte) (string, error) {
+	client := newOpenAIClient()
+
+	resp, err := withContextTimeoutRetry(ctx, "OpenAI CreateTranscription", OpenAIChatTimeout, func(ctx context.Context) (openai.AudioResponse, error) {
+		return client.CreateTranscription(ctx, openai.AudioRequest{
+			Model:    openai.Whisper1,
+			Reader:   bytes.NewReader(audio),
+			FilePath: "voice.ogg", // Розширення підказує API формат аудіо
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(resp.Text), nil
+}